@@ -6,13 +6,27 @@ import (
 	"os"
 
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/thebug/lab/eko/v3/pkg/config"
+	"github.com/thebug/lab/eko/v3/pkg/control"
 	"github.com/thebug/lab/eko/v3/pkg/ui"
 )
 
 func main() {
 	imageMode := flag.Bool("i", false, "Enable image generation mode")
+	inline := flag.Bool("inline", false, "Render in the normal terminal buffer instead of the alt-screen")
+	configFile := flag.String("c", "", "Use an alternate config file instead of ~/.config/eko/config.json")
+	flag.StringVar(configFile, "config", "", "Same as -c")
+	controlAddr := flag.String("control", "", "Accept commands from other programs on this Unix socket path or host:port on a loopback address (no authentication)")
 	flag.Parse()
 
+	if *configFile != "" {
+		*configFile = config.ExpandPath(*configFile)
+		if err := config.CheckConfigFile(*configFile); err != nil {
+			fmt.Printf("Invalid config file: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
 	// Add panic recovery
 	defer func() {
 		if r := recover(); r != nil {
@@ -21,7 +35,18 @@ func main() {
 		}
 	}()
 
-	p := tea.NewProgram(ui.NewModel(*imageMode, flag.Args()), tea.WithInput(os.Stdin), tea.WithOutput(os.Stdout))
+	p := tea.NewProgram(ui.NewModel(*imageMode, *inline, *configFile, flag.Args()), tea.WithInput(os.Stdin), tea.WithOutput(os.Stdout))
+
+	if *controlAddr != "" {
+		listener, err := control.Listen(*controlAddr)
+		if err != nil {
+			fmt.Printf("Can't open control socket: %v\n", err)
+			os.Exit(1)
+		}
+		defer listener.Close()
+		go control.Serve(listener, p.Send)
+	}
+
 	if _, err := p.Run(); err != nil {
 		fmt.Printf("Error running program: %v", err)
 		os.Exit(1)
@@ -0,0 +1,94 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/thebug/lab/eko/v3/pkg/types"
+)
+
+// Cache stores finished responses on disk, one file per request hash
+type Cache struct {
+	dir string
+}
+
+// New creates a cache rooted at dir. The directory is created on first Put.
+func New(dir string) *Cache {
+	return &Cache{dir: dir}
+}
+
+// Key hashes everything that determines a response: the model, the prompt
+// template, the conversation (roles and content only) and generation options
+func Key(model, template string, messages []types.Message, options map[string]interface{}) string {
+	type turn struct {
+		Role    string `json:"role"`
+		Content string `json:"content"`
+	}
+	turns := make([]turn, len(messages))
+	for i, msg := range messages {
+		turns[i] = turn{Role: msg.Role, Content: msg.Content}
+	}
+
+	// Map keys are marshalled in sorted order, so equal options hash equally
+	data, _ := json.Marshal(struct {
+		Model    string                 `json:"model"`
+		Template string                 `json:"template,omitempty"`
+		Messages []turn                 `json:"messages"`
+		Options  map[string]interface{} `json:"options,omitempty"`
+	}{model, template, turns, options})
+
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Get returns the cached response for key, if any
+func (c *Cache) Get(key string) (string, bool) {
+	data, err := os.ReadFile(c.path(key))
+	if err != nil {
+		return "", false
+	}
+	return string(data), true
+}
+
+// Put stores a finished response under key
+func (c *Cache) Put(key, response string) error {
+	if err := os.MkdirAll(c.dir, 0755); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	if err := os.WriteFile(c.path(key), []byte(response), 0644); err != nil {
+		return fmt.Errorf("failed to write cache entry: %w", err)
+	}
+	return nil
+}
+
+// Clear removes every cached response and reports how many were deleted
+func (c *Cache) Clear() (int, error) {
+	entries, err := os.ReadDir(c.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	removed := 0
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".txt") {
+			continue
+		}
+		if err := os.Remove(filepath.Join(c.dir, entry.Name())); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+func (c *Cache) path(key string) string {
+	return filepath.Join(c.dir, key+".txt")
+}
@@ -11,27 +11,38 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
+	"github.com/thebug/lab/eko/v3/pkg/debuglog"
 )
 
 // Debug logging
 func logDebug(format string, v ...interface{}) {
-	f, err := os.OpenFile("eko-debug.log", os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-	if err != nil {
-		return
-	}
-	defer f.Close()
-	timestamp := time.Now().Format("15:04:05.000")
-	fmt.Fprintf(f, timestamp+" "+format+"\n", v...)
+	debuglog.Printf(format, v...)
 }
 
 type Client struct {
 	BaseURL string
 	ClientID string
+	// InjectAllPositive sends the prompt to every positive text node (e.g. SDXL
+	// base + refiner) instead of only the first one
+	InjectAllPositive bool
+	// Nodes overrides which node types or IDs are treated as prompt, latent and
+	// sampler nodes
+	Nodes NodeMap
+	// OutputDir is where images are saved, created when missing; empty saves
+	// them in the current directory
+	OutputDir string
+	// FilePrefix starts the names of saved images; empty uses DefaultFilePrefix
+	FilePrefix string
+
+	limit    atomic.Pointer[jobLimit] // Set by SetMaxJobs
+	lastSeed atomic.Pointer[int64]    // Seed of the last generation, reused by seed-last
 }
 
 type ProgressUpdate struct {
@@ -42,8 +53,46 @@ type ProgressUpdate struct {
 	Percent        float64
 	ElapsedTime    time.Duration
 	QueueRemaining int
+	Seed           int64  // Sampler seed, sent with the first update; 0 when the workflow has none
+	Warning        string // Something that went wrong without stopping the generation
 }
 
+// DefaultFilePrefix starts the names of saved images unless FilePrefix is set
+const DefaultFilePrefix = "eko-img"
+
+// ImageInfo records the settings an image was generated with, so it can be reproduced
+type ImageInfo struct {
+	Seed     int64
+	HasSeed  bool // False when the workflow has no sampler seed
+	Width    int
+	Height   int
+	Prompt   string   // Prompt sent to the positive nodes, without the --no clause
+	Negative string   // Negative prompt, from the --no clause or the workflow default
+	Files    []string // Paths of the downloaded images
+}
+
+// ExecutionError describes a node failure reported by ComfyUI during execution
+type ExecutionError struct {
+	NodeID        string
+	NodeType      string
+	ExceptionType string
+	Message       string
+}
+
+func (e *ExecutionError) Error() string {
+	node := e.NodeID
+	if e.NodeType != "" {
+		node = fmt.Sprintf("%s (%s)", e.NodeID, e.NodeType)
+	}
+	msg := strings.TrimSpace(e.Message)
+	if e.ExceptionType != "" {
+		msg = e.ExceptionType + ": " + msg
+	}
+	return fmt.Sprintf("execution error in node %s: %s", node, msg)
+}
+
+// NewClient creates a client for the ComfyUI server at baseURL. Its random
+// ClientID can be replaced with a fixed one before the first request.
 func NewClient(baseURL string) *Client {
 	// Generate a simple random client ID
 	rand.Seed(time.Now().UnixNano())
@@ -55,14 +104,39 @@ func NewClient(baseURL string) *Client {
 	}
 }
 
-// GenerateImage sends a prompt to ComfyUI and waits for the result
-func (c *Client) GenerateImage(workflowJSON []byte, prompt string, progressChan chan<- ProgressUpdate) (string, error) {
+// seedRegex matches the seed-<n> and seed-last prompt tags
+var seedRegex = regexp.MustCompile(`\bseed-(\d+|last)\b`)
+
+// batchRegex matches the batch-<n> prompt tag
+var batchRegex = regexp.MustCompile(`\bbatch-(\d+)\b`)
+
+// maxBatch caps batch-<n>, since every image in a batch shares the GPU's memory
+const maxBatch = 8
+
+// GenerateImage runs the workflow with prompt on ComfyUI and waits for the
+// result. A "--no ..." clause at the end of the prompt fills the negative node;
+// without one, defaultNegative does. The returned ImageInfo holds the
+// randomized seed and the latent image size.
+func (c *Client) GenerateImage(workflowJSON []byte, prompt, defaultNegative string, progressChan chan<- ProgressUpdate) (string, ImageInfo, error) {
+	var info ImageInfo
+	var seedNodeID string
+
+	// Wait for a free slot under the max_image_jobs limit
+	release := c.acquire()
+	defer release()
+
 	// 1. Parse the workflow JSON
 	var workflow map[string]interface{}
 	if err := json.Unmarshal(workflowJSON, &workflow); err != nil {
-		return "", fmt.Errorf("failed to parse workflow JSON: %w", err)
+		return "", info, fmt.Errorf("failed to parse workflow JSON: %w", err)
 	}
 
+	prompt, negative := SplitNegative(prompt)
+	if negative == "" {
+		negative = defaultNegative
+	}
+	info.Prompt, info.Negative = prompt, negative
+
 	// Check for aspect ratio override in prompt
 	// Pattern: ar-<width>:<height>
 	arRegex := regexp.MustCompile(`ar-(\d+):(\d+)`)
@@ -81,9 +155,41 @@ func (c *Client) GenerateImage(workflowJSON []byte, prompt string, progressChan
 		}
 	}
 
+	// A seed-<n> tag pins the sampler seed, and seed-last reuses the previous one
+	var pinnedSeed *int64
+	if matches := seedRegex.FindStringSubmatch(prompt); matches != nil {
+		if matches[1] == "last" {
+			pinnedSeed = c.lastSeed.Load()
+			if pinnedSeed == nil {
+				return "", info, fmt.Errorf("seed-last: no image has been generated yet")
+			}
+		} else {
+			seed, err := strconv.ParseInt(matches[1], 10, 64)
+			if err != nil {
+				return "", info, fmt.Errorf("invalid seed %q: %w", matches[1], err)
+			}
+			pinnedSeed = &seed
+		}
+		prompt = strings.TrimSpace(seedRegex.ReplaceAllString(prompt, ""))
+		info.Prompt = prompt
+	}
+
+	// A batch-<n> tag generates n variations at once
+	batchSize := 0
+	if matches := batchRegex.FindStringSubmatch(prompt); matches != nil {
+		batchSize, _ = strconv.Atoi(matches[1])
+		batchSize = max(1, min(batchSize, maxBatch))
+		prompt = strings.TrimSpace(batchRegex.ReplaceAllString(prompt, ""))
+		info.Prompt = prompt
+	}
+
 	// 2. Inject the prompt into the workflow
-	// Heuristic: Find the best CLIPTextEncode node
-	var positiveNodeID string
+	// Heuristic: Find the best CLIPTextEncode node (or the configured text nodes)
+	textNodes := orDefault(c.Nodes.Positive, defaultTextNodes)
+	latentNodes := orDefault(c.Nodes.Latent, defaultLatentNodes)
+	samplerNodes := orDefault(c.Nodes.Sampler, defaultSamplerNodes)
+	var positiveNodeIDs []string
+	var refinerNodeIDs []string
 	var negativeNodeID string
 	var lastTextNodeID string
 	
@@ -98,35 +204,58 @@ func (c *Client) GenerateImage(workflowJSON []byte, prompt string, progressChan
 		}
 
 		// Randomize seed in KSampler
-		if classType == "KSampler" || classType == "KSamplerAdvanced" {
+		if matchNode(samplerNodes, nodeID, classType) {
 			inputs, ok := nodeMap["inputs"].(map[string]interface{})
 			if ok {
-				if _, hasSeed := inputs["seed"]; hasSeed {
+				// Custom samplers and noise nodes often call it noise_seed
+				for _, key := range []string{"seed", "noise_seed"} {
+					if _, hasSeed := inputs[key]; !hasSeed {
+						continue
+					}
 					// Generate a random seed (ComfyUI uses large integers)
-					inputs["seed"] = rand.Int63()
-					logDebug("Randomized seed for node %s", nodeID)
+					seed := rand.Int63()
+					if pinnedSeed != nil {
+						seed = *pinnedSeed
+					}
+					inputs[key] = seed
+					logDebug("Set seed %d for node %s", seed, nodeID)
+					// Report the first sampler's seed; map order is random, so pick by ID
+					if !info.HasSeed || nodeID < seedNodeID {
+						info.Seed, info.HasSeed, seedNodeID = seed, true, nodeID
+					}
+					break
 				}
 			}
 		}
 
-		if classType == "CLIPTextEncode" || classType == "ShowText" || classType == "PrimitiveString" {
+		if matchNode(c.Nodes.Negative, nodeID, classType) {
+			negativeNodeID = nodeID
+		} else if matchNode(textNodes, nodeID, classType) {
 			// Check metadata
+			lowerTitle := ""
 			if meta, ok := nodeMap["_meta"].(map[string]interface{}); ok {
 				if title, ok := meta["title"].(string); ok {
-					lowerTitle := strings.ToLower(title)
-					if strings.Contains(lowerTitle, "positive") {
-						positiveNodeID = nodeID
-					} else if strings.Contains(lowerTitle, "negative") {
-						negativeNodeID = nodeID
-					}
+					lowerTitle = strings.ToLower(title)
 				}
 			}
+			switch {
+			case strings.Contains(lowerTitle, "negative"):
+				negativeNodeID = nodeID
+			case strings.Contains(lowerTitle, "positive"):
+				positiveNodeIDs = append(positiveNodeIDs, nodeID)
+			case strings.Contains(lowerTitle, "refiner"):
+				// Refiner prompt nodes are often titled without "positive"
+				refinerNodeIDs = append(refinerNodeIDs, nodeID)
+			case len(c.Nodes.Positive) > 0:
+				// Configured positive nodes take the prompt even without a telling title
+				positiveNodeIDs = append(positiveNodeIDs, nodeID)
+			}
 			lastTextNodeID = nodeID
 		}
 		
 		// Override dimensions if found
 		// Support both EmptyLatentImage and EmptySD3LatentImage
-		if overrideWidth > 0 && overrideHeight > 0 && (classType == "EmptyLatentImage" || classType == "EmptySD3LatentImage") {
+		if overrideWidth > 0 && overrideHeight > 0 && matchNode(latentNodes, nodeID, classType) {
 			inputs, ok := nodeMap["inputs"].(map[string]interface{})
 			if ok {
 				if _, hasWidth := inputs["width"]; hasWidth {
@@ -137,22 +266,41 @@ func (c *Client) GenerateImage(workflowJSON []byte, prompt string, progressChan
 				}
 			}
 		}
+		if matchNode(latentNodes, nodeID, classType) {
+			if inputs, ok := nodeMap["inputs"].(map[string]interface{}); ok {
+				info.Width = intInput(inputs["width"])
+				info.Height = intInput(inputs["height"])
+				if _, hasBatch := inputs["batch_size"]; hasBatch && batchSize > 0 {
+					inputs["batch_size"] = batchSize
+				}
+			}
+		}
 	}
-	
-	// Decide which node to inject into
-	targetNodeID := ""
-	if positiveNodeID != "" {
-		targetNodeID = positiveNodeID
+
+	// Decide which nodes to inject into. Map iteration order is random, so sort
+	// to pick the same node every run; base positives come before refiners.
+	sort.Strings(positiveNodeIDs)
+	sort.Strings(refinerNodeIDs)
+	positiveNodeIDs = append(positiveNodeIDs, refinerNodeIDs...)
+
+	var targetNodeIDs []string
+	if len(positiveNodeIDs) > 0 {
+		targetNodeIDs = positiveNodeIDs
+		if !c.InjectAllPositive {
+			targetNodeIDs = positiveNodeIDs[:1]
+		}
 	} else if lastTextNodeID != "" && lastTextNodeID != negativeNodeID {
 		// If we didn't find a positive one, but found a text node that isn't explicitly negative
-		targetNodeID = lastTextNodeID
+		targetNodeIDs = []string{lastTextNodeID}
 	}
 	
-	if targetNodeID != "" {
-		if node, ok := workflow[targetNodeID].(map[string]interface{}); ok {
-			if inputs, ok := node["inputs"].(map[string]interface{}); ok {
-				inputs["text"] = prompt
-				logDebug("Injected prompt into node %s", targetNodeID)
+	if len(targetNodeIDs) > 0 {
+		for _, targetNodeID := range targetNodeIDs {
+			if node, ok := workflow[targetNodeID].(map[string]interface{}); ok {
+				if inputs, ok := node["inputs"].(map[string]interface{}); ok {
+					inputs["text"] = prompt
+					logDebug("Injected prompt into node %s", targetNodeID)
+				}
 			}
 		}
 	} else {
@@ -161,12 +309,24 @@ func (c *Client) GenerateImage(workflowJSON []byte, prompt string, progressChan
 		// Or just fail?
 	}
 
+	// A workflow without a negative node keeps running with the prompt alone
+	if negative != "" {
+		if node, ok := workflow[negativeNodeID].(map[string]interface{}); ok {
+			if inputs, ok := node["inputs"].(map[string]interface{}); ok {
+				inputs["text"] = negative
+				logDebug("Injected negative prompt into node %s", negativeNodeID)
+			}
+		} else {
+			logDebug("WARNING: No negative node for negative prompt %q", negative)
+		}
+	}
+
 	// 3. Connect to WebSocket
 	wsURL := strings.Replace(c.BaseURL, "http", "ws", 1) + "/ws?clientId=" + c.ClientID
 	logDebug("Connecting to WebSocket: %s", wsURL)
 	ws, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
 	if err != nil {
-		return "", fmt.Errorf("failed to connect to WebSocket: %w", err)
+		return "", info, fmt.Errorf("failed to connect to WebSocket: %w", err)
 	}
 	defer ws.Close()
 
@@ -177,26 +337,26 @@ func (c *Client) GenerateImage(workflowJSON []byte, prompt string, progressChan
 	}
 	payloadBytes, err := json.Marshal(payload)
 	if err != nil {
-		return "", fmt.Errorf("failed to marshal payload: %w", err)
+		return "", info, fmt.Errorf("failed to marshal payload: %w", err)
 	}
 
 	logDebug("Sending prompt to %s/prompt", c.BaseURL)
 	resp, err := http.Post(fmt.Sprintf("%s/prompt", c.BaseURL), "application/json", bytes.NewBuffer(payloadBytes))
 	if err != nil {
-		return "", fmt.Errorf("failed to send request to ComfyUI: %w", err)
+		return "", info, fmt.Errorf("failed to send request to ComfyUI: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != 200 {
 		body, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("ComfyUI returned error: %s", string(body))
+		return "", info, fmt.Errorf("ComfyUI returned error: %s", string(body))
 	}
 
 	var promptResp struct {
 		PromptID string `json:"prompt_id"`
 	}
 	if err := json.NewDecoder(resp.Body).Decode(&promptResp); err != nil {
-		return "", fmt.Errorf("failed to decode response: %w", err)
+		return "", info, fmt.Errorf("failed to decode response: %w", err)
 	}
 
 	promptID := promptResp.PromptID
@@ -208,11 +368,19 @@ func (c *Client) GenerateImage(workflowJSON []byte, prompt string, progressChan
 	logDebug("Total nodes in workflow: %d", totalNodes)
 	executedNodes := make(map[string]bool)
 	var generatedImages []string
+	outputDir, dirErr := c.outputDir()
+	if dirErr != nil {
+		logDebug("Can't save images in %s: %v", c.OutputDir, dirErr)
+		if progressChan != nil {
+			progressChan <- ProgressUpdate{Warning: fmt.Sprintf("Can't save images in %s (%v), saving them in the current directory", c.OutputDir, dirErr)}
+		}
+	}
 
 	if progressChan != nil {
 		progressChan <- ProgressUpdate{
 			Message:     "Queued...",
 			ElapsedTime: 0,
+			Seed:        info.Seed,
 		}
 	}
 
@@ -220,7 +388,7 @@ func (c *Client) GenerateImage(workflowJSON []byte, prompt string, progressChan
 	for {
 		_, message, err := ws.ReadMessage()
 		if err != nil {
-			return "", fmt.Errorf("websocket read error: %w", err)
+			return "", info, fmt.Errorf("websocket read error: %w", err)
 		}
 
 		// logDebug("Received WS message: %s", string(message))
@@ -267,11 +435,21 @@ func (c *Client) GenerateImage(workflowJSON []byte, prompt string, progressChan
 		case "executing":
 			node := data["node"]
 			if node == nil {
-				// Execution finished!
-				if len(generatedImages) > 0 {
-					return fmt.Sprintf("Image(s) generated: %s", strings.Join(generatedImages, ", ")), nil
+				// Execution finished! Only a seed that generated something is
+				// worth reusing with seed-last
+				if info.HasSeed {
+					seed := info.Seed
+					c.lastSeed.Store(&seed)
+				}
+				switch len(generatedImages) {
+				case 0:
+					// Workflows without a save node finish with nothing to show
+				case 1:
+					return "Image generated: " + generatedImages[0], info, nil
+				default:
+					return fmt.Sprintf("%d images generated:\n%s", len(generatedImages), strings.Join(generatedImages, "\n")), info, nil
 				}
-				return "Generation complete", nil
+				return "Generation complete", info, nil
 			} else {
 				// Check prompt_id if available, but be permissive
 				pid, _ := data["prompt_id"].(string)
@@ -347,7 +525,7 @@ func (c *Client) GenerateImage(workflowJSON []byte, prompt string, progressChan
 				if output, ok := data["output"].(map[string]interface{}); ok {
 					for _, nodeOutput := range output {
 						if images, ok := nodeOutput.([]interface{}); ok {
-							for _, img := range images {
+							for i, img := range images {
 								if imgMap, ok := img.(map[string]interface{}); ok {
 									filename, okName := imgMap["filename"].(string)
 									subfolder, _ := imgMap["subfolder"].(string)
@@ -355,12 +533,22 @@ func (c *Client) GenerateImage(workflowJSON []byte, prompt string, progressChan
 									
 									if okName {
 										// Download the image
-										downloadedFile, err := c.downloadImage(filename, subfolder, imgType)
+										downloadedFile, err := c.downloadImage(filename, subfolder, imgType, outputDir)
 										if err == nil {
 											generatedImages = append(generatedImages, downloadedFile)
+											info.Files = append(info.Files, downloadedFile)
 										} else {
 											generatedImages = append(generatedImages, fmt.Sprintf("%s (failed: %v)", filename, err))
 										}
+										// Report each image of a batch as it is saved
+										if progressChan != nil {
+											progressChan <- ProgressUpdate{
+												Message:     fmt.Sprintf("Saved image %d of %d", i+1, len(images)),
+												Value:       i + 1,
+												Max:         len(images),
+												ElapsedTime: time.Since(startTime),
+											}
+										}
 									}
 								}
 							}
@@ -371,14 +559,57 @@ func (c *Client) GenerateImage(workflowJSON []byte, prompt string, progressChan
 		case "execution_error":
 			pid, _ := data["prompt_id"].(string)
 			if pid == promptID {
-				return "", fmt.Errorf("execution error: %v", data["exception_message"])
+				execErr := &ExecutionError{}
+				switch v := data["node_id"].(type) {
+				case string:
+					execErr.NodeID = v
+				case float64:
+					execErr.NodeID = fmt.Sprintf("%.0f", v)
+				}
+				execErr.NodeType, _ = data["node_type"].(string)
+				execErr.ExceptionType, _ = data["exception_type"].(string)
+				execErr.Message, _ = data["exception_message"].(string)
+				logDebug("Execution error in node %s (%s): %s", execErr.NodeID, execErr.NodeType, execErr.Message)
+				return "", info, execErr
 			}
 		}
 	}
 }
 
-// downloadImage downloads an image from ComfyUI to the current directory
-func (c *Client) downloadImage(filename, subfolder, imgType string) (string, error) {
+// intInput reads a numeric workflow input, which is float64 when parsed from JSON
+func intInput(v interface{}) int {
+	switch n := v.(type) {
+	case float64:
+		return int(n)
+	case int:
+		return n
+	}
+	return 0
+}
+
+// outputDir returns the directory to save images in, creating OutputDir if
+// needed. When it can't be written to, the current directory ("") is used and
+// the error says why.
+func (c *Client) outputDir() (string, error) {
+	if c.OutputDir == "" {
+		return "", nil
+	}
+	if err := os.MkdirAll(c.OutputDir, 0755); err != nil {
+		return "", err
+	}
+	// A directory can exist without being writable
+	probe, err := os.CreateTemp(c.OutputDir, ".eko-write-test-*")
+	if err != nil {
+		return "", err
+	}
+	probe.Close()
+	os.Remove(probe.Name())
+	return c.OutputDir, nil
+}
+
+// downloadImage downloads an image from ComfyUI into dir, the current
+// directory when empty
+func (c *Client) downloadImage(filename, subfolder, imgType, dir string) (string, error) {
 	// Construct URL
 	params := url.Values{}
 	params.Add("filename", filename)
@@ -401,15 +632,18 @@ func (c *Client) downloadImage(filename, subfolder, imgType string) (string, err
 		return "", fmt.Errorf("status code %d", resp.StatusCode)
 	}
 	
-	// Save to current directory
-	// Generate new filename: eko-img-<timestamp>
+	// Generate new filename: <prefix>-<timestamp>
 	ext := filepath.Ext(filename)
 	if ext == "" {
 		ext = ".png"
 	}
+	prefix := c.FilePrefix
+	if prefix == "" {
+		prefix = DefaultFilePrefix
+	}
 	
 	timestamp := time.Now().Format("20060102-150405")
-	newFilename := fmt.Sprintf("eko-img-%s%s", timestamp, ext)
+	newFilename := filepath.Join(dir, fmt.Sprintf("%s-%s%s", prefix, timestamp, ext))
 	
 	// Handle collision
 	counter := 1
@@ -417,7 +651,7 @@ func (c *Client) downloadImage(filename, subfolder, imgType string) (string, err
 		if _, err := os.Stat(newFilename); os.IsNotExist(err) {
 			break
 		}
-		newFilename = fmt.Sprintf("eko-img-%s-%d%s", timestamp, counter, ext)
+		newFilename = filepath.Join(dir, fmt.Sprintf("%s-%s-%d%s", prefix, timestamp, counter, ext))
 		counter++
 	}
 
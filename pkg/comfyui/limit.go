@@ -0,0 +1,46 @@
+package comfyui
+
+import "sync/atomic"
+
+// jobLimit caps how many generations a Client has submitted to ComfyUI at
+// once. The rest wait their turn on this side instead of piling onto a
+// single-GPU server's queue.
+type jobLimit struct {
+	slots   chan struct{}
+	waiting atomic.Int32
+}
+
+// SetMaxJobs limits how many GenerateImage calls run at once; 0 removes the
+// limit. Generations already running finish under the old limit.
+func (c *Client) SetMaxJobs(n int) {
+	if n <= 0 {
+		c.limit.Store(nil)
+		return
+	}
+	if limit := c.limit.Load(); limit != nil && cap(limit.slots) == n {
+		return
+	}
+	c.limit.Store(&jobLimit{slots: make(chan struct{}, n)})
+}
+
+// Waiting returns how many generations are held back by the job limit
+func (c *Client) Waiting() int {
+	limit := c.limit.Load()
+	if limit == nil {
+		return 0
+	}
+	return int(limit.waiting.Load())
+}
+
+// acquire blocks until a generation may be submitted and returns the
+// function that frees its slot again
+func (c *Client) acquire() (release func()) {
+	limit := c.limit.Load()
+	if limit == nil {
+		return func() {}
+	}
+	limit.waiting.Add(1)
+	limit.slots <- struct{}{}
+	limit.waiting.Add(-1)
+	return func() { <-limit.slots }
+}
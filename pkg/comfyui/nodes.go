@@ -0,0 +1,73 @@
+package comfyui
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// NodeMap tells GenerateImage which workflow nodes play which part, for
+// workflows built from custom nodes its heuristics don't know. Each entry is
+// a node class type or a node ID. A role left empty uses the built-in class
+// types.
+type NodeMap struct {
+	Positive []string `json:"positive,omitempty"` // Text nodes that receive the prompt
+	Negative []string `json:"negative,omitempty"` // Text nodes that never receive it
+	Latent   []string `json:"latent,omitempty"`   // Nodes with the image width and height
+	Sampler  []string `json:"sampler,omitempty"`  // Nodes whose seed is randomized
+}
+
+// Built-in class types for each role
+var (
+	defaultTextNodes    = []string{"CLIPTextEncode", "ShowText", "PrimitiveString"}
+	defaultLatentNodes  = []string{"EmptyLatentImage", "EmptySD3LatentImage"}
+	defaultSamplerNodes = []string{"KSampler", "KSamplerAdvanced"}
+)
+
+// matchNode reports whether a node is named by entries, by ID or class type
+func matchNode(entries []string, nodeID, classType string) bool {
+	for _, entry := range entries {
+		if entry == nodeID || entry == classType {
+			return true
+		}
+	}
+	return false
+}
+
+// orDefault returns entries, or defaults when none are configured
+func orDefault(entries, defaults []string) []string {
+	if len(entries) == 0 {
+		return defaults
+	}
+	return entries
+}
+
+// ValidateWorkflow checks that data is a workflow in ComfyUI's API format
+// with a text node GenerateImage can put the prompt into
+func (c *Client) ValidateWorkflow(data []byte) error {
+	var workflow map[string]interface{}
+	if err := json.Unmarshal(data, &workflow); err != nil {
+		return fmt.Errorf("not valid JSON: %w", err)
+	}
+
+	textNodes := orDefault(c.Nodes.Positive, defaultTextNodes)
+	graph := false
+	for nodeID, node := range workflow {
+		nodeMap, ok := node.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		classType, ok := nodeMap["class_type"].(string)
+		if !ok {
+			continue
+		}
+		graph = true
+		if matchNode(textNodes, nodeID, classType) && !matchNode(c.Nodes.Negative, nodeID, classType) {
+			return nil
+		}
+	}
+	if !graph {
+		return fmt.Errorf("not a ComfyUI prompt graph; export it with \"Save (API Format)\"")
+	}
+	return fmt.Errorf("no %s node to put the prompt in", strings.Join(textNodes, " or "))
+}
@@ -0,0 +1,18 @@
+package comfyui
+
+import "strings"
+
+// negativeClause marks where the negative prompt starts in an image prompt
+const negativeClause = "--no"
+
+// SplitNegative splits "a castle --no fog, people" into the prompt and the
+// negative prompt after --no. The negative is empty without a --no clause.
+func SplitNegative(prompt string) (string, string) {
+	fields := strings.Fields(prompt)
+	for i, field := range fields {
+		if field == negativeClause {
+			return strings.Join(fields[:i], " "), strings.Join(fields[i+1:], " ")
+		}
+	}
+	return prompt, ""
+}
@@ -0,0 +1,52 @@
+package comfyui
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Sidecar is the metadata written next to a generated image as
+// <image name>.json, so the image documents how to reproduce it
+type Sidecar struct {
+	Prompt         string    `json:"prompt"`
+	NegativePrompt string    `json:"negative_prompt,omitempty"`
+	Seed           *int64    `json:"seed,omitempty"`
+	Width          int       `json:"width,omitempty"`
+	Height         int       `json:"height,omitempty"`
+	Workflow       string    `json:"workflow,omitempty"`
+	Created        time.Time `json:"created"`
+}
+
+// WriteSidecars writes a sidecar for every image info lists, recording the
+// workflow by file name
+func WriteSidecars(info ImageInfo, workflow string) error {
+	sidecar := Sidecar{
+		Prompt:         info.Prompt,
+		NegativePrompt: info.Negative,
+		Width:          info.Width,
+		Height:         info.Height,
+		Created:        time.Now(),
+	}
+	if info.HasSeed {
+		seed := info.Seed
+		sidecar.Seed = &seed
+	}
+	if workflow != "" {
+		sidecar.Workflow = filepath.Base(workflow)
+	}
+
+	data, err := json.MarshalIndent(sidecar, "", "  ")
+	if err != nil {
+		return err
+	}
+	for _, image := range info.Files {
+		path := strings.TrimSuffix(image, filepath.Ext(image)) + ".json"
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -1,12 +1,15 @@
 package config
 
 import (
+	"bytes"
 	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
 
 	"github.com/charmbracelet/bubbletea"
+	"github.com/thebug/lab/eko/v3/pkg/comfyui"
 	"github.com/thebug/lab/eko/v3/pkg/types"
 )
 
@@ -17,6 +20,8 @@ const (
 	DefaultURL        = "http://localhost:11434"
 	DefaultComfyUIURL = "http://localhost:8188"
 	DefaultWorkflowPath = "~/lab/model/workflow/default.json"
+	CacheDir            = "cache"
+	CrashLog            = "crash.log"
 )
 
 // Config represents the application configuration
@@ -25,15 +30,88 @@ type Config struct {
 	URL          string `json:"url"`
 	ComfyUIURL   string `json:"comfyui_url"`
 	WorkflowPath string `json:"img-workflow"`
+	// Inject the image prompt into every positive node, e.g. SDXL base + refiner
+	InjectAllPrompts bool `json:"inject_all_prompts"`
+	// Node class types or IDs for workflows with custom prompt, latent or sampler nodes
+	ComfyUINodes comfyui.NodeMap `json:"comfyui_nodes"`
+	SessionDir   string `json:"session_dir"`
+	Inline       bool   `json:"inline"`
+	RenderMath   bool   `json:"render_math"`
+	HideHeader   bool   `json:"hide_header"`
+	StopPattern  string `json:"stop_pattern"` // Regex that ends a response client-side
+	// End a response that repeats the same phrase this many times in a row; 0 never does
+	RepetitionLimit int `json:"repetition_limit"`
+	// Batch streamed tokens into one redraw per interval; 0 redraws on every token
+	RedrawIntervalMs int `json:"redraw_interval_ms"`
+	// Follow streamed output only while within this many lines of the bottom
+	AutoScrollThreshold int `json:"auto_scroll_threshold"`
+	// Reuse stored responses for identical requests; only temperature 0 requests unless CacheAll
+	ResponseCache bool `json:"response_cache"`
+	CacheAll      bool `json:"cache_all"`
+	// Format prompts client-side and use /api/generate for these models, base models without a
+	// server template, keyed by model name with the template name as value
+	ModelTemplates  map[string]string               `json:"model_templates"`
+	PromptTemplates map[string]types.PromptTemplate `json:"prompt_templates"`
+	// Command shorthands, e.g. "gpt": "config"; expanded before a : command runs
+	CommandAliases map[string]string `json:"command_aliases"`
+	// Wrapped around every user message sent, not the one displayed
+	PromptPrefix string `json:"prompt_prefix"`
+	PromptSuffix string `json:"prompt_suffix"`
+	// Models offered by :config when the list can't be fetched from Ollama
+	FallbackModels []string `json:"fallback_models"`
+	// Show streamed text a word at a time instead of as raw tokens
+	WordStreaming bool `json:"word_streaming"`
+	// Render only the last this many messages, for very long conversations; 0 renders all
+	RenderWindow int `json:"render_window"`
+	// Diagnostic log written by the ComfyUI client and shown by :log
+	DebugLog string `json:"debug_log"`
+	// Settings for individual ComfyUI workflows, keyed by file name or path
+	Workflows map[string]types.WorkflowSettings `json:"workflows"`
+	// Sampling seed sent to Ollama for reproducible replies; unset samples randomly
+	Seed *int64 `json:"seed"`
+	// Offer to run "ollama serve" when Ollama at a localhost URL can't be reached
+	StartOllama bool `json:"start_ollama"`
+	// Fixed ComfyUI client ID, so progress and history carry over restarts; empty picks a random one
+	ComfyUIClientID string `json:"comfyui_client_id"`
+	// Image generations submitted to ComfyUI at once, the rest wait in eko; 0 is unlimited
+	MaxImageJobs int `json:"max_image_jobs"`
+	// Write <image>.json with the prompt, seed, size and workflow next to each generated image
+	ImageSidecars bool `json:"image_sidecars"`
+	// Seconds allowed for connecting to Ollama and for short requests; streamed replies aren't cut off; 0 uses 30
+	TimeoutSeconds int `json:"timeout_seconds"`
+	// Sampling temperature sent to Ollama; unset uses the model's default
+	Temperature *float64 `json:"temperature"`
+	// Nucleus sampling cutoff sent to Ollama; unset uses the model's default
+	TopP *float64 `json:"top_p"`
+	// Context window in tokens sent to Ollama; 0 uses the model's default
+	NumCtx int `json:"num_ctx"`
+	// Instructions sent as a system message ahead of every conversation; empty sends none
+	SystemPrompt string `json:"system_prompt"`
+	// Directory :workflow lists workflows from; empty uses the directory of img-workflow
+	WorkflowDir string `json:"workflow_dir"`
+	// Directory generated images are saved to, created when missing; empty is the current directory
+	ImageOutputDir string `json:"image_output_dir"`
+	// File name prefix of generated images; empty uses "eko-img"
+	ImagePrefix string `json:"image_prefix"`
+	// Characters a collapsed message keeps in TLDR view; 0 uses 100
+	CollapseLength int `json:"collapse_length"`
+	// Deleting a prompt with d also deletes the reply that follows it
+	DeleteWithReply bool `json:"delete_with_reply"`
+	// Commands remembered for up/down in : mode; 0 keeps 100
+	HistorySize int `json:"history_size"`
+	// Ask thinking models to think (true) or not (false); unset leaves it to the model
+	Think *bool `json:"think"`
 }
 
 // Manager handles configuration operations
 type Manager struct {
-	configPath string
+	configPath string // Directory holding the config and state such as timings and the cache
+	configFile string
 }
 
-// NewManager creates a new configuration manager
-func NewManager() *Manager {
+// NewManager creates a new configuration manager. configFile selects an
+// alternate config file; empty uses config.json in the config directory.
+func NewManager(configFile string) *Manager {
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
 		// Fallback to current directory
@@ -41,32 +119,60 @@ func NewManager() *Manager {
 	}
 	
 	configPath := filepath.Join(homeDir, ConfigDir)
+	if configFile == "" {
+		configFile = filepath.Join(configPath, ConfigFile)
+	}
 	return &Manager{
 		configPath: configPath,
+		configFile: configFile,
 	}
 }
 
+// CheckConfigFile validates a config file given on the command line. The file
+// doesn't have to exist yet, since it is created on the first save, but its
+// directory does.
+func CheckConfigFile(path string) error {
+	info, err := os.Stat(path)
+	if err == nil {
+		if info.IsDir() {
+			return fmt.Errorf("%s is a directory", path)
+		}
+		return nil
+	}
+	if !os.IsNotExist(err) {
+		return err
+	}
+	if _, err := os.Stat(filepath.Dir(path)); err != nil {
+		return fmt.Errorf("directory of %s: %w", path, err)
+	}
+	return nil
+}
+
 // LoadConfig loads configuration from file
 func (m *Manager) LoadConfig() tea.Cmd {
 	return func() tea.Msg {
 		// Ensure config directory exists
 		if err := os.MkdirAll(m.configPath, 0755); err != nil {
-			return types.ConfigLoadedMsg{ModelName: "", Err: err}
+			return types.ConfigLoadedMsg{ModelName: "", Err: fmt.Errorf("can't create config directory: %w", err)}
 		}
 
-		configFilePath := filepath.Join(m.configPath, ConfigFile)
+		configFilePath := m.configFile
+		if info, err := os.Stat(configFilePath); err == nil && info.IsDir() {
+			return types.ConfigLoadedMsg{ModelName: "", URL: "", Err: fmt.Errorf("config %s is a directory", configFilePath)}
+		}
 		data, err := os.ReadFile(configFilePath)
 		if err != nil {
 			// If file doesn't exist, return default config
 			if os.IsNotExist(err) {
 				return types.ConfigLoadedMsg{ModelName: DefaultModel, URL: DefaultURL, Err: nil}
 			}
-			return types.ConfigLoadedMsg{ModelName: "", URL: "", Err: err}
+			// Anything else, e.g. permission denied, must not pass for a missing file
+			return types.ConfigLoadedMsg{ModelName: "", URL: "", Err: fmt.Errorf("can't read config: %w", err)}
 		}
 
 		var config Config
 		if err := json.Unmarshal(data, &config); err != nil {
-			return types.ConfigLoadedMsg{ModelName: "", URL: "", Err: err}
+			return types.ConfigLoadedMsg{ModelName: "", URL: "", Err: fmt.Errorf("invalid config %s: %w", configFilePath, err)}
 		}
 
 		// Use default model if not specified
@@ -98,32 +204,159 @@ func (m *Manager) LoadConfig() tea.Cmd {
 			config.WorkflowPath = DefaultWorkflowPath
 		}
 
-		return types.ConfigLoadedMsg{ModelName: config.Model, URL: config.URL, ComfyUIURL: config.ComfyUIURL, WorkflowPath: config.WorkflowPath, Err: nil}
+		return types.ConfigLoadedMsg{
+			ModelName:           config.Model,
+			URL:                 config.URL,
+			ComfyUIURL:          config.ComfyUIURL,
+			ComfyUIClientID:     config.ComfyUIClientID,
+			MaxImageJobs:        config.MaxImageJobs,
+			ImageSidecars:       config.ImageSidecars,
+			TimeoutSeconds:      config.TimeoutSeconds,
+			Temperature:         config.Temperature,
+			TopP:                config.TopP,
+			NumCtx:              config.NumCtx,
+			SystemPrompt:        config.SystemPrompt,
+			WorkflowDir:         ExpandPath(config.WorkflowDir),
+			ImageOutputDir:      ExpandPath(config.ImageOutputDir),
+			ImagePrefix:         config.ImagePrefix,
+			CollapseLength:      config.CollapseLength,
+			DeleteWithReply:     config.DeleteWithReply,
+			HistorySize:         config.HistorySize,
+			Think:               config.Think,
+			WorkflowPath:        config.WorkflowPath,
+			InjectAllPrompts:    config.InjectAllPrompts,
+			ComfyUINodes:        config.ComfyUINodes,
+			SessionDir:          ExpandPath(config.SessionDir),
+			Inline:              config.Inline,
+			RenderMath:          config.RenderMath,
+			HideHeader:          config.HideHeader,
+			StopPattern:         config.StopPattern,
+			RepetitionLimit:     config.RepetitionLimit,
+			RedrawIntervalMs:    config.RedrawIntervalMs,
+			AutoScrollThreshold: config.AutoScrollThreshold,
+			ResponseCache:       config.ResponseCache,
+			CacheAll:            config.CacheAll,
+			ModelTemplates:      config.ModelTemplates,
+			PromptTemplates:     config.PromptTemplates,
+			CommandAliases:      config.CommandAliases,
+			FallbackModels:      config.FallbackModels,
+			PromptPrefix:        config.PromptPrefix,
+			PromptSuffix:        config.PromptSuffix,
+			Workflows:           config.Workflows,
+			DebugLog:            ExpandPath(config.DebugLog),
+			WordStreaming:       config.WordStreaming,
+			RenderWindow:        config.RenderWindow,
+			Seed:                config.Seed,
+			StartOllama:         config.StartOllama,
+			Err:                 nil,
+		}
+	}
+}
+
+// ResponseCacheDir returns the directory cached responses are stored in
+func (m *Manager) ResponseCacheDir() string {
+	return filepath.Join(m.configPath, CacheDir)
+}
+
+// CrashLogPath returns the file panics caught by the UI are logged to
+func (m *Manager) CrashLogPath() string {
+	return filepath.Join(m.configPath, CrashLog)
+}
+
+// ExpandPath expands a leading ~/ to the user's home directory
+func ExpandPath(path string) string {
+	if strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, path[2:])
+		}
 	}
+	return path
 }
 
-// SaveConfig saves configuration to file
+// SaveConfig saves the selected model to the config file
 func (m *Manager) SaveConfig(modelName string) tea.Cmd {
+	return m.UpdateConfig(func(c *Config) {
+		c.Model = modelName
+	})
+}
+
+// UpdateConfig reads the config file, applies update and writes it back. Only
+// the fields update changed are written, so keys this version doesn't know
+// about survive, and nothing is written when update changed nothing. A config
+// that can't be read, understood or written comes back as a StatusMsg error
+// and is left as it was.
+func (m *Manager) UpdateConfig(update func(*Config)) tea.Cmd {
 	return func() tea.Msg {
-		// Ensure config directory exists
-		if err := os.MkdirAll(m.configPath, 0755); err != nil {
-			return nil
+		if err := m.updateConfig(update); err != nil {
+			return types.StatusMsg{Err: fmt.Errorf("couldn't save config: %w", err)}
 		}
+		return nil
+	}
+}
 
-		configFilePath := filepath.Join(m.configPath, ConfigFile)
-		config := Config{Model: modelName}
+// updateConfig does the work of UpdateConfig
+func (m *Manager) updateConfig(update func(*Config)) error {
+	// Ensure config directory exists
+	if err := os.MkdirAll(m.configPath, 0755); err != nil {
+		return fmt.Errorf("can't create config directory: %w", err)
+	}
 
-		data, err := json.MarshalIndent(config, "", "  ")
-		if err != nil {
-			return nil
+	configFilePath := m.configFile
+	var config Config
+	raw := map[string]json.RawMessage{}
+	if data, err := os.ReadFile(configFilePath); err == nil {
+		// Don't overwrite a file we couldn't understand
+		if err := json.Unmarshal(data, &raw); err != nil {
+			return fmt.Errorf("invalid config %s: %w", configFilePath, err)
 		}
-
-		if err := os.WriteFile(configFilePath, data, 0644); err != nil {
-			return nil
+		if err := json.Unmarshal(data, &config); err != nil {
+			return fmt.Errorf("invalid config %s: %w", configFilePath, err)
 		}
+	} else if !os.IsNotExist(err) {
+		// Nor one we couldn't read
+		return fmt.Errorf("can't read config: %w", err)
+	}
 
+	before, err := configFields(config)
+	if err != nil {
+		return err
+	}
+	update(&config)
+	after, err := configFields(config)
+	if err != nil {
+		return err
+	}
+
+	changed := false
+	for key, value := range after {
+		if !bytes.Equal(before[key], value) {
+			raw[key] = value
+			changed = true
+		}
+	}
+	if !changed {
 		return nil
 	}
+
+	data, err := json.MarshalIndent(raw, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(configFilePath, data, 0644); err != nil {
+		return fmt.Errorf("can't write config: %w", err)
+	}
+	return nil
+}
+
+// configFields encodes each Config field separately, keyed by its JSON name
+func configFields(config Config) (map[string]json.RawMessage, error) {
+	data, err := json.Marshal(config)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]json.RawMessage
+	err = json.Unmarshal(data, &fields)
+	return fields, err
 }
 
 // CreateDummyConfig creates a dummy configuration file for testing
@@ -133,7 +366,7 @@ func (m *Manager) CreateDummyConfig() error {
 		return err
 	}
 
-	configFilePath := filepath.Join(m.configPath, ConfigFile)
+	configFilePath := m.configFile
 	config := Config{Model: DefaultModel}
 
 	data, err := json.MarshalIndent(config, "", "  ")
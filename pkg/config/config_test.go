@@ -0,0 +1,57 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/thebug/lab/eko/v3/pkg/types"
+)
+
+func TestUpdateConfigKeepsUnknownKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(`{"model": "a", "future_key": [1, 2]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	m := NewManager(path)
+
+	if msg := m.UpdateConfig(func(c *Config) { c.NumCtx = 4096 })(); msg != nil {
+		t.Fatalf("UpdateConfig = %+v, want nothing to report", msg)
+	}
+	data, _ := os.ReadFile(path)
+	for _, want := range []string{`"model": "a"`, `"future_key": [`, `"num_ctx": 4096`} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("config lacks %s:\n%s", want, data)
+		}
+	}
+}
+
+func TestUpdateConfigReportsErrors(t *testing.T) {
+	dir := t.TempDir()
+	invalid := filepath.Join(dir, "invalid.json")
+	if err := os.WriteFile(invalid, []byte(`{"model": `), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		path string
+		want string
+	}{
+		{"invalid JSON", invalid, "invalid config"},
+		{"unreadable", dir, "can't read config"},
+		{"unwritable", filepath.Join(dir, "missing", "config.json"), "can't write config"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg, ok := NewManager(tt.path).UpdateConfig(func(c *Config) { c.NumCtx = 4096 })().(types.StatusMsg)
+			if !ok || msg.Err == nil || !strings.Contains(msg.Err.Error(), tt.want) {
+				t.Errorf("UpdateConfig = %+v, want an error containing %q", msg, tt.want)
+			}
+		})
+	}
+	// The file that couldn't be understood is left alone
+	if data, _ := os.ReadFile(invalid); string(data) != `{"model": ` {
+		t.Errorf("invalid config rewritten: %s", data)
+	}
+}
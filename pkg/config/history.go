@@ -0,0 +1,47 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/charmbracelet/bubbletea"
+	"github.com/thebug/lab/eko/v3/pkg/types"
+)
+
+// HistoryFile holds the : command history, one command per line, oldest first
+const HistoryFile = "history"
+
+// LoadHistory loads the command history, returning nothing if none exists
+func (m *Manager) LoadHistory() []string {
+	data, err := os.ReadFile(filepath.Join(m.configPath, HistoryFile))
+	if err != nil {
+		return nil
+	}
+	var history []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if line != "" {
+			history = append(history, line)
+		}
+	}
+	return history
+}
+
+// SaveHistory persists the command history. A history that can't be written
+// comes back as a StatusMsg error.
+func (m *Manager) SaveHistory(history []string) tea.Cmd {
+	// Join now so the UI can keep recording while this is written
+	snapshot := strings.Join(history, "\n") + "\n"
+
+	return func() tea.Msg {
+		if err := os.MkdirAll(m.configPath, 0755); err != nil {
+			return types.StatusMsg{Err: fmt.Errorf("couldn't save command history: %w", err)}
+		}
+
+		if err := os.WriteFile(filepath.Join(m.configPath, HistoryFile), []byte(snapshot), 0644); err != nil {
+			return types.StatusMsg{Err: fmt.Errorf("couldn't save command history: %w", err)}
+		}
+		return nil
+	}
+}
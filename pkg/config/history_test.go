@@ -0,0 +1,41 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/thebug/lab/eko/v3/pkg/types"
+)
+
+func TestSaveHistory(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	m := NewManager("")
+
+	if msg := m.SaveHistory([]string{"set temperature 0.2", "tabnew"})(); msg != nil {
+		t.Fatalf("SaveHistory = %+v, want nothing to report", msg)
+	}
+	if got, want := m.LoadHistory(), []string{"set temperature 0.2", "tabnew"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("LoadHistory = %q, want %q", got, want)
+	}
+}
+
+func TestSaveHistoryReportsErrors(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	// A file where the config directory should be
+	configPath := filepath.Join(home, ConfigDir)
+	if err := os.MkdirAll(filepath.Dir(configPath), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(configPath, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	msg, ok := NewManager("").SaveHistory([]string{"tabnew"})().(types.StatusMsg)
+	if !ok || msg.Err == nil || !strings.Contains(msg.Err.Error(), "couldn't save command history") {
+		t.Errorf("SaveHistory = %+v, want a command history error", msg)
+	}
+}
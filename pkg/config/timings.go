@@ -0,0 +1,85 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/charmbracelet/bubbletea"
+	"github.com/thebug/lab/eko/v3/pkg/types"
+)
+
+const (
+	TimingsFile = "timings.json"
+	// timingAlpha weights the newest sample in the moving average
+	timingAlpha = 0.3
+)
+
+// Timings holds an exponential moving average of generation durations in
+// seconds, keyed by workflow
+type Timings map[string]float64
+
+// Estimate returns the expected duration for a workflow, if any history exists
+func (t Timings) Estimate(key string) (time.Duration, bool) {
+	seconds, ok := t[key]
+	if !ok || seconds <= 0 {
+		return 0, false
+	}
+	return time.Duration(seconds * float64(time.Second)), true
+}
+
+// Record folds a completed generation's duration into the workflow's average
+func (t Timings) Record(key string, d time.Duration) {
+	sample := d.Seconds()
+	if prev, ok := t[key]; ok && prev > 0 {
+		t[key] = timingAlpha*sample + (1-timingAlpha)*prev
+	} else {
+		t[key] = sample
+	}
+}
+
+// LoadTimings loads the generation timing history, returning an empty set if none exists
+func (m *Manager) LoadTimings() Timings {
+	timings := make(Timings)
+	data, err := os.ReadFile(filepath.Join(m.configPath, TimingsFile))
+	if err != nil {
+		return timings
+	}
+	if err := json.Unmarshal(data, &timings); err != nil {
+		return make(Timings)
+	}
+	return timings
+}
+
+// SaveTimings persists the generation timing history. A history that can't be
+// written comes back as a StatusMsg error.
+func (m *Manager) SaveTimings(timings Timings) tea.Cmd {
+	// Copy so the UI can keep recording while this is written
+	snapshot := make(Timings, len(timings))
+	for k, v := range timings {
+		snapshot[k] = v
+	}
+
+	return func() tea.Msg {
+		if err := m.saveTimings(snapshot); err != nil {
+			return types.StatusMsg{Err: fmt.Errorf("couldn't save generation timings: %w", err)}
+		}
+		return nil
+	}
+}
+
+// saveTimings does the work of SaveTimings
+func (m *Manager) saveTimings(timings Timings) error {
+	if err := os.MkdirAll(m.configPath, 0755); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(timings, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(filepath.Join(m.configPath, TimingsFile), data, 0644)
+}
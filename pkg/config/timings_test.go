@@ -0,0 +1,39 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/thebug/lab/eko/v3/pkg/types"
+)
+
+func TestSaveTimings(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	m := NewManager("")
+
+	timings := make(Timings)
+	timings.Record("flux.json", 20*time.Second)
+	if msg := m.SaveTimings(timings)(); msg != nil {
+		t.Fatalf("SaveTimings = %+v, want nothing to report", msg)
+	}
+	if got, ok := m.LoadTimings().Estimate("flux.json"); !ok || got != 20*time.Second {
+		t.Errorf("Estimate = %v, %v, want 20s", got, ok)
+	}
+}
+
+func TestSaveTimingsReportsErrors(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	// A directory where the timings file should be
+	if err := os.MkdirAll(filepath.Join(home, ConfigDir, TimingsFile), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	msg, ok := NewManager("").SaveTimings(Timings{"flux.json": 20})().(types.StatusMsg)
+	if !ok || msg.Err == nil || !strings.Contains(msg.Err.Error(), "couldn't save generation timings") {
+		t.Errorf("SaveTimings = %+v, want a timings error", msg)
+	}
+}
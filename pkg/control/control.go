@@ -0,0 +1,120 @@
+// Package control lets other programs drive a running eko over a local
+// socket, e.g. an editor sending its selection as a prompt. It has no
+// authentication: anyone who can connect can send prompts and read replies.
+package control
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/thebug/lab/eko/v3/pkg/types"
+)
+
+// replyTimeout bounds how long a request waits for the UI to answer
+const replyTimeout = 10 * time.Second
+
+// maxRequest caps the size of one request line, which holds the whole prompt
+const maxRequest = 1 << 20
+
+// request is one line sent by a client, e.g.
+// {"id": 1, "method": "send", "params": {"prompt": "hello"}}
+type request struct {
+	ID     json.RawMessage `json:"id,omitempty"`
+	Method string          `json:"method"`
+	Params struct {
+		Prompt string `json:"prompt"`
+	} `json:"params"`
+}
+
+// response answers a request with the same ID
+type response struct {
+	ID     json.RawMessage `json:"id,omitempty"`
+	Result interface{}     `json:"result,omitempty"`
+	Error  string          `json:"error,omitempty"`
+}
+
+// Listen opens the control socket at addr: a host:port listens on TCP,
+// anything else is the path of a Unix socket. Since there is no
+// authentication, TCP only listens on a loopback host, never on all
+// interfaces. A stale socket file left by a crashed run is replaced.
+func Listen(addr string) (net.Listener, error) {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		if !loopback(host) {
+			return nil, fmt.Errorf("%s isn't a loopback address; the control socket has no authentication, so listen on 127.0.0.1 or localhost", addr)
+		}
+		return net.Listen("tcp", addr)
+	}
+	if info, err := os.Stat(addr); err == nil && info.Mode()&os.ModeSocket != 0 {
+		if conn, err := net.Dial("unix", addr); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("%s is in use by another eko", addr)
+		}
+		os.Remove(addr)
+	}
+	return net.Listen("unix", addr)
+}
+
+// loopback reports whether host only reaches this machine. An empty host
+// means every interface.
+func loopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// Serve answers connections on l until it is closed, handing each request
+// to the UI through send
+func Serve(l net.Listener, send func(tea.Msg)) {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		go serveConn(conn, send)
+	}
+}
+
+// serveConn answers newline-delimited JSON requests on one connection
+func serveConn(conn net.Conn, send func(tea.Msg)) {
+	defer conn.Close()
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 64*1024), maxRequest)
+	encoder := json.NewEncoder(conn)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		var req request
+		if err := json.Unmarshal([]byte(line), &req); err != nil {
+			encoder.Encode(response{Error: "invalid request: " + err.Error()})
+			continue
+		}
+
+		resp := response{ID: req.ID}
+		reply := make(chan types.ControlReply, 1)
+		send(types.ControlRequest{Method: req.Method, Prompt: req.Params.Prompt, Reply: reply})
+		select {
+		case r := <-reply:
+			resp.Result = r.Result
+			if r.Err != nil {
+				resp.Error = r.Err.Error()
+			}
+		case <-time.After(replyTimeout):
+			resp.Error = "eko did not answer"
+		}
+		if err := encoder.Encode(resp); err != nil {
+			return
+		}
+	}
+}
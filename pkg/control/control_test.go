@@ -0,0 +1,26 @@
+package control
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestListenRefusesNonLoopbackHosts(t *testing.T) {
+	for _, addr := range []string{":0", "0.0.0.0:0", "[::]:0", "192.168.1.10:0", "example.com:0"} {
+		if l, err := Listen(addr); err == nil {
+			l.Close()
+			t.Errorf("Listen(%q) succeeded, want it refused", addr)
+		}
+	}
+}
+
+func TestListenLoopbackAndUnix(t *testing.T) {
+	for _, addr := range []string{"127.0.0.1:0", "localhost:0", filepath.Join(t.TempDir(), "eko.sock")} {
+		l, err := Listen(addr)
+		if err != nil {
+			t.Errorf("Listen(%q): %v", addr, err)
+			continue
+		}
+		l.Close()
+	}
+}
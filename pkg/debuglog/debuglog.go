@@ -0,0 +1,91 @@
+// Package debuglog writes the diagnostic log shared by eko's packages, which
+// :log shows inside the app
+package debuglog
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultPath is used until SetPath picks another file
+const DefaultPath = "eko-debug.log"
+
+// tailBytes caps how much of the end of the log Tail reads
+const tailBytes = 64 * 1024
+
+var (
+	mu   sync.Mutex
+	path = DefaultPath
+)
+
+// SetPath directs the log to another file; empty restores the default
+func SetPath(p string) {
+	mu.Lock()
+	defer mu.Unlock()
+	if p == "" {
+		p = DefaultPath
+	}
+	path = p
+}
+
+// Path returns the file the log is written to
+func Path() string {
+	mu.Lock()
+	defer mu.Unlock()
+	return path
+}
+
+// Printf appends a timestamped line to the log. Failures are ignored since
+// the log is only a diagnostic aid.
+func Printf(format string, v ...interface{}) {
+	mu.Lock()
+	defer mu.Unlock()
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	timestamp := time.Now().Format("15:04:05.000")
+	fmt.Fprintf(f, timestamp+" "+format+"\n", v...)
+}
+
+// Tail returns the last lines of the log, reading at most its final 64KB. A
+// log that doesn't exist yet has no lines.
+func Tail() ([]string, error) {
+	f, err := os.Open(Path())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	start := info.Size() - tailBytes
+	if start < 0 {
+		start = 0
+	}
+	data := make([]byte, info.Size()-start)
+	if _, err := f.ReadAt(data, start); err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	text := strings.TrimRight(string(data), "\n")
+	if text == "" {
+		return nil, nil
+	}
+	lines := strings.Split(text, "\n")
+	if start > 0 {
+		// The first line was cut off by the read window
+		lines = lines[1:]
+	}
+	return lines, nil
+}
@@ -0,0 +1,34 @@
+package ollama
+
+import (
+	"context"
+	"time"
+
+	"github.com/thebug/lab/eko/v3/pkg/types"
+)
+
+// MeasureChat runs one streamed chat request and times it. The reply itself
+// is discarded; only latency and generation speed are reported. Cancelling
+// ctx aborts the request and MeasureChat returns ctx.Err().
+func (c *Client) MeasureChat(ctx context.Context, model string, messages []types.Message) (types.BenchRun, error) {
+	var run types.BenchRun
+
+	start := time.Now()
+	err := c.chatStream(ctx, model, messages, func(response Response) {
+		if run.FirstToken == 0 && response.Message.Content != "" {
+			run.FirstToken = time.Since(start)
+		}
+		if response.Done {
+			run.Tokens = response.EvalCount
+			if response.EvalDuration > 0 {
+				run.TokensPerSec = float64(response.EvalCount) / time.Duration(response.EvalDuration).Seconds()
+			}
+		}
+	})
+	if err != nil {
+		return run, err
+	}
+
+	run.Total = time.Since(start)
+	return run, nil
+}
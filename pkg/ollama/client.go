@@ -2,10 +2,16 @@ package ollama
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/charmbracelet/bubbletea"
@@ -16,21 +22,112 @@ import (
 type Client struct {
 	BaseURL string
 	Client  *http.Client
+	Options map[string]interface{} // Generation options sent with every chat request
+	Version string                 // Server version reported by /api/version, empty until detected
+	Think   *bool                  // Sent as the request's think field; nil leaves it to the server
+
+	timeout *atomic.Int64 // Nanoseconds bounding connecting and short requests, see SetTimeout
+}
+
+// DefaultTimeout is the connection timeout used until SetTimeout is called
+const DefaultTimeout = 30 * time.Second
+
+// fieldMinVersions lists top-level request fields that older Ollama servers
+// reject or silently ignore, with the first version supporting each
+var fieldMinVersions = map[string]string{
+	"think": "0.9.0",
 }
 
+// optionMinVersions is fieldMinVersions for the entries of the request's
+// options, keyed by Ollama option name. Every option :set and the config
+// send is understood by all servers eko supports, so none are listed yet.
+var optionMinVersions = map[string]string{}
+
 // Request represents an Ollama API request
 type Request struct {
 	Model    string         `json:"model"`
 	Messages []types.Message `json:"messages"`
 	Stream   bool           `json:"stream"`
+	Options  map[string]interface{} `json:"options,omitempty"`
+	Think    *bool          `json:"think,omitempty"`
 }
 
 // Response represents an Ollama API response
 type Response struct {
-	Model     string        `json:"model"`
-	Message   types.Message `json:"message"`
-	Done      bool          `json:"done"`
-	CreatedAt string        `json:"created_at"`
+	Model      string        `json:"model"`
+	Message    types.Message `json:"message"`
+	Done       bool          `json:"done"`
+	CreatedAt  string        `json:"created_at"`
+	DoneReason string        `json:"done_reason,omitempty"` // "length" when the reply hit num_predict
+	Stats
+}
+
+// Stats are the timing statistics Ollama reports on the final chunk of a
+// response. Durations are in nanoseconds.
+type Stats struct {
+	TotalDuration      int64 `json:"total_duration,omitempty"`
+	LoadDuration       int64 `json:"load_duration,omitempty"`
+	PromptEvalCount    int   `json:"prompt_eval_count,omitempty"`
+	PromptEvalDuration int64 `json:"prompt_eval_duration,omitempty"`
+	EvalCount          int   `json:"eval_count,omitempty"`
+	EvalDuration       int64 `json:"eval_duration,omitempty"`
+}
+
+// Meta converts the statistics for display
+func (s Stats) Meta() types.ResponseMeta {
+	return types.ResponseMeta{
+		TotalDuration:      time.Duration(s.TotalDuration),
+		LoadDuration:       time.Duration(s.LoadDuration),
+		PromptEvalCount:    s.PromptEvalCount,
+		PromptEvalDuration: time.Duration(s.PromptEvalDuration),
+		EvalCount:          s.EvalCount,
+		EvalDuration:       time.Duration(s.EvalDuration),
+	}
+}
+
+// latency times a stream as the user experiences it, from sending the request
+// to the first token and to the end of the reply
+type latency struct {
+	start      time.Time
+	firstToken time.Duration
+}
+
+func startLatency() *latency {
+	return &latency{start: time.Now()}
+}
+
+// token notes the arrival of a piece of the reply
+func (l *latency) token(token string) {
+	if l.firstToken == 0 && token != "" {
+		l.firstToken = time.Since(l.start)
+	}
+}
+
+// meta adds the measured latencies to the server's statistics
+func (l *latency) meta(meta types.ResponseMeta) types.ResponseMeta {
+	meta.FirstToken = l.firstToken
+	meta.Elapsed = time.Since(l.start)
+	return meta
+}
+
+// GenerateRequest represents an Ollama raw completion request
+type GenerateRequest struct {
+	Model   string                 `json:"model"`
+	Prompt  string                 `json:"prompt"`
+	Raw     bool                   `json:"raw"`
+	Stream  bool                   `json:"stream"`
+	Options map[string]interface{} `json:"options,omitempty"`
+	Think   *bool                  `json:"think,omitempty"`
+}
+
+// GenerateResponse represents an Ollama raw completion response
+type GenerateResponse struct {
+	Model      string `json:"model"`
+	Response   string `json:"response"`
+	Done       bool   `json:"done"`
+	CreatedAt  string `json:"created_at"`
+	DoneReason string `json:"done_reason,omitempty"`
+	Stats
 }
 
 // ModelInfo represents a model from Ollama
@@ -50,19 +147,110 @@ type ModelInfo struct {
 
 // NewClient creates a new Ollama client
 func NewClient() *Client {
-	return &Client{
-		BaseURL: "http://localhost:11434",
-		Client:  &http.Client{Timeout: 30 * time.Second},
+	c := &Client{BaseURL: "http://localhost:11434", timeout: new(atomic.Int64)}
+	c.timeout.Store(int64(DefaultTimeout))
+	c.Client = &http.Client{Transport: &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			dialer := net.Dialer{Timeout: c.Timeout(), KeepAlive: 30 * time.Second}
+			return dialer.DialContext(ctx, network, addr)
+		},
+		TLSHandshakeTimeout: 10 * time.Second,
+		MaxIdleConns:        10,
+		IdleConnTimeout:     90 * time.Second,
+	}}
+	return c
+}
+
+// SetTimeout limits how long connecting to Ollama may take, and bounds the
+// short model and version requests as a whole. Streamed replies are not
+// bounded, since generating can take much longer; cancel their context instead.
+func (c *Client) SetTimeout(d time.Duration) {
+	if d <= 0 {
+		d = DefaultTimeout
 	}
+	c.timeout.Store(int64(d))
+}
+
+// Timeout returns the timeout set by SetTimeout
+func (c *Client) Timeout() time.Duration {
+	return time.Duration(c.timeout.Load())
+}
+
+// WithOptions returns a copy of the client that sends options instead of its
+// own, for a one-off request with different settings
+func (c *Client) WithOptions(options map[string]interface{}) *Client {
+	clone := *c
+	clone.Options = options
+	return &clone
+}
+
+// post sends a JSON request that is aborted when ctx is cancelled
+func (c *Client) post(ctx context.Context, path string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.BaseURL+path, bytes.NewBuffer(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return c.Client.Do(req)
+}
+
+// get fetches path, giving up after the client's timeout
+func (c *Client) get(path string) (*http.Response, context.CancelFunc, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.Timeout())
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.BaseURL+path, nil)
+	if err != nil {
+		cancel()
+		return nil, nil, err
+	}
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		cancel()
+		return nil, nil, err
+	}
+	return resp, cancel, nil
+}
+
+// send delivers msg unless ctx is cancelled first, so a stream whose reader
+// has gone away (e.g. on quit) exits instead of blocking on a full channel
+func send(ctx context.Context, msgChan chan<- tea.Msg, msg tea.Msg) bool {
+	select {
+	case msgChan <- msg:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// streamFailed reports err as the failure of the stream messageID, on msgChan
+// and as the message returned. Once ctx is cancelled the error is ctx.Err()
+// and nothing is sent, since the UI has stopped waiting for the stream.
+func streamFailed(ctx context.Context, msgChan chan<- tea.Msg, messageID string, err error) tea.Msg {
+	if ctx.Err() != nil {
+		return streamStopped(ctx, messageID)
+	}
+	msg := types.StreamErrorMsg{ID: messageID, Error: err.Error(), Err: err}
+	send(ctx, msgChan, msg)
+	return msg
+}
+
+// streamStopped is what a stream whose observers stopped it returns: ctx.Err()
+// when ctx was cancelled, nothing when an observer asked to stop
+func streamStopped(ctx context.Context, messageID string) tea.Msg {
+	if err := ctx.Err(); err != nil {
+		return types.StreamErrorMsg{ID: messageID, Error: err.Error(), Err: err}
+	}
+	return nil
 }
 
 // FetchModels fetches available models from Ollama
 func (c *Client) FetchModels() tea.Cmd {
 	return func() tea.Msg {
-		resp, err := c.Client.Get(c.BaseURL + "/api/tags")
+		resp, cancel, err := c.get("/api/tags")
 		if err != nil {
 			return types.ModelsLoadedMsg{Models: nil, Err: err}
 		}
+		defer cancel()
 		defer resp.Body.Close()
 
 		if resp.StatusCode != http.StatusOK {
@@ -86,22 +274,100 @@ func (c *Client) FetchModels() tea.Cmd {
 	}
 }
 
-// StreamChat streams a chat response from Ollama
-func (c *Client) StreamChat(model string, messages []types.Message, onToken func(string, bool)) error {
+// FetchVersion detects the Ollama server version
+func (c *Client) FetchVersion() tea.Cmd {
+	return func() tea.Msg {
+		resp, cancel, err := c.get("/api/version")
+		if err != nil {
+			return types.VersionLoadedMsg{Err: err}
+		}
+		defer cancel()
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return types.VersionLoadedMsg{Err: fmt.Errorf("ollama API returned status %d", resp.StatusCode)}
+		}
+
+		var response struct {
+			Version string `json:"version"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+			return types.VersionLoadedMsg{Err: err}
+		}
+
+		return types.VersionLoadedMsg{Version: response.Version}
+	}
+}
+
+// UnsupportedFeatures returns the configured options that need a newer server
+// than the detected version. Nothing is reported until the version is known.
+func (c *Client) UnsupportedFeatures() []string {
+	if c.Version == "" {
+		return nil
+	}
+
+	var unsupported []string
+	check := func(name string, table map[string]string) {
+		if minVersion, ok := table[name]; ok && compareVersions(c.Version, minVersion) < 0 {
+			unsupported = append(unsupported, fmt.Sprintf("%s (needs %s)", name, minVersion))
+		}
+	}
+	if c.Think != nil {
+		check("think", fieldMinVersions)
+	}
+	for option := range c.Options {
+		check(option, optionMinVersions)
+	}
+	sort.Strings(unsupported)
+	return unsupported
+}
+
+// compareVersions compares dotted version strings numerically, ignoring any
+// pre-release suffix such as "-rc1"
+func compareVersions(a, b string) int {
+	pa := strings.Split(strings.SplitN(a, "-", 2)[0], ".")
+	pb := strings.Split(strings.SplitN(b, "-", 2)[0], ".")
+	for i := 0; i < len(pa) || i < len(pb); i++ {
+		var na, nb int
+		if i < len(pa) {
+			na, _ = strconv.Atoi(pa[i])
+		}
+		if i < len(pb) {
+			nb, _ = strconv.Atoi(pb[i])
+		}
+		if na != nb {
+			if na < nb {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+// chatStream sends a streamed chat request and calls onResponse with each
+// chunk Ollama sends, up to the last one. Cancelling ctx aborts the request
+// and chatStream returns ctx.Err().
+func (c *Client) chatStream(ctx context.Context, model string, messages []types.Message, onResponse func(Response)) error {
 	req := Request{
 		Model:    model,
 		Messages: messages,
 		Stream:   true,
+		Options:  c.Options,
+		Think:    c.Think,
 	}
 
 	jsonData, err := json.Marshal(req)
 	if err != nil {
-		return fmt.Errorf("failed to marshal request: %v", err)
+		return fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	resp, err := c.Client.Post(c.BaseURL+"/api/chat", "application/json", bytes.NewBuffer(jsonData))
+	resp, err := c.post(ctx, "/api/chat", jsonData)
 	if err != nil {
-		return fmt.Errorf("failed to make request: %v", err)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return fmt.Errorf("failed to make request: %w", err)
 	}
 	defer resp.Body.Close()
 
@@ -113,13 +379,16 @@ func (c *Client) StreamChat(model string, messages []types.Message, onToken func
 	for {
 		var response Response
 		if err := decoder.Decode(&response); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
 			if err == io.EOF {
 				break
 			}
-			return fmt.Errorf("failed to decode response: %v", err)
+			return fmt.Errorf("failed to decode response: %w", err)
 		}
 
-		onToken(response.Message.Content, response.Done)
+		onResponse(response)
 
 		if response.Done {
 			break
@@ -129,51 +398,135 @@ func (c *Client) StreamChat(model string, messages []types.Message, onToken func
 	return nil
 }
 
+// StreamChat streams a chat response from Ollama, calling onToken for each
+// chunk. Cancelling ctx aborts the request and StreamChat returns ctx.Err().
+func (c *Client) StreamChat(ctx context.Context, model string, messages []types.Message, onToken func(string, bool)) error {
+	return c.chatStream(ctx, model, messages, func(response Response) {
+		// Chunks with a role but no content, e.g. around tool calls, carry nothing
+		if response.Message.Content != "" || response.Done {
+			onToken(response.Message.Content, response.Done)
+		}
+	})
+}
+
+// Chat streams a chat response from Ollama and returns it once complete.
+// Cancelling ctx aborts the request and Chat returns ctx.Err().
+func (c *Client) Chat(ctx context.Context, model string, messages []types.Message) (string, error) {
+	var reply strings.Builder
+	err := c.StreamChat(ctx, model, messages, func(token string, done bool) {
+		reply.WriteString(token)
+	})
+	return reply.String(), err
+}
+
 // StreamChatRealtime streams a chat response from Ollama with real-time updates via channel
-func (c *Client) StreamChatRealtime(model string, messages []types.Message, msgChan chan<- tea.Msg, messageID string) tea.Cmd {
+// Cancelling ctx aborts the request and stops the stream without blocking on msgChan.
+// The observers follow the reply alongside the channel. A failed stream's
+// StreamErrorMsg is also what the command returns, with ctx.Err() once ctx is
+// cancelled, like StreamChat.
+func (c *Client) StreamChatRealtime(ctx context.Context, model string, messages []types.Message, msgChan chan<- tea.Msg, messageID string, observers ...StreamObserver) tea.Cmd {
+	observers = append([]StreamObserver{channelObserver{ctx, msgChan, messageID}}, observers...)
 	return func() tea.Msg {
 		req := Request{
 			Model:    model,
 			Messages: messages,
 			Stream:   true,
+			Options:  c.Options,
+			Think:    c.Think,
 		}
 
 		jsonData, err := json.Marshal(req)
 		if err != nil {
-			msgChan <- types.StreamErrorMsg{ID: messageID, Error: fmt.Sprintf("failed to marshal request: %v", err)}
-			return nil
+			return streamFailed(ctx, msgChan, messageID, fmt.Errorf("failed to marshal request: %w", err))
 		}
 
-		resp, err := c.Client.Post(c.BaseURL+"/api/chat", "application/json", bytes.NewBuffer(jsonData))
+		timing := startLatency()
+		resp, err := c.post(ctx, "/api/chat", jsonData)
 		if err != nil {
-			msgChan <- types.StreamErrorMsg{ID: messageID, Error: fmt.Sprintf("failed to make request: %v", err)}
-			return nil
+			return streamFailed(ctx, msgChan, messageID, fmt.Errorf("failed to make request: %w", err))
 		}
 		defer resp.Body.Close()
 
 		if resp.StatusCode != http.StatusOK {
-			msgChan <- types.StreamErrorMsg{ID: messageID, Error: fmt.Sprintf("ollama API returned status %d", resp.StatusCode)}
-			return nil
+			return streamFailed(ctx, msgChan, messageID, fmt.Errorf("ollama API returned status %d", resp.StatusCode))
 		}
 
 		decoder := json.NewDecoder(resp.Body)
 		for {
 			var response Response
 			if err := decoder.Decode(&response); err != nil {
-				if err == io.EOF {
+				if err == io.EOF && ctx.Err() == nil {
+					break
+				}
+				return streamFailed(ctx, msgChan, messageID, fmt.Errorf("failed to decode response: %w", err))
+			}
+
+			// Hand the token to the UI and the other observers immediately
+			timing.token(response.Message.Content)
+			if !notifyToken(observers, response.Message.Content) {
+				return streamStopped(ctx, messageID)
+			}
+
+			if response.Done {
+				notifyDone(observers, response.DoneReason, timing.meta(response.Meta()))
+				break
+			}
+		}
+
+		return nil
+	}
+}
+
+
+// StreamGenerateRealtime streams a raw completion from /api/generate with real-time updates via channel.
+// The messages are formatted into a prompt with the model's chat template, whose
+// stop tokens are added to the options. It cancels and fails the way
+// StreamChatRealtime does.
+func (c *Client) StreamGenerateRealtime(ctx context.Context, model string, tmpl types.PromptTemplate, messages []types.Message, msgChan chan<- tea.Msg, messageID string, observers ...StreamObserver) tea.Cmd {
+	observers = append([]StreamObserver{channelObserver{ctx, msgChan, messageID}}, observers...)
+	return func() tea.Msg {
+		req := GenerateRequest{
+			Model:   model,
+			Prompt:  FormatPrompt(tmpl, messages),
+			Raw:     true,
+			Stream:  true,
+			Options: stopOptions(c.Options, tmpl.Stop),
+			Think:   c.Think,
+		}
+
+		jsonData, err := json.Marshal(req)
+		if err != nil {
+			return streamFailed(ctx, msgChan, messageID, fmt.Errorf("failed to marshal request: %w", err))
+		}
+
+		timing := startLatency()
+		resp, err := c.post(ctx, "/api/generate", jsonData)
+		if err != nil {
+			return streamFailed(ctx, msgChan, messageID, fmt.Errorf("failed to make request: %w", err))
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return streamFailed(ctx, msgChan, messageID, fmt.Errorf("ollama API returned status %d", resp.StatusCode))
+		}
+
+		decoder := json.NewDecoder(resp.Body)
+		for {
+			var response GenerateResponse
+			if err := decoder.Decode(&response); err != nil {
+				if err == io.EOF && ctx.Err() == nil {
 					break
 				}
-				msgChan <- types.StreamErrorMsg{ID: messageID, Error: fmt.Sprintf("failed to decode response: %v", err)}
-				return nil
+				return streamFailed(ctx, msgChan, messageID, fmt.Errorf("failed to decode response: %w", err))
 			}
 
-			// Send token immediately via channel
-			if response.Message.Content != "" {
-				msgChan <- types.TokenMsg{ID: messageID, Token: response.Message.Content}
+			timing.token(response.Response)
+			if !notifyToken(observers, response.Response) {
+				return streamStopped(ctx, messageID)
 			}
 
 			if response.Done {
-				msgChan <- types.GenerationDoneMsg{ID: messageID}
+				notifyDone(observers, response.DoneReason, timing.meta(response.Meta()))
 				break
 			}
 		}
@@ -0,0 +1,271 @@
+package ollama
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/thebug/lab/eko/v3/pkg/types"
+)
+
+// chatServer answers /api/chat with one streamed chunk per entry of contents
+// and a final done chunk
+func chatServer(t *testing.T, contents ...string) *Client {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/chat" {
+			http.NotFound(w, r)
+			return
+		}
+		for _, content := range contents {
+			fmt.Fprintf(w, `{"message":{"role":"assistant","content":%q},"done":false}`+"\n", content)
+			w.(http.Flusher).Flush()
+		}
+		fmt.Fprintln(w, `{"message":{"role":"assistant","content":""},"done":true,"done_reason":"stop"}`)
+	}))
+	t.Cleanup(srv.Close)
+	c := NewClient()
+	c.BaseURL = srv.URL
+	return c
+}
+
+// Models send chunks with a role but no content, e.g. around tool calls
+var interleaved = []string{"", "Hello", "", "", " wor", "ld", ""}
+
+func TestStreamChatSkipsEmptyChunks(t *testing.T) {
+	c := chatServer(t, interleaved...)
+
+	var tokens []string
+	dones := 0
+	err := c.StreamChat(context.Background(), "m", nil, func(token string, done bool) {
+		if done {
+			dones++
+			return
+		}
+		tokens = append(tokens, token)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(tokens, "|"); got != "Hello| wor|ld" {
+		t.Errorf("tokens = %q, want %q", got, "Hello| wor|ld")
+	}
+	if dones != 1 {
+		t.Errorf("done reported %d times, want once", dones)
+	}
+}
+
+func TestStreamChatRealtimeSkipsEmptyChunks(t *testing.T) {
+	c := chatServer(t, interleaved...)
+	msgChan := make(chan tea.Msg, 100)
+
+	c.StreamChatRealtime(context.Background(), "m", nil, msgChan, "ba")()
+	close(msgChan)
+
+	var reply strings.Builder
+	var done []types.GenerationDoneMsg
+	for msg := range msgChan {
+		switch msg := msg.(type) {
+		case types.TokenMsg:
+			if msg.Token == "" {
+				t.Error("empty token sent")
+			}
+			reply.WriteString(msg.Token)
+		case types.GenerationDoneMsg:
+			done = append(done, msg)
+		default:
+			t.Errorf("unexpected %T: %+v", msg, msg)
+		}
+	}
+	if reply.String() != "Hello world" {
+		t.Errorf("reply = %q, want %q", reply.String(), "Hello world")
+	}
+	if len(done) != 1 || done[0].ID != "ba" || done[0].Reason != "stop" {
+		t.Errorf("done = %+v, want one stop for ba", done)
+	}
+}
+
+func TestStreamRealtimeCancelReturnsContextError(t *testing.T) {
+	started := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"message":{"role":"assistant","content":"Hi"},"response":"Hi","done":false}`)
+		w.(http.Flusher).Flush()
+		close(started)
+		<-r.Context().Done()
+	}))
+	t.Cleanup(func() {
+		srv.CloseClientConnections()
+		srv.Close()
+	})
+	c := NewClient()
+	c.BaseURL = srv.URL
+
+	for name, stream := range map[string]func(context.Context, chan<- tea.Msg) tea.Cmd{
+		"chat": func(ctx context.Context, msgChan chan<- tea.Msg) tea.Cmd {
+			return c.StreamChatRealtime(ctx, "m", nil, msgChan, "ba")
+		},
+		"generate": func(ctx context.Context, msgChan chan<- tea.Msg) tea.Cmd {
+			return c.StreamGenerateRealtime(ctx, "m", BuiltinTemplates["chatml"], nil, msgChan, "ba")
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			started = make(chan struct{})
+			ctx, cancel := context.WithCancel(context.Background())
+			msgChan := make(chan tea.Msg, 10)
+			result := make(chan tea.Msg, 1)
+			go func() { result <- stream(ctx, msgChan)() }()
+
+			<-started
+			cancel()
+			msg, ok := (<-result).(types.StreamErrorMsg)
+			if !ok || !errors.Is(msg.Err, context.Canceled) {
+				t.Fatalf("returned %+v, want context.Canceled", msg)
+			}
+			close(msgChan)
+			for msg := range msgChan {
+				if _, failed := msg.(types.StreamErrorMsg); failed {
+					t.Errorf("cancelled stream reported an error to the UI: %+v", msg)
+				}
+			}
+		})
+	}
+}
+
+func TestChatCancelReturnsContextError(t *testing.T) {
+	started := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"message":{"role":"assistant","content":"Hi"},"done":false}`)
+		w.(http.Flusher).Flush()
+		close(started)
+		<-r.Context().Done()
+	}))
+	t.Cleanup(func() {
+		srv.CloseClientConnections()
+		srv.Close()
+	})
+	c := NewClient()
+	c.BaseURL = srv.URL
+
+	ctx, cancel := context.WithCancel(context.Background())
+	result := make(chan error, 1)
+	go func() {
+		_, err := c.Chat(ctx, "m", nil)
+		result <- err
+	}()
+
+	<-started
+	cancel()
+	if err := <-result; err != context.Canceled {
+		t.Errorf("Chat returned %v, want context.Canceled", err)
+	}
+}
+
+func TestChatCollectsReply(t *testing.T) {
+	reply, err := chatServer(t, interleaved...).Chat(context.Background(), "m", nil)
+	if err != nil || reply != "Hello world" {
+		t.Errorf("Chat = %q, %v, want %q", reply, err, "Hello world")
+	}
+}
+
+func TestMeasureChatTimesReply(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"message":{"role":"assistant","content":"Hi"},"done":false}`)
+		fmt.Fprintln(w, `{"message":{"role":"assistant","content":""},"done":true,"eval_count":20,"eval_duration":2000000000}`)
+	}))
+	t.Cleanup(srv.Close)
+	c := NewClient()
+	c.BaseURL = srv.URL
+
+	run, err := c.MeasureChat(context.Background(), "m", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if run.Tokens != 20 || run.TokensPerSec != 10 {
+		t.Errorf("run = %d tokens at %v/s, want 20 at 10/s", run.Tokens, run.TokensPerSec)
+	}
+	if run.FirstToken <= 0 || run.Total < run.FirstToken {
+		t.Errorf("first token %v, total %v", run.FirstToken, run.Total)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := c.MeasureChat(ctx, "m", nil); err != context.Canceled {
+		t.Errorf("cancelled MeasureChat returned %v, want context.Canceled", err)
+	}
+}
+
+func TestStreamChatRealtimeWrapsErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"message":`)
+	}))
+	t.Cleanup(srv.Close)
+	c := NewClient()
+	c.BaseURL = srv.URL
+	msgChan := make(chan tea.Msg, 10)
+
+	returned, ok := c.StreamChatRealtime(context.Background(), "m", nil, msgChan, "ba")().(types.StreamErrorMsg)
+	if !ok || !errors.Is(returned.Err, io.ErrUnexpectedEOF) {
+		t.Fatalf("returned %+v, want the decode error wrapped", returned)
+	}
+	if sent := <-msgChan; sent != returned {
+		t.Errorf("sent %+v, want %+v", sent, returned)
+	}
+}
+
+func TestUnsupportedFeatures(t *testing.T) {
+	yes := true
+	tests := []struct {
+		name    string
+		version string
+		think   *bool
+		options map[string]interface{}
+		want    string
+	}{
+		{"unknown version", "", &yes, nil, ""},
+		{"think on an old server", "0.6.8", &yes, nil, "think (needs 0.9.0)"},
+		{"think on a new server", "0.9.0", &yes, nil, ""},
+		{"think unset", "0.6.8", nil, nil, ""},
+		// An option of that name isn't the think field
+		{"think as an option", "0.6.8", nil, map[string]interface{}{"think": true}, ""},
+		{"sampling options", "0.1.0", nil, map[string]interface{}{"temperature": 0.7, "num_ctx": 8192}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewClient()
+			c.Version, c.Think, c.Options = tt.version, tt.think, tt.options
+			if got := strings.Join(c.UnsupportedFeatures(), ", "); got != tt.want {
+				t.Errorf("UnsupportedFeatures = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestThinkSentAsRequestField(t *testing.T) {
+	var body map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&body)
+		fmt.Fprintln(w, `{"message":{"role":"assistant","content":"ok"},"done":true}`)
+	}))
+	t.Cleanup(srv.Close)
+	c := NewClient()
+	c.BaseURL = srv.URL
+	no := false
+	c.Think = &no
+	c.Options = map[string]interface{}{"temperature": 0.5}
+
+	if _, err := c.Chat(context.Background(), "m", nil); err != nil {
+		t.Fatal(err)
+	}
+	if think, ok := body["think"]; !ok || think != false {
+		t.Errorf("think = %v, %v, want false at the top level", think, ok)
+	}
+	if _, ok := body["options"].(map[string]interface{})["think"]; ok {
+		t.Error("think sent as an option")
+	}
+}
@@ -0,0 +1,56 @@
+package ollama
+
+import (
+	"context"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/thebug/lab/eko/v3/pkg/types"
+)
+
+// StreamObserver follows a reply as it streams in. Observers see the same
+// tokens in order, so the UI, a tee file and a stats collector can each take
+// what they need from one stream.
+type StreamObserver interface {
+	// Token receives each non-empty piece of the reply. Returning false stops
+	// the stream, e.g. once its consumer has gone away.
+	Token(token string) bool
+	// Done is called once after the last token of a completed reply
+	Done(reason string, meta types.ResponseMeta)
+}
+
+// channelObserver forwards a stream to the UI as TokenMsg and
+// GenerationDoneMsg for the message with the given ID
+type channelObserver struct {
+	ctx       context.Context
+	msgChan   chan<- tea.Msg
+	messageID string
+}
+
+func (o channelObserver) Token(token string) bool {
+	return send(o.ctx, o.msgChan, types.TokenMsg{ID: o.messageID, Token: token})
+}
+
+func (o channelObserver) Done(reason string, meta types.ResponseMeta) {
+	send(o.ctx, o.msgChan, types.GenerationDoneMsg{ID: o.messageID, Reason: reason, Meta: meta})
+}
+
+// notifyToken passes token to every observer, reporting false as soon as one
+// asks to stop. Empty tokens carry nothing and are skipped.
+func notifyToken(observers []StreamObserver, token string) bool {
+	if token == "" {
+		return true
+	}
+	for _, o := range observers {
+		if !o.Token(token) {
+			return false
+		}
+	}
+	return true
+}
+
+// notifyDone tells every observer the reply is complete
+func notifyDone(observers []StreamObserver, reason string, meta types.ResponseMeta) {
+	for _, o := range observers {
+		o.Done(reason, meta)
+	}
+}
@@ -0,0 +1,36 @@
+package ollama
+
+// SetOption sets a generation option sent with every chat request, or removes
+// it when value is nil. The options map is replaced rather than changed, since
+// regenerations and saved sessions may share it. With no options left the
+// request omits them, so the model's own defaults apply.
+func (c *Client) SetOption(name string, value interface{}) {
+	options := make(map[string]interface{}, len(c.Options)+1)
+	for k, v := range c.Options {
+		options[k] = v
+	}
+	if value != nil {
+		options[name] = value
+	} else {
+		delete(options, name)
+	}
+	if len(options) == 0 {
+		options = nil
+	}
+	c.Options = options
+}
+
+// SetTemperature sets the sampling temperature; higher is more random
+func (c *Client) SetTemperature(temperature float64) {
+	c.SetOption("temperature", temperature)
+}
+
+// SetTopP sets nucleus sampling's cumulative probability cutoff
+func (c *Client) SetTopP(topP float64) {
+	c.SetOption("top_p", topP)
+}
+
+// SetNumCtx sets the context window size in tokens
+func (c *Client) SetNumCtx(numCtx int) {
+	c.SetOption("num_ctx", numCtx)
+}
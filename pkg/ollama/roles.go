@@ -0,0 +1,27 @@
+package ollama
+
+import "github.com/thebug/lab/eko/v3/pkg/types"
+
+// IsStandardRole reports whether Ollama understands role natively
+func IsStandardRole(role string) bool {
+	switch role {
+	case "system", "user", "assistant", "tool":
+		return true
+	}
+	return false
+}
+
+// NormalizeRoles maps custom roles (personas in roleplay or multi-agent chats)
+// onto Ollama's roles. They are sent as user turns with the role name prefixed
+// to the content so the model still knows who is speaking.
+func NormalizeRoles(messages []types.Message) []types.Message {
+	normalized := make([]types.Message, len(messages))
+	for i, msg := range messages {
+		if !IsStandardRole(msg.Role) {
+			msg.Content = msg.Role + ": " + msg.Content
+			msg.Role = "user"
+		}
+		normalized[i] = msg
+	}
+	return normalized
+}
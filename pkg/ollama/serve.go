@@ -0,0 +1,119 @@
+package ollama
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/thebug/lab/eko/v3/pkg/types"
+)
+
+// serveTimeout is how long a started server gets to answer
+const serveTimeout = 15 * time.Second
+
+// serveOutputLimit caps the server output kept while it starts; the end of it
+// is what explains a failure
+const serveOutputLimit = 64 * 1024
+
+// CanServe reports whether Ollama could be started for this client: the
+// ollama binary is installed and the URL points at this machine
+func (c *Client) CanServe() bool {
+	if _, err := exec.LookPath("ollama"); err != nil {
+		return false
+	}
+	u, err := url.Parse(c.BaseURL)
+	if err != nil {
+		return false
+	}
+	switch u.Hostname() {
+	case "localhost", "127.0.0.1", "::1":
+		return true
+	}
+	return false
+}
+
+// Serve runs "ollama serve" in the background and waits until it answers. The
+// server keeps running after it is up. If it exits or doesn't answer in time,
+// the error comes back together with its output. Once it answers, its output
+// is dropped, since nothing reads it any more.
+func (c *Client) Serve() tea.Cmd {
+	return func() tea.Msg {
+		var output syncBuffer
+		cmd := exec.Command("ollama", "serve")
+		cmd.Stdout = &output
+		cmd.Stderr = &output
+		// Listen where the client will connect, which may not be the default port
+		if u, err := url.Parse(c.BaseURL); err == nil && u.Port() != "" {
+			cmd.Env = append(os.Environ(), "OLLAMA_HOST="+net.JoinHostPort(u.Hostname(), u.Port()))
+		}
+		if err := cmd.Start(); err != nil {
+			return types.OllamaStartedMsg{Err: err}
+		}
+
+		exited := make(chan error, 1)
+		go func() { exited <- cmd.Wait() }()
+
+		deadline := time.After(serveTimeout)
+		tick := time.NewTicker(250 * time.Millisecond)
+		defer tick.Stop()
+		for {
+			select {
+			case err := <-exited:
+				if err == nil {
+					err = fmt.Errorf("exited")
+				}
+				return types.OllamaStartedMsg{Output: output.String(), Err: fmt.Errorf("ollama serve: %w", err)}
+			case <-deadline:
+				cmd.Process.Kill()
+				return types.OllamaStartedMsg{Output: output.String(), Err: fmt.Errorf("ollama serve didn't answer within %s", serveTimeout)}
+			case <-tick.C:
+				if resp, cancel, err := c.get("/api/version"); err == nil {
+					resp.Body.Close()
+					cancel()
+					output.Discard()
+					return types.OllamaStartedMsg{}
+				}
+			}
+		}
+	}
+}
+
+// syncBuffer collects the end of a subprocess's output while it is still being
+// written, keeping at most serveOutputLimit bytes
+type syncBuffer struct {
+	mu      sync.Mutex
+	buf     []byte
+	discard bool
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.discard {
+		return len(p), nil
+	}
+	b.buf = append(b.buf, p...)
+	if over := len(b.buf) - serveOutputLimit; over > 0 {
+		b.buf = append(b.buf[:0], b.buf[over:]...)
+	}
+	return len(p), nil
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return string(b.buf)
+}
+
+// Discard drops the output collected so far and everything written after
+func (b *syncBuffer) Discard() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.discard = true
+	b.buf = nil
+}
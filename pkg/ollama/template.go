@@ -0,0 +1,122 @@
+package ollama
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/thebug/lab/eko/v3/pkg/types"
+)
+
+// TemplatePlaceholder marks where message content goes in a prompt template
+const TemplatePlaceholder = "{{content}}"
+
+// BuiltinTemplates are the chat formats selectable by name in the config
+var BuiltinTemplates = map[string]types.PromptTemplate{
+	"chatml": {
+		System:    "<|im_start|>system\n{{content}}<|im_end|>\n",
+		User:      "<|im_start|>user\n{{content}}<|im_end|>\n",
+		Assistant: "<|im_start|>assistant\n{{content}}<|im_end|>\n",
+		Stop:      []string{"<|im_end|>", "<|im_start|>"},
+	},
+	"alpaca": {
+		System:    "{{content}}\n\n",
+		User:      "### Instruction:\n{{content}}\n\n",
+		Assistant: "### Response:\n{{content}}\n\n",
+		Stop:      []string{"### Instruction:", "### Response:"},
+	},
+	"llama2": {
+		System:    "<<SYS>>\n{{content}}\n<</SYS>>\n\n",
+		User:      "[INST] {{content}} [/INST]",
+		Assistant: " {{content}} </s>",
+		Stop:      []string{"</s>", "[INST]"},
+	},
+	"mistral": {
+		System:    "{{content}}\n\n",
+		User:      "[INST] {{content}} [/INST]",
+		Assistant: "{{content}}</s>",
+		Stop:      []string{"</s>", "[INST]"},
+	},
+	"vicuna": {
+		System:    "{{content}}\n\n",
+		User:      "USER: {{content}}\n",
+		Assistant: "ASSISTANT: {{content}}</s>\n",
+		Stop:      []string{"</s>", "USER:"},
+	},
+}
+
+// ModelTemplate returns the name of the prompt template configured for model
+// in byModel, matching the full name first and then the name without its tag,
+// so "mybase" also covers "mybase:7b". Empty means the model is prompted
+// through /api/chat.
+func ModelTemplate(model string, byModel map[string]string) string {
+	if name, ok := byModel[model]; ok {
+		return name
+	}
+	if base, _, tagged := strings.Cut(model, ":"); tagged {
+		return byModel[base]
+	}
+	return ""
+}
+
+// LookupTemplate resolves a template name against user-defined templates first,
+// then the built-in ones
+func LookupTemplate(name string, custom map[string]types.PromptTemplate) (types.PromptTemplate, error) {
+	if tmpl, ok := custom[name]; ok {
+		return tmpl, nil
+	}
+	if tmpl, ok := BuiltinTemplates[strings.ToLower(name)]; ok {
+		return tmpl, nil
+	}
+	return types.PromptTemplate{}, fmt.Errorf("unknown prompt template %q", name)
+}
+
+// stopOptions returns options with the stop tokens added to any it already
+// has. options itself is left alone, since it is shared by every request.
+func stopOptions(options map[string]interface{}, stop []string) map[string]interface{} {
+	if len(stop) == 0 {
+		return options
+	}
+	merged := make(map[string]interface{}, len(options)+1)
+	for name, value := range options {
+		merged[name] = value
+	}
+	// Options read back from a saved session hold []interface{}
+	var all []interface{}
+	switch existing := options["stop"].(type) {
+	case []string:
+		for _, s := range existing {
+			all = append(all, s)
+		}
+	case []interface{}:
+		all = append(all, existing...)
+	}
+	for _, s := range stop {
+		all = append(all, s)
+	}
+	merged["stop"] = all
+	return merged
+}
+
+// FormatPrompt renders a conversation into a single raw prompt, ending with the
+// opening of an assistant turn so the model continues from there
+func FormatPrompt(tmpl types.PromptTemplate, messages []types.Message) string {
+	var b strings.Builder
+	for _, msg := range messages {
+		var part string
+		switch msg.Role {
+		case "system":
+			part = tmpl.System
+		case "assistant":
+			part = tmpl.Assistant
+		default:
+			part = tmpl.User
+		}
+		b.WriteString(strings.ReplaceAll(part, TemplatePlaceholder, msg.Content))
+	}
+
+	// Open the assistant turn: everything in the template before the placeholder
+	if idx := strings.Index(tmpl.Assistant, TemplatePlaceholder); idx >= 0 {
+		b.WriteString(tmpl.Assistant[:idx])
+	}
+	return b.String()
+}
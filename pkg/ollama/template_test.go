@@ -0,0 +1,73 @@
+package ollama
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/thebug/lab/eko/v3/pkg/types"
+)
+
+func TestModelTemplate(t *testing.T) {
+	byModel := map[string]string{"mybase": "mine", "llama2-base:7b": "llama2"}
+	tests := []struct {
+		model string
+		want  string
+	}{
+		{"mybase", "mine"},
+		{"mybase:13b", "mine"},
+		{"llama2-base:7b", "llama2"},
+		{"llama2-base:13b", ""},
+		{"qwen3:1.7b", ""},
+	}
+	for _, tt := range tests {
+		if got := ModelTemplate(tt.model, byModel); got != tt.want {
+			t.Errorf("ModelTemplate(%q) = %q, want %q", tt.model, got, tt.want)
+		}
+	}
+}
+
+func TestStopOptions(t *testing.T) {
+	options := map[string]interface{}{"temperature": 0.2, "stop": []interface{}{"END"}}
+
+	got := stopOptions(options, []string{"</s>"})
+	if want := []interface{}{"END", "</s>"}; !reflect.DeepEqual(got["stop"], want) {
+		t.Errorf("stop = %v, want %v", got["stop"], want)
+	}
+	if got["temperature"] != 0.2 {
+		t.Errorf("temperature = %v", got["temperature"])
+	}
+	// The client's options are shared by every request
+	if want := []interface{}{"END"}; !reflect.DeepEqual(options["stop"], want) {
+		t.Errorf("options changed: stop = %v", options["stop"])
+	}
+}
+
+func TestStreamGenerateSendsTemplateStops(t *testing.T) {
+	var req GenerateRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&req)
+		fmt.Fprintln(w, `{"response":"hi","done":true}`)
+	}))
+	t.Cleanup(srv.Close)
+	c := NewClient()
+	c.BaseURL = srv.URL
+	messages := []types.Message{{Role: "user", Content: "hello"}}
+
+	c.StreamGenerateRealtime(context.Background(), "m", BuiltinTemplates["chatml"], messages, make(chan tea.Msg, 10), "ba")()
+
+	if want := "<|im_start|>user\nhello<|im_end|>\n<|im_start|>assistant\n"; req.Prompt != want {
+		t.Errorf("prompt = %q, want %q", req.Prompt, want)
+	}
+	if want := []interface{}{"<|im_end|>", "<|im_start|>"}; !reflect.DeepEqual(req.Options["stop"], want) {
+		t.Errorf("stop = %v, want %v", req.Options["stop"], want)
+	}
+	if c.Options != nil {
+		t.Errorf("client options changed: %v", c.Options)
+	}
+}
@@ -0,0 +1,176 @@
+package session
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/thebug/lab/eko/v3/pkg/types"
+)
+
+// Save writes a session to disk as indented JSON, creating its directory,
+// e.g. a session_dir that doesn't exist yet, if needed
+func Save(path string, s types.Session) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal session: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create session directory: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write session: %w", err)
+	}
+
+	return nil
+}
+
+// SafeName turns a name such as a model name into something usable in a file
+// name. Path separators, colons and characters Windows reserves become "-",
+// and spaces become "_", so "qwen3:1.7b" becomes "qwen3-1.7b".
+func SafeName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		switch {
+		case r < ' ' || strings.ContainsRune(`/\:*?"<>|`, r):
+			return '-'
+		case r == ' ':
+			return '_'
+		}
+		return r
+	}, strings.TrimSpace(name))
+	// Leading dots would hide the file or walk up the directory tree
+	name = strings.TrimLeft(name, ".")
+	if name == "" {
+		return "unnamed"
+	}
+	return name
+}
+
+// Load reads a session from disk. Older saves that only contain a bare
+// message array are still accepted and come back without options.
+func Load(path string) (types.Session, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return types.Session{}, err
+	}
+
+	var s types.Session
+	if err := json.Unmarshal(data, &s); err != nil {
+		// Fall back to the legacy message-only format
+		var messages []types.Message
+		if err := json.Unmarshal(data, &messages); err != nil {
+			return types.Session{}, fmt.Errorf("failed to parse session: %w", err)
+		}
+		s = types.Session{Messages: messages}
+	}
+
+	if err := validate(s.Messages); err != nil {
+		return types.Session{}, fmt.Errorf("invalid session: %w", err)
+	}
+	return s, nil
+}
+
+// validate rejects messages that can't be shown or addressed: each needs a
+// role, and an ID no other message uses, since yank and quote go by ID
+func validate(messages []types.Message) error {
+	seen := make(map[string]bool, len(messages))
+	for i, msg := range messages {
+		switch {
+		case msg.Role == "":
+			return fmt.Errorf("message %d has no role", i+1)
+		case msg.ID == "":
+			return fmt.Errorf("message %d has no ID", i+1)
+		case seen[msg.ID]:
+			return fmt.Errorf("message %d reuses ID %q", i+1, msg.ID)
+		}
+		seen[msg.ID] = true
+	}
+	return nil
+}
+
+// Index caches the tags of every session file in a directory. It is built
+// lazily on the first lookup and only re-reads files whose mtime changed.
+type Index struct {
+	dir     string
+	mu      sync.Mutex
+	entries map[string]indexEntry
+}
+
+type indexEntry struct {
+	modTime time.Time
+	tags    []string
+}
+
+// NewIndex creates an empty index over dir
+func NewIndex(dir string) *Index {
+	return &Index{dir: dir, entries: make(map[string]indexEntry)}
+}
+
+// Find returns the session files carrying tag, compared case-insensitively
+func (idx *Index) Find(tag string) ([]string, error) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	if err := idx.refresh(); err != nil {
+		return nil, err
+	}
+
+	var matches []string
+	for path, entry := range idx.entries {
+		for _, t := range entry.tags {
+			if strings.EqualFold(t, tag) {
+				matches = append(matches, path)
+				break
+			}
+		}
+	}
+	sort.Strings(matches)
+	return matches, nil
+}
+
+// refresh brings the cached entries in line with the files on disk
+func (idx *Index) refresh() error {
+	dir := idx.dir
+	if dir == "" {
+		dir = "."
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return err
+	}
+
+	seen := make(map[string]bool, len(files))
+	for _, path := range files {
+		seen[path] = true
+		info, err := os.Stat(path)
+		if err != nil || info.IsDir() {
+			continue
+		}
+		if entry, ok := idx.entries[path]; ok && entry.modTime.Equal(info.ModTime()) {
+			continue
+		}
+
+		// Files that aren't sessions are indexed without tags so they are skipped cheaply next time
+		s, err := Load(path)
+		if err != nil {
+			s = types.Session{}
+		}
+		idx.entries[path] = indexEntry{modTime: info.ModTime(), tags: s.Tags}
+	}
+
+	// Forget files that have been removed
+	for path := range idx.entries {
+		if !seen[path] {
+			delete(idx.entries, path)
+		}
+	}
+
+	return nil
+}
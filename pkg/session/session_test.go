@@ -0,0 +1,51 @@
+package session
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/thebug/lab/eko/v3/pkg/types"
+)
+
+func TestSafeName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"qwen3:1.7b", "qwen3-1.7b"},
+		{"library/llama3:8b", "library-llama3-8b"},
+		{`hf.co\user/model:Q4_K_M`, "hf.co-user-model-Q4_K_M"},
+		{"my chat notes", "my_chat_notes"},
+		{"  padded name  ", "padded_name"},
+		{"what? <really>|*", "what-_-really---"},
+		{"tab\there", "tab-here"},
+		{"../../etc/passwd", "-..-etc-passwd"},
+		{".hidden", "hidden"},
+		{"", "unnamed"},
+		{"...", "unnamed"},
+		{"日本語 モデル", "日本語_モデル"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SafeName(tt.name); got != tt.want {
+				t.Errorf("SafeName(%q) = %q, want %q", tt.name, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSaveCreatesDirectory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sessions", "nested", "chat.json")
+	want := types.Session{Messages: []types.Message{{ID: "aa", Role: "user", Content: "hi"}}}
+
+	if err := Save(path, want); err != nil {
+		t.Fatal(err)
+	}
+	got, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Messages) != 1 || got.Messages[0].Content != "hi" {
+		t.Errorf("loaded %+v, want the saved session", got)
+	}
+}
@@ -8,11 +8,51 @@ import (
 
 // Message represents a chat message
 type Message struct {
-	ID          string    `json:"id"`
-	Role        string    `json:"role"`
-	Content     string    `json:"content"`
-	IsCollapsed bool      `json:"is_collapsed"`
-	Timestamp   time.Time `json:"timestamp"`
+	ID          string        `json:"id"`
+	Role        string        `json:"role"`
+	Content     string        `json:"content"`
+	IsCollapsed bool          `json:"is_collapsed"`
+	Timestamp   time.Time     `json:"timestamp"`
+	Cached      bool          `json:"cached,omitempty"`  // Served from the response cache
+	Starred     bool          `json:"starred,omitempty"` // Marked with * to find again later
+	Council     string        `json:"council,omitempty"` // Council member model that wrote this reply
+	Model       string        `json:"model,omitempty"`   // Model that generated this reply
+	Image       bool          `json:"image,omitempty"`   // Image prompt or generated image, never sent to the chat model
+	Seed        *int64        `json:"seed,omitempty"`    // Sampling seed the reply was generated with, if fixed
+	Meta        *ResponseMeta `json:"meta,omitempty"`    // Ollama's timing statistics, shown with :meta
+}
+
+// SessionOptions captures the settings a conversation was held with so a
+// saved session can be replayed the same way
+type SessionOptions struct {
+	Model        string                 `json:"model,omitempty"`
+	SystemPrompt string                 `json:"system_prompt,omitempty"`
+	Options      map[string]interface{} `json:"options,omitempty"`
+}
+
+// Session is the on-disk format written by :save and read by :load
+type Session struct {
+	Options    *SessionOptions `json:"options,omitempty"`
+	Tags       []string        `json:"tags,omitempty"`
+	Scratchpad string          `json:"scratchpad,omitempty"`
+	Messages   []Message       `json:"messages"`
+}
+
+// PromptTemplate formats chat turns for the raw /api/generate endpoint. Each
+// role field wraps one role's message, with {{content}} marking where it goes.
+// Stop lists the tokens that end the model's turn, sent as the stop option so
+// the model doesn't carry on writing the next turn itself.
+type PromptTemplate struct {
+	System    string   `json:"system"`
+	User      string   `json:"user"`
+	Assistant string   `json:"assistant"`
+	Stop      []string `json:"stop,omitempty"`
+}
+
+// WorkflowSettings are defaults for generating with one ComfyUI workflow
+type WorkflowSettings struct {
+	// NegativePrompt goes into the negative node unless the prompt has a --no clause
+	NegativePrompt string `json:"negative_prompt"`
 }
 
 // State represents the current application state
@@ -26,6 +66,18 @@ const (
 	YankCodeState  // New state for yanking code blocks
 	ConfigState
 	SaveState
+	SessionListState  // Picking a session from :find results
+	ScratchpadState   // Editing the scratchpad notes
+	DiffState         // Viewing a :diff overlay
+	ComfyURLState     // Entering the ComfyUI URL after it couldn't be reached
+	BenchState        // Viewing a :bench report
+	LoadConfirmState  // Confirming a load that would replace unsaved messages
+	LogState          // Viewing the :log debug log
+	StartOllamaState  // Confirming starting Ollama after it couldn't be reached
+	SearchState       // Typing a / search through the conversation
+	WorkflowListState // Picking a ComfyUI workflow for :workflow
+	ClearConfirmState // Confirming :clear
+	DeleteState       // Typing the ID of a message to delete
 )
 
 // ViewMode represents the view mode for messages
@@ -47,26 +99,81 @@ type ConfigLoadedMsg struct {
 	URL          string
 	ComfyUIURL   string
 	WorkflowPath string
-	Err          error
-}
-
-// Legacy streaming messages (kept for compatibility)
-type StreamMsg struct {
-	ID    string
-	Token string
-	Done  bool
+	// InjectAllPrompts fills every positive prompt node rather than only the first
+	InjectAllPrompts bool
+	// ComfyUINodes maps prompt, latent and sampler roles to custom node types
+	ComfyUINodes comfyui.NodeMap
+	SessionDir   string
+	Inline       bool
+	RenderMath   bool
+	HideHeader   bool
+	StopPattern  string
+	// RepetitionLimit stops a reply repeating one phrase this many times; 0 disables it
+	RepetitionLimit int
+	// RedrawIntervalMs throttles streaming redraws; 0 redraws on every token
+	RedrawIntervalMs int
+	// AutoScrollThreshold is how close to the bottom, in lines, the view must be to follow a stream
+	AutoScrollThreshold int
+	// ResponseCache enables the on-disk response cache; CacheAll also caches non-deterministic requests
+	ResponseCache bool
+	CacheAll      bool
+	// ModelTemplates maps models to the chat template for raw completion; models without one use /api/chat
+	ModelTemplates  map[string]string
+	PromptTemplates map[string]PromptTemplate
+	// CommandAliases maps a command name to the command line it expands to
+	CommandAliases map[string]string
+	// PromptPrefix and PromptSuffix wrap each user message when it is sent
+	PromptPrefix string
+	PromptSuffix string
+	// FallbackModels is the model list used when it can't be fetched
+	FallbackModels []string
+	// Workflows holds per-workflow settings, keyed by workflow file name or path
+	Workflows map[string]WorkflowSettings
+	// DebugLog is the diagnostic log file; empty uses the default
+	DebugLog string
+	// WordStreaming holds streamed text back until a word boundary
+	WordStreaming bool
+	// RenderWindow renders only the last this many messages; 0 renders all
+	RenderWindow int
+	// Seed fixes Ollama's sampling seed; nil samples randomly
+	Seed *int64
+	// StartOllama offers to run "ollama serve" when a local server can't be reached
+	StartOllama bool
+	// ComfyUIClientID pins the ComfyUI client ID; empty keeps the random one
+	ComfyUIClientID string
+	// MaxImageJobs caps concurrent ComfyUI generations; 0 is unlimited
+	MaxImageJobs int
+	// ImageSidecars writes a JSON metadata file next to each generated image
+	ImageSidecars bool
+	// TimeoutSeconds bounds connecting to Ollama; 0 uses the default
+	TimeoutSeconds int
+	// Temperature, TopP and NumCtx are generation options; unset keeps the model's defaults
+	Temperature *float64
+	TopP        *float64
+	NumCtx      int
+	// SystemPrompt is sent ahead of every conversation; empty sends none
+	SystemPrompt string
+	// WorkflowDir is where :workflow lists workflows; empty uses the current workflow's directory
+	WorkflowDir string
+	// ImageOutputDir and ImagePrefix place and name generated images; empty keeps the defaults
+	ImageOutputDir string
+	ImagePrefix    string
+	// CollapseLength is how many characters a collapsed message keeps; 0 uses the default
+	CollapseLength int
+	// DeleteWithReply makes deleting a prompt also delete its reply
+	DeleteWithReply bool
+	// HistorySize caps the : command history; 0 uses the default
+	HistorySize int
+	// Think is sent as the request's think field; nil leaves it to the model
+	Think *bool
+	Err   error
 }
 
 type StreamErrorMsg struct {
 	ID    string
 	Error string
-}
-
-// Real-time streaming messages
-type StreamTokenMsg struct {
-	ID    string
-	Token string
-	Done  bool
+	// Err is the error itself; context.Canceled when the stream was cancelled
+	Err error
 }
 
 // New streaming message types for real-time updates
@@ -76,7 +183,23 @@ type TokenMsg struct {
 }
 
 type GenerationDoneMsg struct {
-	ID string
+	ID     string
+	Reason string       // Ollama's done_reason, e.g. "stop" or "length"
+	Meta   ResponseMeta // Timing statistics, zero for replies not generated by Ollama
+}
+
+// ResponseMeta is Ollama's timing breakdown of one response
+type ResponseMeta struct {
+	TotalDuration      time.Duration `json:"total_duration"`
+	LoadDuration       time.Duration `json:"load_duration"`
+	PromptEvalCount    int           `json:"prompt_eval_count"`
+	PromptEvalDuration time.Duration `json:"prompt_eval_duration"`
+	EvalCount          int           `json:"eval_count"`
+	EvalDuration       time.Duration `json:"eval_duration"`
+	// Measured by eko from sending the request: to the first token, which is
+	// mostly prompt processing, and to the end of the reply
+	FirstToken time.Duration `json:"first_token,omitempty"`
+	Elapsed    time.Duration `json:"elapsed,omitempty"`
 }
 
 type GenerationStartMsg struct {
@@ -90,7 +213,101 @@ type CancelStreamMsg struct {
 }
 
 type ViewportContentMsg struct {
+	Content      string
+	MessageLines []int // First line of each message in Content, -1 when not shown
+	Tab          int   // Index of the conversation tab the content was rendered for
+}
+
+type SessionLoadedMsg struct {
+	Path     string
+	Session  Session
+	ReadOnly bool
+	Err      error
+}
+
+// SessionSavedMsg reports a finished :save; Messages is the conversation as written
+type SessionSavedMsg struct {
+	Path     string
+	Tab      int
+	Messages []Message
+	Err      error
+}
+
+type SessionsFoundMsg struct {
+	Tag   string
+	Paths []string
+	Err   error
+}
+
+type VersionLoadedMsg struct {
+	Version string
+	Err     error
+}
+
+// ControlRequest is a command from the control socket. The UI answers on
+// Reply, which must be buffered so answering never blocks.
+type ControlRequest struct {
+	Method string
+	Prompt string
+	Reply  chan<- ControlReply
+}
+
+// ControlReply is the answer to a ControlRequest
+type ControlReply struct {
+	Result interface{}
+	Err    error
+}
+
+// PingMsg reports which backends answered a reachability check
+type PingMsg struct {
+	Ollama  bool
+	ComfyUI bool
+}
+
+// OllamaStartedMsg reports whether "ollama serve" came up. Output holds what
+// the server printed when it didn't.
+type OllamaStartedMsg struct {
+	Output string
+	Err    error
+}
+
+// ComfyUICheckedMsg reports whether ComfyUI answered at URL. Entered is set
+// when the URL was typed into the setup prompt rather than read from config.
+type ComfyUICheckedMsg struct {
+	URL     string
+	Entered bool
+	Err     error
+}
+
+// BenchRun is the timing of one :bench generation
+type BenchRun struct {
+	FirstToken   time.Duration // Latency until the first token arrived
+	Total        time.Duration
+	Tokens       int     // Tokens generated, as reported by Ollama
+	TokensPerSec float64 // Generation speed excluding prompt processing
+}
+
+// CouncilReply is one council member's answer, stored in message ID
+type CouncilReply struct {
+	ID      string
+	Model   string
 	Content string
+	Err     error
+}
+
+// CouncilDoneMsg carries the council's answers; ID is the judge's reply
+type CouncilDoneMsg struct {
+	ID      string
+	Replies []CouncilReply
+}
+
+// LogTickMsg refreshes the :log overlay
+type LogTickMsg struct{}
+
+// BenchDoneMsg carries the runs a :bench completed; Err is set if it stopped early
+type BenchDoneMsg struct {
+	Runs []BenchRun
+	Err  error
 }
 
 type ModelsLoadedMsg struct {
@@ -98,7 +315,21 @@ type ModelsLoadedMsg struct {
 	Err    error
 }
 
-type ScrollToBottomMsg struct{}
+type ScrollToBottomMsg struct {
+	Tab int // Index of the conversation tab that asked to scroll
+}
+
+// StatusMsg reports the outcome of a background command on the status line
+type StatusMsg struct {
+	Text string
+	Err  error
+}
+
+// ImageInfoMsg carries the settings a finished image was generated with
+type ImageInfoMsg struct {
+	ID   string
+	Info comfyui.ImageInfo
+}
 
 type ProgressMsg struct {
 	ID     string
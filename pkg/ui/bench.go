@@ -0,0 +1,144 @@
+package ui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/thebug/lab/eko/v3/pkg/types"
+)
+
+// benchHistory is the conversation up to and including the last user prompt,
+// as it would be sent to Ollama
+func (m Model) benchHistory() ([]types.Message, bool) {
+	for i := len(m.messages) - 1; i >= 0; i-- {
+		if m.messages[i].Role == "user" {
+			upTo := m
+			upTo.messages = m.messages[:i+1]
+			return upTo.chatHistory(""), true
+		}
+	}
+	return nil, false
+}
+
+// startBench re-sends the last prompt n times in the background, timing each
+// run. Replies are discarded and nothing is added to the conversation.
+func (m *Model) startBench(n int) tea.Cmd {
+	if m.isImageMode {
+		m.setStatus(false, "Benchmarking is not available in image mode")
+		return nil
+	}
+	if m.isThinking || m.benchCancel != nil {
+		m.setStatus(false, "Wait for the current generation to finish")
+		return nil
+	}
+	history, ok := m.benchHistory()
+	if !ok {
+		m.setStatus(false, "No prompt to benchmark")
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(m.ctx)
+	m.benchCancel = cancel
+	m.benchTotal = n
+	client := m.tabClient()
+	model := m.modelName
+	run := func() tea.Msg {
+		var runs []types.BenchRun
+		for i := 0; i < n; i++ {
+			result, err := client.MeasureChat(ctx, model, history)
+			if err != nil {
+				return types.BenchDoneMsg{Runs: runs, Err: err}
+			}
+			runs = append(runs, result)
+		}
+		return types.BenchDoneMsg{Runs: runs}
+	}
+	return tea.Batch(run, m.spinner.Tick)
+}
+
+// handleBenchDone shows the benchmark report once the runs are finished
+func (m *Model) handleBenchDone(msg types.BenchDoneMsg) {
+	if m.benchCancel != nil {
+		m.benchCancel()
+		m.benchCancel = nil
+	}
+
+	if msg.Err != nil && len(msg.Runs) == 0 {
+		if errors.Is(msg.Err, context.Canceled) {
+			m.setStatus(false, "Benchmark cancelled")
+		} else {
+			m.setStatus(false, fmt.Sprintf("Benchmark failed: %v", msg.Err))
+		}
+		return
+	}
+
+	m.benchReport = m.formatBenchReport(msg)
+	if m.state == types.NormalState {
+		m.state = types.BenchState
+	} else {
+		m.setStatus(true, "Benchmark finished")
+	}
+}
+
+// formatBenchReport tabulates each run followed by the averages
+func (m Model) formatBenchReport(msg types.BenchDoneMsg) string {
+	temperature := "default"
+	if temp, ok := m.ollamaClient.Options["temperature"]; ok {
+		temperature = fmt.Sprint(temp)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d/%d runs, temperature %s\n\n", len(msg.Runs), m.benchTotal, temperature)
+	fmt.Fprintf(&b, "%4s  %12s  %8s  %7s  %7s\n", "run", "first token", "total", "tokens", "tok/s")
+
+	var sumFirst, sumTotal time.Duration
+	var sumSpeed float64
+	minSpeed, maxSpeed := msg.Runs[0].TokensPerSec, msg.Runs[0].TokensPerSec
+	for i, run := range msg.Runs {
+		fmt.Fprintf(&b, "%4d  %12s  %8s  %7d  %7.1f\n", i+1,
+			run.FirstToken.Round(time.Millisecond), run.Total.Round(time.Millisecond), run.Tokens, run.TokensPerSec)
+		sumFirst += run.FirstToken
+		sumTotal += run.Total
+		sumSpeed += run.TokensPerSec
+		if run.TokensPerSec < minSpeed {
+			minSpeed = run.TokensPerSec
+		}
+		if run.TokensPerSec > maxSpeed {
+			maxSpeed = run.TokensPerSec
+		}
+	}
+
+	count := len(msg.Runs)
+	fmt.Fprintf(&b, "%4s  %12s  %8s  %7s  %7.1f\n", "avg",
+		(sumFirst / time.Duration(count)).Round(time.Millisecond),
+		(sumTotal / time.Duration(count)).Round(time.Millisecond), "", sumSpeed/float64(count))
+	fmt.Fprintf(&b, "\ntok/s min %.1f, max %.1f\n", minSpeed, maxSpeed)
+
+	if msg.Err != nil {
+		fmt.Fprintf(&b, "\nStopped early: %v\n", msg.Err)
+	}
+	return b.String()
+}
+
+// handleBenchState closes the benchmark report
+func (m *Model) handleBenchState(msg tea.KeyMsg) {
+	switch msg.String() {
+	case "esc", "q", "enter":
+		m.benchReport = ""
+		m.state = types.NormalState
+	}
+}
+
+// renderBench renders the benchmark report overlay
+func (m Model) renderBench() string {
+	var b strings.Builder
+	b.WriteString(lipgloss.NewStyle().Foreground(accentColor).Render("bench " + m.modelName))
+	b.WriteString(" (esc to close)\n\n")
+	b.WriteString(lipgloss.NewStyle().Foreground(defaultColor).Render(m.benchReport))
+	return b.String()
+}
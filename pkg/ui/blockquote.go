@@ -0,0 +1,95 @@
+package ui
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// quoteStyle draws a blockquote's left border; nested quotes get one each
+var quoteStyle = lipgloss.NewStyle().
+	Border(lipgloss.ThickBorder(), false, false, false, true).
+	BorderForeground(accentColor).
+	PaddingLeft(1)
+
+// quoteIndent is how much width each quote level's border and padding take
+const quoteIndent = 2
+
+// quoteLine is a line of a blockquote with its "> " markers removed
+type quoteLine struct {
+	depth int
+	text  string
+}
+
+// RenderQuotes draws markdown blockquotes in content with a colored left
+// border instead of "> " markers, one border per nesting level, wrapping the
+// text to width. Code blocks are left untouched.
+func RenderQuotes(content string, width int) string {
+	return mapProse(content, func(text string) string {
+		return renderQuoteLines(text, width)
+	})
+}
+
+// renderQuoteLines renders the blockquotes in text that contains no code fences
+func renderQuoteLines(text string, width int) string {
+	lines := strings.Split(text, "\n")
+	out := make([]string, 0, len(lines))
+	var quote []quoteLine
+	flush := func() {
+		if len(quote) > 0 {
+			out = append(out, formatQuote(quote, 1, width))
+			quote = nil
+		}
+	}
+
+	for _, line := range lines {
+		if depth, rest := quoteDepth(line); depth > 0 {
+			quote = append(quote, quoteLine{depth: depth, text: rest})
+			continue
+		}
+		flush()
+		out = append(out, line)
+	}
+	flush()
+	return strings.Join(out, "\n")
+}
+
+// quoteDepth counts the ">" markers starting line and returns the text after
+// them. Up to three spaces may precede each marker, as in markdown.
+func quoteDepth(line string) (int, string) {
+	depth := 0
+	for {
+		trimmed := strings.TrimLeft(line, " ")
+		if len(line)-len(trimmed) > 3 || !strings.HasPrefix(trimmed, ">") {
+			return depth, line
+		}
+		depth++
+		line = strings.TrimPrefix(trimmed[1:], " ")
+	}
+}
+
+// formatQuote renders the lines of a quote at depth inside a border, and
+// deeper runs of lines as quotes nested within it
+func formatQuote(lines []quoteLine, depth, width int) string {
+	textWidth := width - quoteIndent
+	if textWidth < 10 {
+		textWidth = 10
+	}
+
+	var parts []string
+	for i := 0; i < len(lines); {
+		if lines[i].depth > depth {
+			j := i
+			for j < len(lines) && lines[j].depth > depth {
+				j++
+			}
+			parts = append(parts, formatQuote(lines[i:j], depth+1, textWidth))
+			i = j
+			continue
+		}
+		parts = append(parts, ansi.Wrap(lines[i].text, textWidth, ""))
+		i++
+	}
+	return quoteStyle.Render(strings.Join(parts, "\n"))
+}
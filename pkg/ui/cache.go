@@ -0,0 +1,54 @@
+package ui
+
+import (
+	"fmt"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/thebug/lab/eko/v3/pkg/types"
+)
+
+// cachedReason is the done reason reported for replies served from the cache
+const cachedReason = "cached"
+
+// cacheable reports whether requests may be served from and stored in the
+// response cache. Without cache_all only deterministic (temperature 0) requests
+// qualify, since caching a sampled reply would hide the variation.
+func (m Model) cacheable() bool {
+	if m.responseCache == nil {
+		return false
+	}
+	if m.cacheAll {
+		return true
+	}
+	temp, ok := m.ollamaClient.Options["temperature"]
+	return ok && fmt.Sprint(temp) == "0"
+}
+
+// finishCaching runs when a stream completes: it marks replies that came from
+// the cache and stores fresh, complete ones under the stream's cache key
+func (m *Model) finishCaching(reason string) tea.Cmd {
+	key := m.cacheKey
+	m.cacheKey = ""
+	if len(m.messages) == 0 || m.messages[len(m.messages)-1].Role != "assistant" {
+		return nil
+	}
+
+	last := &m.messages[len(m.messages)-1]
+	if reason == cachedReason {
+		last.Cached = true
+		return nil
+	}
+	// Replies cut off by num_predict are not worth replaying
+	if key == "" || reason == "length" || m.responseCache == nil {
+		return nil
+	}
+
+	c := m.responseCache
+	response := last.Content
+	return func() tea.Msg {
+		if err := c.Put(key, response); err != nil {
+			return types.StatusMsg{Err: err}
+		}
+		return nil
+	}
+}
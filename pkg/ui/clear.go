@@ -0,0 +1,46 @@
+package ui
+
+import (
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/thebug/lab/eko/v3/pkg/types"
+)
+
+// confirmClear asks before :clear empties the conversation
+func (m *Model) confirmClear() {
+	if len(m.messages) == 0 {
+		m.setStatus(false, "Nothing to clear")
+		return
+	}
+	m.state = types.ClearConfirmState
+}
+
+// handleClearConfirmState handles the answer to clearing the conversation
+func (m *Model) handleClearConfirmState(msg tea.KeyMsg) tea.Cmd {
+	switch msg.String() {
+	case "y":
+		m.state = types.NormalState
+		return m.clearConversation()
+
+	case "n", "esc":
+		m.state = types.NormalState
+		m.setStatus(false, "Clear cancelled")
+	}
+	return nil
+}
+
+// clearConversation starts the conversation afresh, stopping a reply still
+// being generated along with anything queued behind it. u brings it back.
+func (m *Model) clearConversation() tea.Cmd {
+	if m.isThinking && m.currentStreamID != "" {
+		m.promptQueue = nil
+		m.cancelStream()
+	}
+	m.pushUndo()
+	m.messages = nil
+	m.failedID = ""
+	m.focusedBlock = ""
+	m.hotID = ""
+	ClearCodeBlocks()
+	m.setStatus(true, "Conversation cleared (u to undo)")
+	return m.updateViewportContent()
+}
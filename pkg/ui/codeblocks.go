@@ -2,19 +2,76 @@ package ui
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
 	"regexp"
 	"strings"
+	"sync"
 
 	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
 	"github.com/thebug/lab/eko/v3/pkg/types"
 )
 
-// Global code block storage
-var codeBlocks = make(map[string]types.CodeBlock)
+// Global code block storage. Rendering runs in tea.Cmd goroutines, so every
+// access goes through codeBlocksMu.
+var (
+	codeBlocks   = make(map[string]types.CodeBlock)
+	codeBlocksMu sync.RWMutex
+)
 
 // codeBlockRegex matches markdown code blocks with optional language
 var codeBlockRegex = regexp.MustCompile("```(\\w*)\\n([\\s\\S]*?)```")
 
+// languageExtensions maps code fence language tags to file extensions
+var languageExtensions = map[string]string{
+	"bash":       "sh",
+	"c":          "c",
+	"cpp":        "cpp",
+	"cs":         "cs",
+	"csharp":     "cs",
+	"css":        "css",
+	"go":         "go",
+	"golang":     "go",
+	"html":       "html",
+	"java":       "java",
+	"javascript": "js",
+	"js":         "js",
+	"json":       "json",
+	"jsx":        "jsx",
+	"kotlin":     "kt",
+	"lua":        "lua",
+	"markdown":   "md",
+	"md":         "md",
+	"php":        "php",
+	"py":         "py",
+	"python":     "py",
+	"rb":         "rb",
+	"ruby":       "rb",
+	"rs":         "rs",
+	"rust":       "rs",
+	"sh":         "sh",
+	"shell":      "sh",
+	"sql":        "sql",
+	"swift":      "swift",
+	"toml":       "toml",
+	"ts":         "ts",
+	"tsx":        "tsx",
+	"typescript": "ts",
+	"xml":        "xml",
+	"yaml":       "yaml",
+	"yml":        "yml",
+	"zsh":        "sh",
+}
+
+// extensionForLanguage returns the file extension for a code fence language, defaulting to txt
+func extensionForLanguage(language string) string {
+	if ext, ok := languageExtensions[strings.ToLower(language)]; ok {
+		return ext
+	}
+	return "txt"
+}
+
 // generateCodeBlockID creates a unique ID for a code block using parentID+letter format
 func generateCodeBlockID(messageID string, index int) string {
 	// Convert index to letter (a, b, c, ...)
@@ -22,16 +79,14 @@ func generateCodeBlockID(messageID string, index int) string {
 	return messageID + letter
 }
 
-// RenderCodeBlock renders a code block with gray background and ID in bottom right
-func RenderCodeBlock(block types.CodeBlock, width int) string {
+// RenderCodeBlock renders a code block with gray background and ID in bottom right.
+// The block focused by ]c / [c navigation gets a lighter background.
+func RenderCodeBlock(block types.CodeBlock, width int, focused bool) string {
 	// Ensure minimum width to prevent crashes
 	if width < 20 {
 		width = 80
 	}
 
-	// Apply syntax highlighting
-	highlightedContent := highlightCode(block.Content, block.Language)
-
 	// Language display
 	languageDisplay := block.Language
 	if languageDisplay == "" {
@@ -39,9 +94,16 @@ func RenderCodeBlock(block types.CodeBlock, width int) string {
 	}
 
 	// Create gray background style that covers the entire block
+	background := lipgloss.Color("#0f0f0f")
+	if focused {
+		background = lipgloss.Color("#262626")
+	}
+
+	// Apply syntax highlighting on the block's background
+	highlightedContent := highlightCode(block.Content, block.Language, background)
 	grayStyle := lipgloss.NewStyle().
-		Background(lipgloss.Color("#0f0f0f")).
-		Foreground(lipgloss.Color("#fe3f01")).
+		Background(background).
+		Foreground(codeColor).
 		Padding(1, 2).
 		Margin(0, 0, 1, 0).
 		Width(width - 4) // Set explicit width to ensure full coverage
@@ -49,21 +111,26 @@ func RenderCodeBlock(block types.CodeBlock, width int) string {
 	// Add header with language
 	header := fmt.Sprintf("%s code", languageDisplay)
 
-	// Split content into lines for processing
-	lines := strings.Split(highlightedContent, "\n")
+	// Account for padding and width
+	availableWidth := width - 4 - 4 // width - padding - some buffer
+
+	// Split content into lines for processing, breaking lines that don't fit
+	lines := strings.Split(hardWrap(highlightedContent, availableWidth), "\n")
 
 	// Add ID to bottom right corner
 	if len(lines) > 0 {
 		lastLine := lines[len(lines)-1]
 		// Calculate padding needed to right-align the ID
 		idText := "[" + block.ID + "]"
-		// Account for padding and width
-		availableWidth := width - 4 - 4 // width - padding - some buffer
-		paddingNeeded := availableWidth - len(lastLine) - len(idText)
+		paddingNeeded := availableWidth - ansi.StringWidth(lastLine) - len(idText)
+		// Highlighted code resets its colors, so the tag brings its own background
+		tagStyle := lipgloss.NewStyle().Foreground(codeColor).Background(background)
 		if paddingNeeded < 0 {
-			paddingNeeded = 0
+			// Keep the tag whole on a line of its own
+			lines = append(lines, tagStyle.Render(strings.Repeat(" ", availableWidth-len(idText))+idText))
+		} else {
+			lines[len(lines)-1] = lastLine + tagStyle.Render(strings.Repeat(" ", paddingNeeded)+idText)
 		}
-		lines[len(lines)-1] = lastLine + strings.Repeat(" ", paddingNeeded) + idText
 	}
 
 	// Combine header and content
@@ -72,20 +139,24 @@ func RenderCodeBlock(block types.CodeBlock, width int) string {
 	return grayStyle.Render(content)
 }
 
-// highlightCode applies basic syntax highlighting to code content
-func highlightCode(content, language string) string {
-	// Return plain text if content is empty
-	if content == "" {
-		return content
+// trimCodeBlock drops the blank lines around a code block. The lines in
+// between are kept exactly, indentation and trailing whitespace included,
+// since both can be significant, as in Python or Markdown line breaks.
+func trimCodeBlock(code string) string {
+	lines := strings.Split(strings.ReplaceAll(code, "\r\n", "\n"), "\n")
+	blank := func(line string) bool { return strings.TrimSpace(line) == "" }
+	for len(lines) > 0 && blank(lines[0]) {
+		lines = lines[1:]
 	}
-
-	// For now, return content as is with minimal highlighting
-	// We can add more sophisticated highlighting later
-	return content
+	for len(lines) > 0 && blank(lines[len(lines)-1]) {
+		lines = lines[:len(lines)-1]
+	}
+	return strings.Join(lines, "\n")
 }
 
-// ReplaceCodeBlocksInContent replaces code blocks in content with rendered versions
-func ReplaceCodeBlocksInContent(content string, messageID string, width int) string {
+// ReplaceCodeBlocksInContent replaces code blocks in content with rendered versions,
+// highlighting the one whose ID is focusedID
+func ReplaceCodeBlocksInContent(content string, messageID string, width int, focusedID string) string {
 	// Ensure minimum width to prevent crashes
 	if width < 20 {
 		width = 80
@@ -100,44 +171,93 @@ func ReplaceCodeBlocksInContent(content string, messageID string, width int) str
 
 	// Process each match and replace it
 	for i, match := range matches {
-		if len(match) >= 3 {
-			language := strings.TrimSpace(match[1])
-			codeContent := strings.TrimSpace(match[2])
-
-			// Generate unique ID
-			blockID := generateCodeBlockID(messageID, i)
-
-			// Create code block
-			block := types.CodeBlock{
-				ID:        blockID,
-				Language:  language,
-				Content:   codeContent,
-				MessageID: messageID,
-			}
-
-			// Store in global map
-			codeBlocks[blockID] = block
-
-			// Render the block
-			renderedBlock := RenderCodeBlock(block, width)
-
-			// Replace the original code block
-			originalBlock := match[0] // The full match including ```
-			content = strings.Replace(content, originalBlock, renderedBlock, 1)
-		}
+		block := codeBlockFromMatch(match, messageID, i)
+
+		// Store in global map
+		codeBlocksMu.Lock()
+		codeBlocks[block.ID] = block
+		codeBlocksMu.Unlock()
+
+		// Render the block
+		renderedBlock := RenderCodeBlock(block, width, block.ID == focusedID)
+
+		// Replace the original code block
+		originalBlock := match[0] // The full match including ```
+		content = strings.Replace(content, originalBlock, renderedBlock, 1)
 	}
 
 	return content
 }
 
+// codeBlockFromMatch builds the index'th code block of a message from its
+// codeBlockRegex match
+func codeBlockFromMatch(match []string, messageID string, index int) types.CodeBlock {
+	return types.CodeBlock{
+		ID:        generateCodeBlockID(messageID, index),
+		Language:  strings.TrimSpace(match[1]),
+		Content:   trimCodeBlock(match[2]),
+		MessageID: messageID,
+	}
+}
+
+// extractCodeBlocks returns the code blocks in a message's content, with the
+// IDs they are rendered under
+func extractCodeBlocks(content, messageID string) []types.CodeBlock {
+	var blocks []types.CodeBlock
+	for i, match := range codeBlockRegex.FindAllStringSubmatch(content, -1) {
+		blocks = append(blocks, codeBlockFromMatch(match, messageID, i))
+	}
+	return blocks
+}
+
 // GetCodeBlock retrieves a code block by ID
 func GetCodeBlock(blockID string) (types.CodeBlock, bool) {
+	codeBlocksMu.RLock()
+	defer codeBlocksMu.RUnlock()
 	block, exists := codeBlocks[blockID]
 	return block, exists
 }
 
+// ClearCodeBlocks forgets every registered code block. Blocks still on screen
+// register again the next time they are rendered.
+func ClearCodeBlocks() {
+	codeBlocksMu.Lock()
+	defer codeBlocksMu.Unlock()
+	codeBlocks = make(map[string]types.CodeBlock)
+}
+
+// ResetCodeBlocks replaces every registered code block with the blocks of
+// the assistant messages in messages, for a conversation that replaced the
+// previous one. Its message IDs may be reused, so no old block may remain.
+func ResetCodeBlocks(messages []types.Message) {
+	codeBlocksMu.Lock()
+	defer codeBlocksMu.Unlock()
+	codeBlocks = make(map[string]types.CodeBlock)
+	for _, msg := range messages {
+		if msg.Role != "assistant" {
+			continue
+		}
+		for _, block := range extractCodeBlocks(msg.Content, msg.ID) {
+			codeBlocks[block.ID] = block
+		}
+	}
+}
+
+// RemoveCodeBlocks forgets the code blocks of a deleted message
+func RemoveCodeBlocks(messageID string) {
+	codeBlocksMu.Lock()
+	defer codeBlocksMu.Unlock()
+	for id, block := range codeBlocks {
+		if block.MessageID == messageID {
+			delete(codeBlocks, id)
+		}
+	}
+}
+
 // GetAllCodeBlocks returns all code blocks for a message
 func GetAllCodeBlocks(messageID string) []types.CodeBlock {
+	codeBlocksMu.RLock()
+	defer codeBlocksMu.RUnlock()
 	var blocks []types.CodeBlock
 	for _, block := range codeBlocks {
 		if block.MessageID == messageID {
@@ -147,8 +267,37 @@ func GetAllCodeBlocks(messageID string) []types.CodeBlock {
 	return blocks
 }
 
+// ExportCodeBlocks writes every code block in the replies among messages into
+// dir, one file per block named <id>.<ext>, and returns the number of files
+// written. The blocks are read from the messages themselves, so replies that
+// were never rendered, e.g. collapsed ones or those outside the render
+// window, are included too.
+func ExportCodeBlocks(messages []types.Message, dir string) (int, error) {
+	var blocks []types.CodeBlock
+	for _, msg := range messages {
+		if msg.Role == "assistant" {
+			blocks = append(blocks, extractCodeBlocks(msg.Content, msg.ID)...)
+		}
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return 0, err
+	}
+
+	for i, block := range blocks {
+		filename := filepath.Join(dir, block.ID+"."+extensionForLanguage(block.Language))
+		if err := os.WriteFile(filename, []byte(block.Content+"\n"), 0644); err != nil {
+			return i, err
+		}
+	}
+
+	return len(blocks), nil
+}
+
 // ListAllCodeBlocks returns all code block IDs for debugging
 func ListAllCodeBlocks() []string {
+	codeBlocksMu.RLock()
+	defer codeBlocksMu.RUnlock()
 	var ids []string
 	for id := range codeBlocks {
 		ids = append(ids, id)
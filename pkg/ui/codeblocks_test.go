@@ -0,0 +1,88 @@
+package ui
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/thebug/lab/eko/v3/pkg/config"
+	"github.com/thebug/lab/eko/v3/pkg/types"
+)
+
+func TestTrimCodeBlock(t *testing.T) {
+	tests := []struct {
+		name string
+		code string
+		want string
+	}{
+		{
+			"indented python",
+			"\n\ndef f(x):\n    if x:\n        return 1\n\n    return 0\n\n",
+			"def f(x):\n    if x:\n        return 1\n\n    return 0",
+		},
+		{
+			"indented first line",
+			"    indented()\n    again()\n",
+			"    indented()\n    again()",
+		},
+		{"trailing whitespace kept", "line one  \nline two\t\n", "line one  \nline two\t"},
+		{"blank lines of spaces", "  \n\t\ncode\n   \n", "code"},
+		{"windows line endings", "\r\nx = 1\r\n    y = 2\r\n", "x = 1\n    y = 2"},
+		{"empty", "\n\n", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := trimCodeBlock(tt.code); got != tt.want {
+				t.Errorf("trimCodeBlock = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExportCodeBlocksFromContent(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	ClearCodeBlocks()
+	messages := []types.Message{
+		{ID: "aa", Role: "user", Content: "```go\nnot exported\n```"},
+		// Never rendered, so never registered
+		{ID: "ba", Role: "assistant", Content: "One:\n```go\npackage main\n```\nTwo:\n```python\nif x:\n    y()\n```"},
+		{ID: "ca", Role: "assistant", Content: "```\nplain\n```", IsCollapsed: true},
+	}
+
+	count, err := ExportCodeBlocks(messages, config.ExpandPath("~/snippets"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 3 {
+		t.Errorf("exported %d blocks, want 3", count)
+	}
+	for name, want := range map[string]string{
+		"baa.go":  "package main\n",
+		"bab.py":  "if x:\n    y()\n",
+		"caa.txt": "plain\n",
+	} {
+		data, err := os.ReadFile(filepath.Join(home, "snippets", name))
+		if err != nil || string(data) != want {
+			t.Errorf("%s = %q, %v, want %q", name, data, err, want)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(home, "snippets", "aaa.go")); err == nil {
+		t.Error("exported a block from a user message")
+	}
+}
+
+func TestExportCodeCommandExpandsHome(t *testing.T) {
+	m := newTestModel(t)
+	m.messages = []types.Message{{ID: "ba", Role: "assistant", Content: "```sh\necho hi\n```"}}
+
+	_, cmd := press(t, m, append(append([]string{":"}, typed("export-code ~/out")...), "enter")...)
+	statuses := collect[types.StatusMsg](cmd)
+	if len(statuses) != 1 || statuses[0].Err != nil {
+		t.Fatalf("status messages = %+v", statuses)
+	}
+	home, _ := os.UserHomeDir()
+	if _, err := os.Stat(filepath.Join(home, "out", "baa.sh")); err != nil {
+		t.Errorf("block not exported under the home directory: %v", err)
+	}
+}
@@ -0,0 +1,129 @@
+package ui
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/thebug/lab/eko/v3/pkg/types"
+)
+
+// codeBlockPos is the first viewport line of a rendered code block
+type codeBlockPos struct {
+	ID   string
+	Line int
+}
+
+// locateCodeBlocks finds where each code block starts in rendered conversation
+// content. Every block ends with its [id] tag, and the "<language> code" header
+// above the tag marks where it begins.
+func locateCodeBlocks(content string, messages []types.Message) []codeBlockPos {
+	lines := strings.Split(content, "\n")
+	var positions []codeBlockPos
+	cursor := 0
+	for _, msg := range messages {
+		blocks := GetAllCodeBlocks(msg.ID)
+		sort.Slice(blocks, func(i, j int) bool {
+			return blocks[i].ID < blocks[j].ID
+		})
+		for _, block := range blocks {
+			tag := "[" + block.ID + "]"
+			end := -1
+			for i := cursor; i < len(lines); i++ {
+				if strings.Contains(lines[i], tag) {
+					end = i
+					break
+				}
+			}
+			if end < 0 {
+				// Not rendered, e.g. collapsed in TLDR mode
+				continue
+			}
+
+			language := block.Language
+			if language == "" {
+				language = "code"
+			}
+			header := language + " code"
+			start := end
+			for i := end; i >= cursor; i-- {
+				if strings.Contains(lines[i], header) {
+					start = i
+					break
+				}
+			}
+			// Include the padding line above the header
+			if start > cursor {
+				start--
+			}
+			positions = append(positions, codeBlockPos{ID: block.ID, Line: start})
+			cursor = end + 1
+		}
+	}
+	return positions
+}
+
+// jumpCodeBlock scrolls to the next (dir > 0) or previous (dir < 0) code block
+// and highlights it. Movement continues from the highlighted block while it is
+// on screen, otherwise from the current scroll position.
+func (m *Model) jumpCodeBlock(dir int) tea.Cmd {
+	if len(m.codeBlockPositions) == 0 {
+		m.setStatus(false, "No code blocks")
+		return nil
+	}
+
+	top := m.viewport.YOffset
+	current := -1
+	for i, pos := range m.codeBlockPositions {
+		if pos.ID == m.focusedBlock && pos.Line >= top && pos.Line < top+m.viewport.Height {
+			current = i
+			break
+		}
+	}
+
+	next := -1
+	switch {
+	case current >= 0:
+		next = current + dir
+	case dir > 0:
+		for i, pos := range m.codeBlockPositions {
+			if pos.Line >= top {
+				next = i
+				break
+			}
+		}
+	default:
+		for i := len(m.codeBlockPositions) - 1; i >= 0; i-- {
+			if m.codeBlockPositions[i].Line < top {
+				next = i
+				break
+			}
+		}
+	}
+	if next < 0 || next >= len(m.codeBlockPositions) {
+		m.setStatus(false, "No more code blocks")
+		return nil
+	}
+
+	pos := m.codeBlockPositions[next]
+	m.focusedBlock = pos.ID
+	m.viewport.SetYOffset(pos.Line)
+	return m.updateViewportContent()
+}
+
+// handleBracketKey completes a ]c / [c sequence started by a bracket key
+func (m *Model) handleBracketKey(key string) tea.Cmd {
+	prev := m.lastKey
+	m.lastKey = ""
+	if time.Since(m.keyTimer) > 500*time.Millisecond {
+		return nil
+	}
+	switch {
+	case prev == "]" && key == "c":
+		return m.jumpCodeBlock(1)
+	case prev == "[" && key == "c":
+		return m.jumpCodeBlock(-1)
+	}
+	return nil
+}
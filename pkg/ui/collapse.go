@@ -0,0 +1,74 @@
+package ui
+
+import (
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/rivo/uniseg"
+	"github.com/thebug/lab/eko/v3/pkg/types"
+)
+
+// defaultCollapseLength is how many characters a collapsed message keeps
+// when collapse_length isn't set
+const defaultCollapseLength = 100
+
+// collapseLimit returns how many characters a collapsed message keeps
+func (m Model) collapseLimit() int {
+	if m.collapseLength > 0 {
+		return m.collapseLength
+	}
+	return defaultCollapseLength
+}
+
+// collapsible reports whether content is long enough to be collapsed
+func (m Model) collapsible(content string) bool {
+	return uniseg.GraphemeClusterCount(content) > m.collapseLimit()
+}
+
+// truncateGraphemes cuts s after n user-visible characters. Emoji, flags and
+// letters with combining accents count as one and are never split.
+func truncateGraphemes(s string, n int) string {
+	g := uniseg.NewGraphemes(s)
+	for count := 0; g.Next(); count++ {
+		if count == n {
+			from, _ := g.Positions()
+			return s[:from]
+		}
+	}
+	return s
+}
+
+// toggleCollapse expands or collapses the message with the given ID, or the
+// last reply when id is empty, leaving the rest of the view as it is
+func (m *Model) toggleCollapse(id string) tea.Cmd {
+	idx := -1
+	for i := len(m.messages) - 1; i >= 0; i-- {
+		if id == "" && m.messages[i].Role == "assistant" || id != "" && m.messages[i].ID == id {
+			idx = i
+			break
+		}
+	}
+	if idx < 0 {
+		if id == "" {
+			m.setStatus(false, "No reply to expand")
+		} else {
+			m.setStatus(false, "No message with ID "+id)
+		}
+		return nil
+	}
+
+	msg := &m.messages[idx]
+	if !msg.IsCollapsed && !m.collapsible(msg.Content) {
+		m.setStatus(false, msg.ID+" is short enough to show in full")
+		return nil
+	}
+	if m.viewMode != types.TLDRMode && msg.Council == "" {
+		m.setStatus(false, "Messages are shown in full; :tldr collapses them")
+		return nil
+	}
+	msg.IsCollapsed = !msg.IsCollapsed
+	if msg.IsCollapsed {
+		m.setStatus(true, "Collapsed "+msg.ID)
+	} else {
+		m.setStatus(true, "Expanded "+msg.ID)
+	}
+	return m.updateViewportContent()
+}
@@ -0,0 +1,75 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/thebug/lab/eko/v3/pkg/comfyui"
+	"github.com/thebug/lab/eko/v3/pkg/config"
+	"github.com/thebug/lab/eko/v3/pkg/types"
+)
+
+// checkComfyUI tests whether ComfyUI answers at url. entered marks a URL typed
+// into the setup prompt, which is saved to the config once it works.
+func checkComfyUI(url string, entered bool) tea.Cmd {
+	return func() tea.Msg {
+		_, err := comfyui.NewClient(url).GetQueueRemaining()
+		return types.ComfyUICheckedMsg{URL: url, Entered: entered, Err: err}
+	}
+}
+
+// handleComfyUIChecked applies the result of a reachability check, asking for
+// the URL inline when ComfyUI cannot be reached
+func (m *Model) handleComfyUIChecked(msg types.ComfyUICheckedMsg) tea.Cmd {
+	if msg.Err != nil {
+		m.setStatus(false, fmt.Sprintf("ComfyUI not reachable at %s", msg.URL))
+		// Don't pull the user out of something they're in the middle of
+		if m.state != types.NormalState {
+			return nil
+		}
+		m.state = types.ComfyURLState
+		m.input.Focus()
+		m.input.Prompt = "ComfyUI URL: "
+		m.input.SetValue(msg.URL)
+		m.input.CursorEnd()
+		return nil
+	}
+
+	m.comfyUIClient.BaseURL = msg.URL
+	if !msg.Entered {
+		return nil
+	}
+	m.setStatus(true, "Connected to ComfyUI at "+msg.URL)
+	url := msg.URL
+	return m.configManager.UpdateConfig(func(c *config.Config) {
+		c.ComfyUIURL = url
+	})
+}
+
+// handleComfyURLState handles input in the ComfyUI URL setup prompt. It returns
+// true when the key was consumed.
+func (m *Model) handleComfyURLState(msg tea.KeyMsg) (bool, tea.Cmd) {
+	switch msg.String() {
+	case "enter":
+		url := strings.TrimSpace(m.input.Value())
+		if url == "" {
+			return true, nil
+		}
+		if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+			url = "http://" + url
+		}
+		m.state = types.NormalState
+		m.input.Reset()
+		m.input.Prompt = ""
+		return true, checkComfyUI(strings.TrimRight(url, "/"), true)
+
+	case "esc":
+		m.state = types.NormalState
+		m.input.Reset()
+		m.input.Prompt = ""
+		m.setStatus(false, "ComfyUI not configured, image generation will fail")
+		return true, nil
+	}
+	return false, nil
+}
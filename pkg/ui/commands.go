@@ -1,13 +1,23 @@
 package ui
 
 import (
-	"encoding/json"
-	"os"
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/thebug/lab/eko/v3/pkg/cache"
+	"github.com/thebug/lab/eko/v3/pkg/config"
 	"github.com/thebug/lab/eko/v3/pkg/comfyui"
+	"github.com/thebug/lab/eko/v3/pkg/ollama"
+	"github.com/thebug/lab/eko/v3/pkg/session"
 	"github.com/thebug/lab/eko/v3/pkg/types"
 )
 
@@ -42,8 +52,17 @@ func (m Model) updateViewportContent() tea.Cmd {
 	elapsedTime := m.elapsedTime
 	isImageMode := m.isImageMode
 	isThinking := m.isThinking
+	renderMath := m.renderMath
+	tab := m.activeTab
+
+	return func() (msg tea.Msg) {
+		// A panic while rendering is reported instead of ending the program
+		defer func() {
+			if r := recover(); r != nil {
+				msg = types.StatusMsg{Err: errors.New(m.rescue("Rendering", r))}
+			}
+		}()
 
-	return func() tea.Msg {
 		// Add safety check to prevent panics, but use defaults if needed
 		if width == 0 {
 			width = 80
@@ -66,117 +85,339 @@ func (m Model) updateViewportContent() tea.Cmd {
 		tempModel.elapsedTime = elapsedTime
 		tempModel.isImageMode = isImageMode
 		tempModel.isThinking = isThinking
+		tempModel.renderMath = renderMath
 
-		content := tempModel.renderMessages()
-		return types.ViewportContentMsg{Content: content}
+		content, lines := tempModel.renderMessages()
+		return types.ViewportContentMsg{Content: content, MessageLines: lines, Tab: tab}
 	}
 }
 
+// wrapPrompt surrounds a user message with the configured prefix and suffix,
+// each separated from it by a blank line, unless :affix turned them off
+func (m Model) wrapPrompt(content string) string {
+	if m.noAffixes {
+		return content
+	}
+	if m.promptPrefix != "" {
+		content = m.promptPrefix + "\n\n" + content
+	}
+	if m.promptSuffix != "" {
+		content += "\n\n" + m.promptSuffix
+	}
+	return content
+}
+
 // scrollToBottom scrolls the viewport to the bottom
 func (m Model) scrollToBottom() tea.Cmd {
+	tab := m.activeTab
 	return tea.Tick(time.Millisecond*10, func(time.Time) tea.Msg {
-		return types.ScrollToBottomMsg{}
+		return types.ScrollToBottomMsg{Tab: tab}
 	})
 }
 
-// streamResponse streams a response from Ollama
-func (m Model) streamResponse(id string) tea.Cmd {
-	return func() tea.Msg {
-		// Prepare messages for Ollama (exclude the empty assistant message we just added)
-		messages := make([]types.Message, 0, len(m.messages)-1)
-		for _, msg := range m.messages {
-			if msg.ID != id { // Skip the empty assistant message
-				messages = append(messages, msg)
+// chatHistory prepares the messages sent to Ollama, leaving out the message with
+// excludeID, council members' replies and image generations, prepending the system prompt when
+// one is set, wrapping user messages in the prompt prefix and suffix and
+// mapping custom roles onto the ones Ollama accepts
+func (m Model) chatHistory(excludeID string) []types.Message {
+	messages := make([]types.Message, 0, len(m.messages)+1)
+	if m.systemPrompt != "" {
+		messages = append(messages, types.Message{Role: "system", Content: m.systemPrompt})
+	}
+	for _, msg := range m.messages {
+		if msg.ID != excludeID && msg.Council == "" && !msg.Image {
+			if msg.Role == "user" {
+				msg.Content = m.wrapPrompt(msg.Content)
 			}
+			messages = append(messages, msg)
 		}
+	}
+	return ollama.NormalizeRoles(messages)
+}
 
-		// Stream response from Ollama
-		var fullResponse strings.Builder
-		err := m.ollamaClient.StreamChat(m.modelName, messages, func(token string, done bool) {
-			fullResponse.WriteString(token)
-		})
+// startRealtimeStream starts a real-time streaming response
+func (m *Model) startRealtimeStream(id string) tea.Cmd {
+	// Prepare messages for Ollama (exclude the empty assistant message we just added)
+	return m.streamInto(m.beginStream(), id, m.chatHistory(id))
+}
 
-		if err != nil {
-			return types.StreamErrorMsg{ID: id, Error: err.Error()}
-		}
+// streamInto streams the reply to messages into the assistant message with the
+// given ID. Cancelling ctx aborts the request.
+func (m Model) streamInto(ctx context.Context, id string, messages []types.Message) tea.Cmd {
+	m.ollamaClient = m.tabClient()
+	return func() tea.Msg {
+		// Start the real-time streaming in a goroutine
+		go func() {
+			// Send generation start message
+			if !m.emit(types.GenerationStartMsg{ID: id}) {
+				return
+			}
+
+			observers := m.streamObservers()
+
+			// Replay an identical earlier request from the cache
+			if m.cacheKey != "" {
+				if response, ok := m.responseCache.Get(m.cacheKey); ok {
+					m.emit(types.TokenMsg{ID: id, Token: response})
+					m.emit(types.GenerationDoneMsg{ID: id, Reason: cachedReason})
+					for _, o := range observers {
+						o.Token(response)
+						o.Done(cachedReason, types.ResponseMeta{})
+					}
+					return
+				}
+			}
+
+			// Base models without a server-side template get a client-formatted raw prompt
+			if name := ollama.ModelTemplate(m.modelName, m.modelTemplates); name != "" {
+				tmpl, err := ollama.LookupTemplate(name, m.promptTemplates)
+				if err != nil {
+					m.emit(types.StreamErrorMsg{ID: id, Error: err.Error(), Err: err})
+					return
+				}
+				cmd := m.ollamaClient.StreamGenerateRealtime(ctx, m.modelName, tmpl, messages, m.msgChan, id, observers...)
+				cmd()
+				return
+			}
+
+			// Use the new real-time streaming method
+			cmd := m.ollamaClient.StreamChatRealtime(ctx, m.modelName, messages, m.msgChan, id, observers...)
+			cmd()
+		}()
 
-		return types.StreamMsg{ID: id, Token: fullResponse.String(), Done: true}
+		return nil
 	}
 }
 
-// streamResponseRealtime streams a response from Ollama in real-time
-func (m Model) streamResponseRealtime(id string) tea.Cmd {
-	return func() tea.Msg {
-		// Prepare messages for Ollama (exclude the empty assistant message we just added)
-		messages := make([]types.Message, 0, len(m.messages)-1)
-		for _, msg := range m.messages {
-			if msg.ID != id { // Skip the empty assistant message
-				messages = append(messages, msg)
-			}
-		}
 
-		// Stream response from Ollama with real-time updates
-		var fullResponse strings.Builder
-		err := m.ollamaClient.StreamChat(m.modelName, messages, func(token string, done bool) {
-			fullResponse.WriteString(token)
-		})
+// sendPrompt appends a user message with an assistant placeholder and starts
+// generating the reply, through ComfyUI in image mode or Ollama otherwise
+func (m *Model) sendPrompt(prompt string) []tea.Cmd {
+	return m.sendAs("user", prompt)
+}
 
-		if err != nil {
-			return types.StreamErrorMsg{ID: id, Error: err.Error()}
+// readOnlyHint explains why sending is refused in a read-only conversation
+const readOnlyHint = "Read-only conversation, use :readonly to allow sending"
+
+// sendAs is sendPrompt for a message spoken by the given role
+func (m *Model) sendAs(role, prompt string) []tea.Cmd {
+	if m.sendBlocked() {
+		return nil
+	}
+	// "/img <prompt>" generates an image in the middle of a chat
+	imagePrompt, image := strings.CutPrefix(prompt, imagePrefix)
+	image = image || m.isImageMode
+	if !image && len(m.councilModels) > 0 {
+		return m.sendToCouncil(role, prompt)
+	}
+	if image {
+		if err := m.loadWorkflow(); err != nil {
+			m.setStatus(false, err.Error())
+			return nil
 		}
+	}
+
+	// Add user message
+	id := m.nextID()
+	userMsg := types.Message{ID: id, Role: role, Content: prompt, IsCollapsed: false, Image: image, Timestamp: time.Now()}
+	m.messages = append(m.messages, userMsg)
+
+	// Add placeholder AI message
+	aiId := m.nextID()
+	aiMsg := types.Message{ID: aiId, Role: "assistant", Content: "", IsCollapsed: false, Image: image, Timestamp: time.Now()}
+	m.messages = append(m.messages, aiMsg)
 
-		return types.StreamTokenMsg{ID: id, Token: fullResponse.String(), Done: true}
+	if image {
+		return m.startImage(aiId, imagePrompt)
 	}
+	m.messages[len(m.messages)-1].Model = m.modelName
+	m.messages[len(m.messages)-1].Seed = m.seed()
+
+	// Start real-time streaming response
+	m.streaming = true
+	m.isThinking = true
+	m.currentStreamID = aiId
+	m.cacheKey = ""
+	if m.cacheable() {
+		m.cacheKey = cache.Key(m.modelName, ollama.ModelTemplate(m.modelName, m.modelTemplates), m.chatHistory(aiId), m.ollamaClient.Options)
+	}
+	return []tea.Cmd{m.startRealtimeStream(aiId), m.updateViewportContent(), m.scrollToBottom()}
 }
 
-// startRealtimeStream starts a real-time streaming response
-func (m Model) startRealtimeStream(id string) tea.Cmd {
-	return func() tea.Msg {
-		// Prepare messages for Ollama (exclude the empty assistant message we just added)
-		messages := make([]types.Message, 0, len(m.messages)-1)
-		for _, msg := range m.messages {
-			if msg.ID != id { // Skip the empty assistant message
-				messages = append(messages, msg)
-			}
-		}
+// startImage generates the image for prompt into the message with the given ID
+func (m *Model) startImage(id, prompt string) []tea.Cmd {
+	m.isThinking = true
+	m.currentStreamID = id
+	m.progressPct = 0.0
+	m.progressStage = "Starting..."
+	m.nodeProgress = ""
+	m.elapsedTime = 0
+	m.startTime = time.Now()
+	m.etaEstimate, _ = m.timings.Estimate(m.timingKey())
+	m.lastImagePrompt = prompt
+	return []tea.Cmd{m.generateImage(id, prompt), m.updateViewportContent(), m.scrollToBottom(), m.spinner.Tick}
+}
 
-		// Start the real-time streaming in a goroutine
-		go func() {
-			// Send generation start message
-			m.msgChan <- types.GenerationStartMsg{ID: id}
+// continuePrompt asks the model to pick up a cut-off reply without repeating it
+const continuePrompt = "Continue exactly where your last message stopped. Do not repeat anything or add a preamble."
 
-			// Use the new real-time streaming method
-			cmd := m.ollamaClient.StreamChatRealtime(m.modelName, messages, m.msgChan, id)
-			cmd()
-		}()
+// continueResponse streams a continuation onto the last assistant message
+// instead of starting a new one
+func (m *Model) continueResponse() []tea.Cmd {
+	if m.sendBlocked() {
+		return nil
+	}
+	if m.isThinking {
+		m.setStatus(false, "Wait for the current response to finish")
+		return nil
+	}
+	if m.isImageMode {
+		m.setStatus(false, "Continue is not available in image mode")
+		return nil
+	}
+	if len(m.messages) == 0 || m.messages[len(m.messages)-1].Role != "assistant" || m.messages[len(m.messages)-1].Image {
+		m.setStatus(false, "No response to continue")
+		return nil
+	}
+
+	id := m.messages[len(m.messages)-1].ID
+	// The instruction is sent but never added to the conversation
+	messages := append(m.chatHistory(""), types.Message{Role: "user", Content: continuePrompt})
+	m.streaming = true
+	m.isThinking = true
+	m.currentStreamID = id
+	m.cacheKey = ""
+	return []tea.Cmd{m.streamInto(m.beginStream(), id, messages), m.updateViewportContent(), m.scrollToBottom()}
+}
+
+// looksTruncated guesses whether a finished reply was cut off, either by
+// num_predict or mid-sentence
+func looksTruncated(reason, content string) bool {
+	if reason == "length" {
+		return true
+	}
+	content = strings.TrimSpace(content)
+	if content == "" || strings.HasSuffix(content, "```") {
+		return false
+	}
+	last, _ := utf8.DecodeLastRuneInString(content)
+	return !strings.ContainsRune(".!?…:;)]}\"'`*", last)
+}
+
+// suggestContinue hints at :continue when the reply that just finished looks cut off
+func (m *Model) suggestContinue(reason string) {
+	if m.isImageMode || len(m.messages) == 0 {
+		return
+	}
+	last := m.messages[len(m.messages)-1]
+	if last.Role == "assistant" && !last.Image && looksTruncated(reason, last.Content) {
+		m.setStatus(false, "Response looks cut off, press a or :continue to resume it")
+	}
+}
 
+// dispatchQueuedPrompt sends the next queued prompt once the previous generation has finished
+func (m *Model) dispatchQueuedPrompt() []tea.Cmd {
+	if m.isThinking || len(m.promptQueue) == 0 {
 		return nil
 	}
+
+	prompt := m.promptQueue[0]
+	m.promptQueue = m.promptQueue[1:]
+	return m.sendPrompt(prompt)
 }
 
+// checkStopPattern ends the stream early once the client-side stop pattern
+// appears in the response, trimming the reply at the match
+func (m *Model) checkStopPattern() []tea.Cmd {
+	if m.stopPattern == nil || len(m.messages) == 0 {
+		return nil
+	}
 
-// cancelStream cancels the current streaming operation
-func (m Model) cancelStream(id string) tea.Cmd {
-	return func() tea.Msg {
-		// Send cancellation message to the channel
-		m.msgChan <- types.CancelStreamMsg{ID: id}
+	last := &m.messages[len(m.messages)-1]
+	loc := m.stopPattern.FindStringIndex(last.Content)
+	if loc == nil {
 		return nil
 	}
+
+	last.Content = strings.TrimRight(last.Content[:loc[0]], " \n")
+	m.endStream()
+	m.setStatus(true, "Stopped at stop pattern")
+	return append([]tea.Cmd{m.updateViewportContent()}, m.dispatchQueuedPrompt()...)
 }
 
-// continueStream continues streaming
-func (m Model) continueStream(_ string) tea.Cmd {
-	// This is just a placeholder since we're handling streaming in streamResponse
-	return nil
+// emit sends a stream message to the active conversation's channel. It gives up
+// once the app is shutting down, since nothing drains the channel after quit.
+func (m Model) emit(msg tea.Msg) bool {
+	select {
+	case m.msgChan <- msg:
+		return true
+	case <-m.ctx.Done():
+		return false
+	}
 }
 
-// continueStreamRealtime continues real-time streaming
-func (m Model) continueStreamRealtime(id string) tea.Cmd {
-	return func() tea.Msg {
-		// This will be called to continue streaming
-		// We need to get the next token from the channel
-		// For now, we'll return a simple continuation
-		return types.StreamTokenMsg{ID: id, Token: "", Done: true}
+// quit cancels every in-flight request so their goroutines exit, then quits
+func (m *Model) quit() tea.Cmd {
+	m.shutdown()
+	return tea.Quit
+}
+
+// beginStream returns the context for a new stream's requests. endStream
+// cancels it, so a stream that is stopped early stops generating too.
+func (m *Model) beginStream() context.Context {
+	if m.streamCancel != nil {
+		m.streamCancel()
+	}
+	ctx, cancel := context.WithCancel(m.ctx)
+	m.streamCancel = cancel
+	return ctx
+}
+
+// endStream resets the streaming state once the current stream is over,
+// whether it finished, failed, hit the stop pattern or was cancelled
+func (m *Model) endStream() {
+	if m.streamCancel != nil {
+		m.streamCancel()
+		m.streamCancel = nil
+	}
+	m.isThinking = false
+	m.streaming = false
+	m.currentStreamID = ""
+	// Text still held back belongs to a reply that ended some other way
+	m.pendingTokens = ""
+}
+
+// cancelStream stops the current stream and marks its partial reply
+func (m *Model) cancelStream() []tea.Cmd {
+	if !m.isThinking {
+		return nil
+	}
+	m.flushTokens()
+	if i := m.messageIndex(m.currentStreamID); i >= 0 {
+		content := strings.TrimRight(m.messages[i].Content, " \n")
+		if content != "" {
+			content += " "
+		}
+		m.messages[i].Content = content + "[Stream cancelled]"
+	}
+	m.endStream()
+	return []tea.Cmd{m.updateViewportContent()}
+}
+
+// messageIndex returns the position of the message with the given ID, or -1
+func (m Model) messageIndex(id string) int {
+	for i := len(m.messages) - 1; i >= 0; i-- {
+		if m.messages[i].ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// trimReply drops the whitespace a finished reply ended with
+func (m *Model) trimReply(id string) {
+	if i := m.messageIndex(id); i >= 0 {
+		m.messages[i].Content = strings.TrimRightFunc(m.messages[i].Content, unicode.IsSpace)
 	}
 }
 
@@ -187,6 +428,18 @@ func (m *Model) handleCommand(command string) tea.Cmd {
 		return nil
 	}
 
+	alias := ""
+	if _, ok := m.commandAliases[parts[0]]; ok {
+		alias = parts[0]
+		expanded, err := expandAlias(parts, m.commandAliases)
+		if err != nil {
+			m.state = types.NormalState
+			m.setStatus(false, "Bad command alias: "+err.Error())
+			return nil
+		}
+		parts = expanded
+	}
+
 	cmd := parts[0]
 	args := parts[1:]
 
@@ -203,38 +456,421 @@ func (m *Model) handleCommand(command string) tea.Cmd {
 		}
 		return nil
 
+	case "model":
+		m.state = types.NormalState
+		if len(args) < 1 {
+			m.setStatus(true, "Model: "+m.modelName)
+			return nil
+		}
+		if args[0] == m.modelName {
+			return nil
+		}
+		if len(m.modelList) > 0 && !slices.Contains(m.modelList, args[0]) {
+			m.setStatus(false, "Unknown model "+args[0]+" (R refreshes the list)")
+			return nil
+		}
+		m.modelName = args[0]
+		m.setStatus(true, "Model: "+m.modelName)
+		return m.configManager.SaveConfig(m.modelName)
+
 	case "save":
+		m.state = types.NormalState
+		sess := m.currentSession()
+		tab := m.activeTab
+		if len(args) < 1 {
+			// Without a name the model titles the session
+			m.setStatus(true, "Naming session...")
+			return m.saveTitled(sess, tab)
+		}
+
+		filename := m.sessionPath(args[0])
+		return func() tea.Msg {
+			err := session.Save(filename, sess)
+			return types.SessionSavedMsg{Path: filename, Tab: tab, Messages: sess.Messages, Err: err}
+		}
+
+	case "load":
+		m.state = types.NormalState
+		if len(args) < 1 {
+			m.setStatus(false, "Usage: :load <file>")
+			return nil
+		}
+
+		return m.loadSession(m.sessionPath(args[0]), false)
+
+	case "view":
+		m.state = types.NormalState
+		if len(args) < 1 {
+			m.setStatus(false, "Usage: :view <file>")
+			return nil
+		}
+
+		return m.loadSession(m.sessionPath(args[0]), true)
+
+	case "readonly":
+		m.state = types.NormalState
+		m.readOnly = !m.readOnly
+		if m.readOnly {
+			m.setStatus(true, "Conversation is now read-only")
+		} else {
+			m.setStatus(true, "Conversation is editable again")
+		}
+		return nil
+
+	case "council":
+		m.state = types.NormalState
+		if len(args) == 0 {
+			if len(m.councilModels) == 0 {
+				m.setStatus(false, "No council set. Usage: :council <model> <model>... | :council off")
+			} else {
+				m.setStatus(true, fmt.Sprintf("Council: %s (judge: %s)", strings.Join(m.councilModels, ", "), m.modelName))
+			}
+			return nil
+		}
+		if args[0] == "off" {
+			m.councilModels = nil
+			m.setStatus(true, "Council off")
+			return nil
+		}
+		m.councilModels = args
+		m.setStatus(true, fmt.Sprintf("Council: %s (judge: %s)", strings.Join(args, ", "), m.modelName))
+		return nil
+
+	case "meta":
+		m.state = types.NormalState
+		m.showMeta = !m.showMeta
+		if m.showMeta {
+			m.setStatus(true, "Showing response metadata")
+		} else {
+			m.setStatus(true, "Hiding response metadata")
+		}
+		return m.updateViewportContent()
+
+	case "affix":
+		m.state = types.NormalState
+		if m.promptPrefix == "" && m.promptSuffix == "" {
+			m.setStatus(false, "No prompt_prefix or prompt_suffix configured")
+			return nil
+		}
+		m.noAffixes = !m.noAffixes
+		if m.noAffixes {
+			m.setStatus(true, "Sending messages as typed")
+		} else {
+			m.setStatus(true, "Wrapping messages in the prompt prefix and suffix")
+		}
+		return nil
+
+	case "log":
+		return m.openLog()
+
+	case "tee":
+		m.state = types.NormalState
+		if len(args) < 1 {
+			if m.teePath == "" {
+				m.setStatus(false, "Usage: :tee <file> | :tee off")
+			} else {
+				m.setStatus(true, "Replies are copied to "+m.teePath)
+			}
+			return nil
+		}
+		if args[0] == "off" {
+			m.teePath = ""
+			m.setStatus(true, "Tee off")
+			return nil
+		}
+		m.teePath = config.ExpandPath(args[0])
+		m.setStatus(true, "Copying replies to "+m.teePath)
+		return nil
+
+	case "seed":
+		m.state = types.NormalState
+		return m.setSeed(args)
+
+	case "set":
+		m.state = types.NormalState
+		return m.setOption(args)
+
+	case "workflow":
+		m.state = types.NormalState
+		if len(args) == 0 {
+			m.openWorkflowList()
+			return nil
+		}
+		return m.selectWorkflow(m.workflowFile(strings.Join(args, " ")))
+
+	case "clear":
+		m.state = types.NormalState
+		m.confirmClear()
+		return nil
+
+	case "noh", "nohlsearch":
+		m.state = types.NormalState
+		return m.clearSearch()
+
+	case "regenerate":
+		m.state = types.NormalState
+		return tea.Batch(m.regenerate()...)
+
+	case "system":
+		m.state = types.NormalState
+		switch {
+		case len(args) == 0 && m.systemPrompt == "":
+			m.setStatus(false, "No system prompt. Usage: :system <text> | :system off")
+		case len(args) == 0:
+			m.setStatus(true, "System prompt: "+m.systemPrompt)
+		case len(args) == 1 && args[0] == "off":
+			m.systemPrompt = ""
+			m.setStatus(true, "System prompt off")
+		default:
+			// Sent ahead of the conversation with every request, never shown as a message
+			m.systemPrompt = strings.Join(args, " ")
+			m.setStatus(true, "System prompt set")
+		}
+		return nil
+
+	case "numbers":
+		m.state = types.NormalState
+		m.showIndices = !m.showIndices
+		if m.showIndices {
+			m.setStatus(true, "Showing message numbers")
+		} else {
+			m.setStatus(true, "Hiding message numbers")
+		}
+		return m.updateViewportContent()
+
+	case "goto":
+		m.state = types.NormalState
+		if len(args) < 1 {
+			m.setStatus(false, "Usage: :goto <n>")
+			return nil
+		}
+		return m.gotoMessage(args[0])
+
+	case "quote":
+		m.state = types.NormalState
+		id := ""
+		if len(args) > 0 {
+			id = args[0]
+		}
+		m.quoteMessage(id)
+		return nil
+
+	case "star":
+		m.state = types.NormalState
+		id := ""
+		if len(args) > 0 {
+			id = args[0]
+		}
+		return m.toggleStar(id)
+
+	case "starred":
+		m.state = types.NormalState
+		m.starredOnly = !m.starredOnly
+		if m.starredOnly {
+			m.setStatus(true, "Showing starred messages only")
+		} else {
+			m.setStatus(true, "Showing all messages")
+		}
+		return m.updateViewportContent()
+
+	case "focus":
+		m.state = types.NormalState
+		return m.toggleFocus()
+
+	case "tag":
+		m.state = types.NormalState
+		if len(args) == 0 {
+			if len(m.tags) == 0 {
+				m.setStatus(false, "No tags set. Usage: :tag <tag>...")
+			} else {
+				m.setStatus(true, "Tags: "+strings.Join(m.tags, ", "))
+			}
+			return nil
+		}
+		m.tags = args
+		m.setStatus(true, "Tagged: "+strings.Join(m.tags, ", ")+" (saved on next :save)")
+		return nil
+
+	case "find":
+		m.state = types.NormalState
 		if len(args) < 1 {
+			m.setStatus(false, "Usage: :find <tag>")
+			return nil
+		}
+
+		if m.sessionIndex == nil {
+			m.sessionIndex = session.NewIndex(m.sessionDir)
+		}
+		index := m.sessionIndex
+		tag := args[0]
+		return func() tea.Msg {
+			paths, err := index.Find(tag)
+			return types.SessionsFoundMsg{Tag: tag, Paths: paths, Err: err}
+		}
+
+	case "diff":
+		if len(args) < 2 {
 			m.state = types.NormalState
+			m.setStatus(false, "Usage: :diff <idA> <idB>")
 			return nil
 		}
+		m.openDiff(args[0], args[1])
+		return nil
 
-		filename := args[0]
-		if !strings.HasSuffix(filename, ".json") {
-			filename += ".json"
+	case "retry-image":
+		m.state = types.NormalState
+		if !m.isImageMode || m.lastImagePrompt == "" {
+			m.setStatus(false, "No image generation to retry")
+			return nil
+		}
+		if m.isThinking {
+			m.setStatus(false, "Wait for the current generation to finish")
+			return nil
+		}
+		// The workflow seed is re-randomized on every submission
+		return tea.Batch(m.sendPrompt(m.lastImagePrompt)...)
+
+	case "as":
+		m.state = types.NormalState
+		if len(args) < 2 {
+			m.setStatus(false, "Usage: :as <role> <message>")
+			return nil
+		}
+		if m.isImageMode {
+			m.setStatus(false, "Custom roles are not available in image mode")
+			return nil
+		}
+		if m.isThinking {
+			m.setStatus(false, "Wait for the current response to finish")
+			return nil
+		}
+		return tea.Batch(m.sendAs(args[0], strings.Join(args[1:], " "))...)
+
+	case "cache":
+		m.state = types.NormalState
+		if len(args) < 1 {
+			m.setStatus(false, "Usage: :cache on|off|clear")
+			return nil
+		}
+		switch args[0] {
+		case "on":
+			if m.responseCache == nil {
+				m.responseCache = cache.New(m.configManager.ResponseCacheDir())
+			}
+			m.setStatus(true, "Response cache on")
+		case "off":
+			// Bypasses the cache for the rest of this run without deleting entries
+			m.responseCache = nil
+			m.setStatus(true, "Response cache off")
+		case "clear":
+			c := cache.New(m.configManager.ResponseCacheDir())
+			return func() tea.Msg {
+				n, err := c.Clear()
+				if err != nil {
+					return types.StatusMsg{Err: fmt.Errorf("failed to clear cache: %v", err)}
+				}
+				return types.StatusMsg{Text: fmt.Sprintf("Cleared %d cached responses", n)}
+			}
+		default:
+			m.setStatus(false, "Usage: :cache on|off|clear")
+		}
+		return nil
+
+	case "bench":
+		m.state = types.NormalState
+		n := 5
+		if len(args) > 0 {
+			var err error
+			if n, err = strconv.Atoi(args[0]); err != nil || n < 1 {
+				m.setStatus(false, "Usage: :bench <runs>")
+				return nil
+			}
 		}
+		return m.startBench(n)
+
+	case "continue":
+		m.state = types.NormalState
+		return tea.Batch(m.continueResponse()...)
+
+	case "retry":
+		m.state = types.NormalState
+		return tea.Batch(m.retryFailed()...)
 
+	case "refresh":
 		m.state = types.NormalState
+		return m.refreshModels()
+
+	case "export-code":
+		m.state = types.NormalState
+		if len(args) < 1 {
+			m.setStatus(false, "Usage: :export-code <dir>")
+			return nil
+		}
+
+		dir := config.ExpandPath(args[0])
+		messages := m.messages
 		return func() tea.Msg {
-			data, err := json.MarshalIndent(m.messages, "", "  ")
+			count, err := ExportCodeBlocks(messages, dir)
 			if err != nil {
-				// In a real app, we'd handle this error properly
-				return nil
+				return types.StatusMsg{Err: fmt.Errorf("export failed: %v", err)}
 			}
+			return types.StatusMsg{Text: fmt.Sprintf("Exported %d code blocks to %s", count, dir)}
+		}
 
-			if err := os.WriteFile(filename, data, 0644); err != nil {
-				// In a real app, we'd handle this error properly
-				return nil
+	case "export":
+		m.state = types.NormalState
+		if len(args) < 1 {
+			m.setStatus(false, "Usage: :export <file.md>")
+			return nil
+		}
+
+		path := exportPath(args[0])
+		messages := m.messages
+		return func() tea.Msg {
+			if err := ExportMarkdown(messages, path); err != nil {
+				return types.StatusMsg{Err: fmt.Errorf("export failed: %v", err)}
 			}
+			return types.StatusMsg{Text: fmt.Sprintf("Exported %d messages to %s", len(messages), path)}
+		}
+
+	case "tab":
+		m.state = types.NormalState
+		if len(args) < 1 {
+			m.setStatus(false, "Usage: :tab <number>")
+			return nil
+		}
+		n, err := strconv.Atoi(args[0])
+		if err != nil || n < 1 || n > len(m.tabs) {
+			m.setStatus(false, fmt.Sprintf("No tab %s (1-%d)", args[0], len(m.tabs)))
+			return nil
+		}
+		m.switchTab(n - 1)
+		return nil
+
+	case "tabnew":
+		m.state = types.NormalState
+		m.newTab(strings.Join(args, " "))
+		return nil
 
+	case "tabname":
+		m.state = types.NormalState
+		if len(args) < 1 {
+			m.setStatus(false, "Usage: :tabname <name>")
 			return nil
 		}
+		m.tabs[m.activeTab].name = strings.Join(args, " ")
+		return nil
+
+	case "tabclose":
+		m.state = types.NormalState
+		m.closeTab()
+		return nil
 
 	case "tldr":
 		m.viewMode = types.TLDRMode
 		// Collapse all messages except the last few
 		for i := range m.messages {
-			if len(m.messages[i].Content) > 100 {
+			if m.collapsible(m.messages[i].Content) {
 				m.messages[i].IsCollapsed = true
 			}
 		}
@@ -250,15 +886,103 @@ func (m *Model) handleCommand(command string) tea.Cmd {
 		m.state = types.NormalState
 		return nil
 
+	case "delete":
+		m.state = types.NormalState
+		if len(args) < 1 {
+			m.setStatus(false, "Usage: :delete <id>")
+			return nil
+		}
+		return m.deleteMessage(args[0])
+
+	case "expand":
+		m.state = types.NormalState
+		id := ""
+		if len(args) > 0 {
+			id = args[0]
+		}
+		return m.toggleCollapse(id)
+
 	case "q", "quit":
-		return tea.Quit
+		return m.quit()
 
 	default:
 		m.state = types.NormalState
+		if alias != "" {
+			m.setStatus(false, fmt.Sprintf("Alias :%s expands to unknown command :%s", alias, cmd))
+		}
 		return nil
 	}
 }
 
+// expandAlias rewrites a command line whose name is an alias, repeatedly so an
+// alias may refer to another one. Extra arguments are appended to the expansion.
+func expandAlias(parts []string, aliases map[string]string) ([]string, error) {
+	chain := []string{parts[0]}
+	for {
+		expansion, ok := aliases[parts[0]]
+		if !ok {
+			return parts, nil
+		}
+		expanded := strings.Fields(expansion)
+		if len(expanded) == 0 {
+			return nil, fmt.Errorf("alias :%s is empty", parts[0])
+		}
+		parts = append(expanded, parts[1:]...)
+		for _, name := range chain {
+			if name == parts[0] {
+				return nil, fmt.Errorf("alias loop :%s", strings.Join(append(chain, parts[0]), " → :"))
+			}
+		}
+		chain = append(chain, parts[0])
+	}
+}
+
+// cycleModel switches to the next (dir > 0) or previous model in the list and saves it
+func (m *Model) cycleModel(dir int) tea.Cmd {
+	if len(m.modelList) == 0 {
+		m.setStatus(false, "No models available, press R to refresh")
+		return nil
+	}
+
+	// A model missing from the list starts the cycle at its first entry
+	next := 0
+	for i, name := range m.modelList {
+		if name == m.modelName {
+			next = (i + dir + len(m.modelList)) % len(m.modelList)
+			break
+		}
+	}
+	if m.modelList[next] == m.modelName {
+		m.setStatus(true, "Model: "+m.modelName+" (only model)")
+		return nil
+	}
+
+	m.modelName = m.modelList[next]
+	m.setStatus(true, "Model: "+m.modelName)
+	return m.configManager.SaveConfig(m.modelName)
+}
+
+// refreshModels re-fetches the model list from Ollama and reports the result on the status line
+func (m *Model) refreshModels() tea.Cmd {
+	m.refreshingModels = true
+	return m.ollamaClient.FetchModels()
+}
+
+// nextID returns the ID for a message appended to the conversation. It
+// follows the message count but skips IDs still in use, which deleting a
+// message from the middle would otherwise hand out twice.
+func (m Model) nextID() string {
+	used := make(map[string]bool, len(m.messages))
+	for _, msg := range m.messages {
+		used[msg.ID] = true
+	}
+	count := len(m.messages)
+	for used[generateID(count)] {
+		count++
+	}
+	return generateID(count)
+}
+
 // generateID generates a unique ID for messages
 func generateID(count int) string {
 	if count == 0 {
@@ -276,6 +1000,21 @@ func generateID(count int) string {
 	return string(rune('a'+first-1)) + string(rune('a'+second))
 }
 
+// formatImageInfo describes the seed, size and workflow of a generated image
+func (m Model) formatImageInfo(info comfyui.ImageInfo) string {
+	var parts []string
+	if info.HasSeed {
+		parts = append(parts, fmt.Sprintf("seed %d", info.Seed))
+	}
+	if info.Width > 0 && info.Height > 0 {
+		parts = append(parts, fmt.Sprintf("%d×%d", info.Width, info.Height))
+	}
+	if m.workflowPath != "" {
+		parts = append(parts, filepath.Base(m.workflowPath))
+	}
+	return strings.Join(parts, " · ")
+}
+
 // generateImage generates an image using ComfyUI
 func (m Model) generateImage(id string, prompt string) tea.Cmd {
 	return func() tea.Msg {
@@ -284,28 +1023,49 @@ func (m Model) generateImage(id string, prompt string) tea.Cmd {
 		
 		// Start a goroutine to forward progress updates IMMEDIATELY
 		go func() {
+			// Keep draining after quit so the ComfyUI client never blocks on the channel
 			for update := range progressChan {
-				m.msgChan <- types.ProgressMsg{ID: id, Update: update}
+				m.emit(types.ProgressMsg{ID: id, Update: update})
 			}
 		}()
 		
 		go func() {
-			m.msgChan <- types.GenerationStartMsg{ID: id}
+			m.emit(types.GenerationStartMsg{ID: id})
 			
 			// Initial status
-			m.msgChan <- types.TokenMsg{ID: id, Token: "Generating image..."}
+			m.emit(types.TokenMsg{ID: id, Token: "Generating image..."})
 
-			result, err := m.comfyUIClient.GenerateImage(m.comfyUIWorkflow, prompt, progressChan)
+			negative := m.workflowSettings().NegativePrompt
+			result, info, err := m.comfyUIClient.GenerateImage(m.comfyUIWorkflow, prompt, negative, progressChan)
 			close(progressChan)
 			
 			if err != nil {
-				m.msgChan <- types.StreamErrorMsg{ID: id, Error: err.Error()}
+				errText := err.Error()
+				var execErr *comfyui.ExecutionError
+				if errors.As(err, &execErr) {
+					errText += " (use :retry-image to resubmit)"
+				}
+				m.emit(types.StreamErrorMsg{ID: id, Error: errText, Err: err})
 				return
 			}
-
-			m.msgChan <- types.TokenMsg{ID: id, Token: "\n\n" + result}
-			m.msgChan <- types.GenerationDoneMsg{ID: id}
+			m.emitImageResult(id, result, info)
 		}()
 		return nil
 	}
 }
+
+// emitImageResult writes the metadata sidecars of a generated image when
+// enabled and reports the result into the reply with the given ID. A
+// sidecar that can't be written is shown on the status line; the image
+// itself is still reported.
+func (m Model) emitImageResult(id, result string, info comfyui.ImageInfo) {
+	if m.imageSidecars {
+		if err := comfyui.WriteSidecars(info, m.workflowPath); err != nil {
+			m.emit(types.StatusMsg{Err: fmt.Errorf("couldn't write image metadata: %w", err)})
+		}
+	}
+
+	m.emit(types.TokenMsg{ID: id, Token: "\n\n" + result + "\n" + m.formatImageInfo(info)})
+	m.emit(types.ImageInfoMsg{ID: id, Info: info})
+	m.emit(types.GenerationDoneMsg{ID: id})
+}
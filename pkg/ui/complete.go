@@ -0,0 +1,141 @@
+package ui
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/thebug/lab/eko/v3/pkg/config"
+)
+
+// commandNames are the commands tab completes while the command name is
+// still being typed
+var commandNames = []string{
+	"affix", "as", "bench", "cache", "clear", "config", "continue", "council",
+	"delete", "diff", "expand", "export", "export-code", "find", "focus",
+	"goto", "load", "log", "meta", "model", "nohlsearch", "numbers", "quit",
+	"quote", "readonly", "refresh", "regenerate", "retry", "retry-image",
+	"save", "seed", "set", "star", "starred", "system", "tab", "tabclose",
+	"tabname", "tabnew", "tag", "tee", "tldr", "verbose", "view", "workflow",
+}
+
+// modelArgCommands are the commands whose arguments are model names
+var modelArgCommands = map[string]bool{
+	"model":   true,
+	"council": true,
+}
+
+// completeCommand handles tab in command mode: it completes the word under the
+// cursor and, when pressed again, cycles through the other matches
+func (m *Model) completeCommand() {
+	line := m.input.Value()
+
+	// Tab right after a completion moves on to the next match. A single
+	// match is completed afresh instead, so tab descends into a directory.
+	if len(m.completions) > 1 && line == m.completionBase+m.completions[m.completionIdx] {
+		m.completionIdx = (m.completionIdx + 1) % len(m.completions)
+		m.input.SetValue(m.completionBase + m.completions[m.completionIdx])
+		m.input.CursorEnd()
+		return
+	}
+
+	m.completions = nil
+	base, candidates := m.completionCandidates(line)
+	word := line[len(base):]
+	for _, candidate := range candidates {
+		if strings.HasPrefix(strings.ToLower(candidate), strings.ToLower(word)) {
+			m.completions = append(m.completions, candidate)
+		}
+	}
+	if len(m.completions) == 0 {
+		return
+	}
+
+	m.completionBase = base
+	m.completionIdx = 0
+	m.input.SetValue(base + m.completions[0])
+	m.input.CursorEnd()
+	if len(m.completions) > 1 {
+		m.setStatus(true, strings.Join(m.completions, "  "))
+	}
+}
+
+// completionCandidates splits a command line into the part kept as typed and
+// the words that may complete the rest
+func (m Model) completionCandidates(line string) (string, []string) {
+	// Still typing the command name
+	if !strings.Contains(line, " ") {
+		return "", m.commandCandidates()
+	}
+
+	fields := strings.Fields(line)
+	base := line[:strings.LastIndex(line, " ")+1]
+	if len(fields) == 0 {
+		return base, nil
+	}
+	word := line[len(base):]
+	switch cmd := fields[0]; {
+	case modelArgCommands[cmd]:
+		return base, m.modelList
+	case cmd == "set" && base == "set ":
+		return base, settableOptions
+	case cmd == "save" || cmd == "load" || cmd == "view":
+		dir := m.sessionDir
+		if dir == "" {
+			dir = "."
+		}
+		return base, fileCandidates(dir, word, ".json")
+	case cmd == "workflow":
+		return base, fileCandidates(m.workflowDirectory(), word, ".json")
+	case cmd == "export" || cmd == "export-code" || cmd == "tee":
+		return base, fileCandidates(".", word, "")
+	}
+	return base, nil
+}
+
+// commandCandidates returns the command names followed by the aliases from
+// the config
+func (m Model) commandCandidates() []string {
+	aliases := make([]string, 0, len(m.commandAliases))
+	for alias := range m.commandAliases {
+		aliases = append(aliases, alias)
+	}
+	sort.Strings(aliases)
+	return append(append([]string(nil), commandNames...), aliases...)
+}
+
+// fileCandidates lists what a file argument may complete to: the entries of
+// root, or of the directory already typed in word, with directories ending in
+// "/". With ext only files with that extension are offered, named without it,
+// the way the command adds it back. Hidden files are offered once word
+// starts with a dot.
+func fileCandidates(root, word, ext string) []string {
+	prefix := word[:strings.LastIndex(word, "/")+1]
+	dir := config.ExpandPath(prefix)
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(root, dir)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+
+	hidden := strings.HasPrefix(word[len(prefix):], ".")
+	var names []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasPrefix(name, ".") && !hidden {
+			continue
+		}
+		switch {
+		case entry.IsDir():
+			names = append(names, prefix+name+"/")
+		case ext == "":
+			names = append(names, prefix+name)
+		case strings.HasSuffix(name, ext):
+			names = append(names, prefix+strings.TrimSuffix(name, ext))
+		}
+	}
+	return names
+}
@@ -0,0 +1,23 @@
+package ui
+
+import "github.com/thebug/lab/eko/v3/pkg/types"
+
+// composeKey abandons whatever mode is active and starts a new message. It
+// is a control key so no mode's text input captures it.
+const composeKey = "ctrl+o"
+
+// compose leaves the current mode the way esc would, dropping its partial
+// input, and enters insert mode with an empty prompt
+func (m *Model) compose() {
+	if m.readOnly {
+		m.setStatus(false, readOnlyHint)
+		return
+	}
+	m.yankInput = ""
+	m.pendingLoad = nil
+	m.completions = nil
+	m.state = types.InsertState
+	m.input.Focus()
+	m.input.Prompt = ""
+	m.input.Reset()
+}
@@ -0,0 +1,47 @@
+package ui
+
+import (
+	"errors"
+	"fmt"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/thebug/lab/eko/v3/pkg/types"
+)
+
+// handleControl answers a request from the control socket. Prompts are sent
+// to the active tab as if typed, queueing behind a running reply.
+func (m *Model) handleControl(msg types.ControlRequest) []tea.Cmd {
+	var reply types.ControlReply
+	var cmds []tea.Cmd
+
+	switch msg.Method {
+	case "send":
+		switch {
+		case msg.Prompt == "":
+			reply.Err = errors.New("empty prompt")
+		case m.readOnly:
+			reply.Err = errors.New(readOnlyHint)
+		case m.offline:
+			reply.Err = errors.New(offlineHint)
+		case m.isThinking:
+			m.promptQueue = append(m.promptQueue, msg.Prompt)
+			reply.Result = map[string]interface{}{"queued": len(m.promptQueue)}
+			cmds = append(cmds, m.updateViewportContent(), m.scrollToBottom())
+		default:
+			cmds = append(cmds, m.sendPrompt(msg.Prompt)...)
+			reply.Result = map[string]interface{}{"queued": 0}
+		}
+
+	case "last_response":
+		reply.Result = m.getLastAssistantMessage()
+
+	case "models":
+		reply.Result = m.modelList
+
+	default:
+		reply.Err = fmt.Errorf("unknown method %q", msg.Method)
+	}
+
+	msg.Reply <- reply
+	return cmds
+}
@@ -0,0 +1,108 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/thebug/lab/eko/v3/pkg/types"
+)
+
+// councilPrompt asks the judge to merge the council's answers into one
+const councilPrompt = "Several assistants answered my last message. Their answers follow. " +
+	"Combine them into a single best answer: keep what they agree on, resolve disagreements " +
+	"and fix mistakes. Do not mention the assistants or that there were several answers."
+
+// sendToCouncil asks every council model for a reply to prompt, then has the
+// current model synthesize them. Each member's reply is kept as a collapsed
+// message above the synthesis.
+func (m *Model) sendToCouncil(role, prompt string) []tea.Cmd {
+	id := m.nextID()
+	m.messages = append(m.messages, types.Message{ID: id, Role: role, Content: prompt, Timestamp: time.Now()})
+	history := m.chatHistory("")
+
+	replies := make([]types.CouncilReply, len(m.councilModels))
+	for i, model := range m.councilModels {
+		replyID := m.nextID()
+		m.messages = append(m.messages, types.Message{ID: replyID, Role: "assistant", IsCollapsed: true, Council: model, Timestamp: time.Now()})
+		replies[i] = types.CouncilReply{ID: replyID, Model: model}
+	}
+
+	judgeID := m.nextID()
+	m.messages = append(m.messages, types.Message{ID: judgeID, Role: "assistant", Model: m.modelName, Seed: m.seed(), Timestamp: time.Now()})
+
+	m.isThinking = true
+	m.currentStreamID = judgeID
+	m.cacheKey = ""
+	return []tea.Cmd{m.askCouncil(m.beginStream(), judgeID, history, replies), m.updateViewportContent(), m.scrollToBottom(), m.spinner.Tick}
+}
+
+// askCouncil queries the council members in parallel and reports their replies
+// on the stream channel, so a council in a background tab still completes.
+// Cancelling ctx aborts the requests.
+func (m Model) askCouncil(ctx context.Context, judgeID string, history []types.Message, replies []types.CouncilReply) tea.Cmd {
+	m.ollamaClient = m.tabClient()
+	return func() tea.Msg {
+		go func() {
+			var wg sync.WaitGroup
+			for i := range replies {
+				wg.Add(1)
+				go func(r *types.CouncilReply) {
+					defer wg.Done()
+					r.Content, r.Err = m.ollamaClient.Chat(ctx, r.Model, history)
+				}(&replies[i])
+			}
+			wg.Wait()
+			m.emit(types.CouncilDoneMsg{ID: judgeID, Replies: replies})
+		}()
+		return nil
+	}
+}
+
+// handleCouncilDone fills in the members' replies and streams the judge's
+// synthesis of those that succeeded
+func (m *Model) handleCouncilDone(msg types.CouncilDoneMsg) []tea.Cmd {
+	// The council was cancelled while it was deliberating
+	if m.currentStreamID != msg.ID {
+		return nil
+	}
+
+	var answers strings.Builder
+	answers.WriteString(councilPrompt)
+	answered, failed := 0, 0
+	for _, reply := range msg.Replies {
+		for i := range m.messages {
+			if m.messages[i].ID != reply.ID {
+				continue
+			}
+			if reply.Err != nil {
+				m.messages[i].Content = fmt.Sprintf("Error: %v", reply.Err)
+			} else {
+				m.messages[i].Content = reply.Content
+			}
+		}
+		if reply.Err != nil || strings.TrimSpace(reply.Content) == "" {
+			failed++
+			continue
+		}
+		answered++
+		fmt.Fprintf(&answers, "\n\n### Answer %d\n%s", answered, reply.Content)
+	}
+
+	if answered == 0 {
+		m.endStream()
+		m.messages[len(m.messages)-1].Content = "Error: no council member answered"
+		return append([]tea.Cmd{m.updateViewportContent()}, m.dispatchQueuedPrompt()...)
+	}
+	if failed > 0 {
+		m.setStatus(false, fmt.Sprintf("%d of %d council members failed", failed, len(msg.Replies)))
+	}
+
+	// The instruction and answers are sent to the judge but not added to the conversation
+	messages := append(m.chatHistory(msg.ID), types.Message{Role: "user", Content: answers.String()})
+	m.streaming = true
+	return []tea.Cmd{m.streamInto(m.beginStream(), msg.ID, messages), m.updateViewportContent()}
+}
@@ -0,0 +1,83 @@
+package ui
+
+import (
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/thebug/lab/eko/v3/pkg/types"
+)
+
+// handleDeleteState collects the two-character ID typed after d and deletes
+// that message once it is complete
+func (m *Model) handleDeleteState(msg tea.KeyMsg) tea.Cmd {
+	switch key := msg.String(); {
+	case key == "esc":
+		m.deleteInput = ""
+		m.state = types.NormalState
+	case key == "backspace":
+		if len(m.deleteInput) > 0 {
+			m.deleteInput = m.deleteInput[:len(m.deleteInput)-1]
+		}
+	case len(key) == 1:
+		m.deleteInput += key
+		if len(m.deleteInput) == 2 {
+			id := m.deleteInput
+			m.deleteInput = ""
+			m.state = types.NormalState
+			return m.deleteMessage(id)
+		}
+	}
+	return nil
+}
+
+// deleteMessage removes the message with the given ID so it is no longer
+// sent as context. With delete_with_reply, deleting a prompt also deletes the
+// replies that follow it. u brings them back.
+func (m *Model) deleteMessage(id string) tea.Cmd {
+	idx := -1
+	for i, msg := range m.messages {
+		if msg.ID == id {
+			idx = i
+			break
+		}
+	}
+	if idx < 0 {
+		m.setStatus(false, "No message with ID "+id)
+		return nil
+	}
+
+	end := idx + 1
+	if m.deleteWithReply && m.messages[idx].Role != "assistant" {
+		// Council replies and their synthesis all answer the one prompt
+		for end < len(m.messages) && m.messages[end].Role == "assistant" {
+			end++
+		}
+	}
+	if m.isThinking {
+		for _, msg := range m.messages[idx:end] {
+			if msg.ID == m.currentStreamID {
+				m.setStatus(false, "Wait for the current response to finish before deleting it")
+				return nil
+			}
+		}
+	}
+
+	m.pushUndo()
+	deleted := make([]string, 0, end-idx)
+	for _, msg := range m.messages[idx:end] {
+		deleted = append(deleted, msg.ID)
+		RemoveCodeBlocks(msg.ID)
+		if msg.ID == m.failedID {
+			m.failedID = ""
+		}
+		if msg.ID == m.hotID {
+			m.hotID = ""
+		}
+	}
+	if _, exists := GetCodeBlock(m.focusedBlock); !exists {
+		m.focusedBlock = ""
+	}
+	m.messages = append(m.messages[:idx:idx], m.messages[end:]...)
+	m.setStatus(true, "Deleted "+strings.Join(deleted, ", ")+" (u to undo)")
+	return m.updateViewportContent()
+}
@@ -0,0 +1,160 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/thebug/lab/eko/v3/pkg/types"
+)
+
+// diffOp is the kind of change a diff line represents
+type diffOp int
+
+const (
+	diffEqual diffOp = iota
+	diffAdded
+	diffRemoved
+)
+
+// diffLine is one line of a line-level diff
+type diffLine struct {
+	Op   diffOp
+	Text string
+}
+
+// diffLines computes a line-level diff from a to b using the longest common subsequence
+func diffLines(a, b []string) []diffLine {
+	// lcs[i][j] holds the LCS length of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var result []diffLine
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			result = append(result, diffLine{Op: diffEqual, Text: a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			result = append(result, diffLine{Op: diffRemoved, Text: a[i]})
+			i++
+		default:
+			result = append(result, diffLine{Op: diffAdded, Text: b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		result = append(result, diffLine{Op: diffRemoved, Text: a[i]})
+	}
+	for ; j < len(b); j++ {
+		result = append(result, diffLine{Op: diffAdded, Text: b[j]})
+	}
+	return result
+}
+
+// findMessage looks up a message by its two-character ID
+func (m Model) findMessage(id string) (types.Message, bool) {
+	for _, msg := range m.messages {
+		if msg.ID == id {
+			return msg, true
+		}
+	}
+	return types.Message{}, false
+}
+
+// openDiff computes the diff between two messages and shows it in the overlay
+func (m *Model) openDiff(idA, idB string) {
+	a, okA := m.findMessage(idA)
+	b, okB := m.findMessage(idB)
+	if !okA || !okB {
+		m.state = types.NormalState
+		m.setStatus(false, "Invalid message ID")
+		return
+	}
+
+	m.diff = diffLines(strings.Split(a.Content, "\n"), strings.Split(b.Content, "\n"))
+	m.diffTitle = fmt.Sprintf("diff %s → %s", idA, idB)
+	m.diffOffset = 0
+	m.state = types.DiffState
+}
+
+// handleDiffState handles scrolling and closing the diff overlay
+func (m *Model) handleDiffState(msg tea.KeyMsg) tea.Cmd {
+	maxOffset := len(m.diff) - m.overlayPageSize()
+	if maxOffset < 0 {
+		maxOffset = 0
+	}
+
+	switch msg.String() {
+	case "j", "down":
+		if m.diffOffset < maxOffset {
+			m.diffOffset++
+		}
+	case "k", "up":
+		if m.diffOffset > 0 {
+			m.diffOffset--
+		}
+	case "G":
+		m.diffOffset = maxOffset
+	case "g":
+		m.diffOffset = 0
+	case "esc", "q":
+		m.diff = nil
+		m.state = types.NormalState
+	}
+	return nil
+}
+
+// overlayPageSize is the number of lines visible below an overlay's title
+func (m Model) overlayPageSize() int {
+	size := m.height - 3
+	if size < 1 {
+		size = 1
+	}
+	return size
+}
+
+// renderDiff renders the diff overlay with removed lines in red and added lines in green
+func (m Model) renderDiff() string {
+	removedStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#FF0000"))
+	addedStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#00FF00"))
+	equalStyle := lipgloss.NewStyle().Foreground(defaultColor)
+
+	var b strings.Builder
+	b.WriteString(lipgloss.NewStyle().Foreground(accentColor).Render(m.diffTitle))
+	b.WriteString(" (j/k to scroll, esc to close)\n\n")
+
+	end := m.diffOffset + m.overlayPageSize()
+	if end > len(m.diff) {
+		end = len(m.diff)
+	}
+	for _, line := range m.diff[m.diffOffset:end] {
+		switch line.Op {
+		case diffRemoved:
+			b.WriteString(removedStyle.Render("- " + line.Text))
+		case diffAdded:
+			b.WriteString(addedStyle.Render("+ " + line.Text))
+		default:
+			b.WriteString(equalStyle.Render("  " + line.Text))
+		}
+		b.WriteString("\n")
+	}
+
+	return b.String()
+}
@@ -0,0 +1,78 @@
+package ui
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/thebug/lab/eko/v3/pkg/types"
+)
+
+// exportPath adds the .md extension when the name has none, the way :save
+// adds .json
+func exportPath(name string) string {
+	if !strings.HasSuffix(name, ".md") {
+		name += ".md"
+	}
+	return name
+}
+
+// ExportMarkdown writes the conversation to path as GitHub-flavored Markdown
+func ExportMarkdown(messages []types.Message, path string) error {
+	return os.WriteFile(path, []byte(conversationMarkdown(messages)), 0644)
+}
+
+// conversationMarkdown renders messages as Markdown for sharing. User turns
+// become **You:** blocks, replies are kept as written, and each message is
+// preceded by its timestamp in an HTML comment.
+func conversationMarkdown(messages []types.Message) string {
+	var b strings.Builder
+	for i, msg := range messages {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		if !msg.Timestamp.IsZero() {
+			fmt.Fprintf(&b, "<!-- %s -->\n", msg.Timestamp.Format("2006-01-02 15:04:05"))
+		}
+		fmt.Fprintf(&b, "**%s:**\n\n", speakerLabel(msg))
+		b.WriteString(strings.TrimSpace(markdownFences(msg)))
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// speakerLabel names who wrote msg in an export
+func speakerLabel(msg types.Message) string {
+	switch {
+	case msg.Role == "user":
+		return "You"
+	case msg.Council != "":
+		return msg.Council
+	case msg.Model != "":
+		return msg.Model
+	case msg.Role == "":
+		return "Unknown"
+	default:
+		return strings.ToUpper(msg.Role[:1]) + msg.Role[1:]
+	}
+}
+
+// markdownFences rewrites each code block in msg as a plain triple-backtick
+// fence built from its stored types.CodeBlock, so the export carries the
+// code itself rather than the numbered, highlighted card shown on screen.
+// Blocks that were never rendered are rebuilt from the raw match.
+func markdownFences(msg types.Message) string {
+	index := 0
+	return codeBlockRegex.ReplaceAllStringFunc(msg.Content, func(match string) string {
+		block, ok := GetCodeBlock(generateCodeBlockID(msg.ID, index))
+		index++
+		if !ok || block.MessageID != msg.ID {
+			parts := codeBlockRegex.FindStringSubmatch(match)
+			block = types.CodeBlock{
+				Language: strings.TrimSpace(parts[1]),
+				Content:  trimCodeBlock(parts[2]),
+			}
+		}
+		return "```" + block.Language + "\n" + block.Content + "\n```"
+	})
+}
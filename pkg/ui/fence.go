@@ -0,0 +1,18 @@
+package ui
+
+// codeFence is the template alt+c inserts. The input is a single line and
+// turns newlines into spaces, so the fence stays on one line; models still read
+// the text between the backticks as code.
+const codeFence = "```  ```"
+
+// codeFenceCursor is where the cursor lands inside codeFence
+const codeFenceCursor = 4
+
+// insertCodeFence puts an empty code fence at the cursor and moves the cursor
+// inside it, ready to paste code
+func (m *Model) insertCodeFence() {
+	value := []rune(m.input.Value())
+	pos := m.input.Position()
+	m.input.SetValue(string(value[:pos]) + codeFence + string(value[pos:]))
+	m.input.SetCursor(pos + codeFenceCursor)
+}
@@ -0,0 +1,26 @@
+package ui
+
+import tea "github.com/charmbracelet/bubbletea"
+
+// focusStart returns the index of the first message of the last exchange: the
+// last user message, or the first message when there is none
+func (m Model) focusStart() int {
+	for i := len(m.messages) - 1; i >= 0; i-- {
+		if m.messages[i].Role == "user" {
+			return i
+		}
+	}
+	return 0
+}
+
+// toggleFocus switches between showing only the last exchange and the whole
+// conversation. Hidden messages are still sent to the model.
+func (m *Model) toggleFocus() tea.Cmd {
+	m.focusLast = !m.focusLast
+	if m.focusLast {
+		m.setStatus(true, "Showing the last exchange only")
+	} else {
+		m.setStatus(true, "Showing all messages")
+	}
+	return tea.Batch(m.updateViewportContent(), m.scrollToBottom())
+}
@@ -0,0 +1,94 @@
+package ui
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/thebug/lab/eko/v3/pkg/types"
+)
+
+// connState is what is known about the connection to Ollama
+type connState int
+
+const (
+	connUnknown connState = iota // Not checked yet
+	connUp
+	connDown
+)
+
+var (
+	connDotStyles = map[connState]lipgloss.Style{
+		connUnknown: lipgloss.NewStyle().Foreground(lipgloss.Color("#808080")),
+		connUp:      lipgloss.NewStyle().Foreground(lipgloss.Color("#00FF00")),
+		connDown:    lipgloss.NewStyle().Foreground(lipgloss.Color("#FF0000")),
+	}
+	footerStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#888888"))
+)
+
+// setOllamaConn records whether Ollama answered
+func (m *Model) setOllamaConn(up bool) {
+	if up {
+		m.ollamaConn = connUp
+	} else {
+		m.ollamaConn = connDown
+	}
+}
+
+// noteOllamaReply records the connection state a request to Ollama revealed:
+// only a failed connection means it is down, an error status still answered
+func (m *Model) noteOllamaReply(err error) {
+	var urlErr *url.Error
+	m.setOllamaConn(!errors.As(err, &urlErr))
+}
+
+// renderStatusFooter renders the chat footer: the Ollama connection, the
+// model, whether a reply is being generated and the speed and size of the
+// latest reply on the left, and the images waiting for a ComfyUI slot and the
+// queued prompts on the right
+func (m Model) renderStatusFooter() string {
+	state := "idle"
+	if m.isThinking {
+		// Thinking until the first token arrives
+		state = "thinking"
+		if i := m.messageIndex(m.currentStreamID); i >= 0 && m.messages[i].Content != "" {
+			state = "streaming"
+		}
+	}
+	status := " " + m.modelName + " · " + state
+	if meta := m.lastReplyMeta(); meta != nil {
+		status += " · " + formatTokenStats(*meta)
+	}
+	left := connDotStyles[m.ollamaConn].Render("●") + footerStyle.Render(status)
+
+	var tags []string
+	if waiting := m.comfyUIClient.Waiting(); waiting > 0 {
+		tags = append(tags, renderFooterTag(waiting, "waiting"))
+	}
+	if len(m.promptQueue) > 0 {
+		tags = append(tags, renderFooterTag(len(m.promptQueue), "queued"))
+	}
+	right := strings.Join(tags, " ")
+
+	gap := m.width - ansi.StringWidth(left) - ansi.StringWidth(right)
+	if gap < 1 {
+		return ansi.Truncate(left+" "+right, m.width, "…")
+	}
+	return left + strings.Repeat(" ", gap) + right
+}
+
+// lastReplyMeta returns Ollama's statistics for the latest reply, or nil while
+// it is being generated or when it has none, e.g. an image or a cached reply
+func (m Model) lastReplyMeta() *types.ResponseMeta {
+	for i := len(m.messages) - 1; i >= 0; i-- {
+		if m.messages[i].Role == "assistant" {
+			if m.messages[i].ID == m.currentStreamID {
+				return nil
+			}
+			return m.messages[i].Meta
+		}
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package ui
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+var indexStyle = lipgloss.NewStyle().Foreground(subtleColor)
+
+// offsetsToLines converts the byte offsets at which messages start in content
+// to line numbers, keeping -1 for messages that were not rendered
+func offsetsToLines(content string, offsets []int) []int {
+	lines := make([]int, len(offsets))
+	line, pos := 0, 0
+	for i, offset := range offsets {
+		if offset < 0 {
+			lines[i] = -1
+			continue
+		}
+		// Offsets grow with the message index, so counting resumes where it stopped
+		line += strings.Count(content[pos:offset], "\n")
+		pos = offset
+		lines[i] = line
+	}
+	return lines
+}
+
+// gotoMessage scrolls to the message with the given 1-based number, as shown
+// by :numbers
+func (m *Model) gotoMessage(arg string) tea.Cmd {
+	n, err := strconv.Atoi(arg)
+	if err != nil {
+		m.setStatus(false, "Usage: :goto <n>")
+		return nil
+	}
+	if n < 1 || n > len(m.messages) || n > len(m.messageLines) {
+		m.setStatus(false, fmt.Sprintf("No message %d", n))
+		return nil
+	}
+	line := m.messageLines[n-1]
+	if line < 0 {
+		m.setStatus(false, fmt.Sprintf("Message %d is hidden by the current view", n))
+		return nil
+	}
+	m.viewport.SetYOffset(line)
+	return nil
+}
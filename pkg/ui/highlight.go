@@ -0,0 +1,96 @@
+package ui
+
+import (
+	"strings"
+
+	"github.com/alecthomas/chroma/v2"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// codeColor is the color of plain code, the code block's orange
+var codeColor = lipgloss.Color("#fe3f01")
+
+// codeStyle is the chroma style code blocks are colored with. Its
+// backgrounds are ignored in favor of the code block's own.
+const codeStyle = "monokai"
+
+// codeLexer returns the lexer for a fence's language tag, or, when the fence
+// has none or chroma doesn't know it, the lexer chroma's analysers pick from
+// the code itself. Nil means the language couldn't be told.
+func codeLexer(content, language string) chroma.Lexer {
+	var lexer chroma.Lexer
+	if language != "" {
+		lexer = lexers.Get(language)
+	}
+	if lexer == nil {
+		lexer = lexers.Analyse(content)
+	}
+	if lexer == nil {
+		return nil
+	}
+	return chroma.Coalesce(lexer)
+}
+
+// tokenStyleFor maps the chroma style entry of a token type to lipgloss, drawn
+// on background. Tokens the style leaves in its plain text color keep
+// codeColor.
+func tokenStyleFor(style *chroma.Style, tokenType chroma.TokenType, background lipgloss.Color) lipgloss.Style {
+	entry := style.Get(tokenType)
+	out := lipgloss.NewStyle().Foreground(codeColor).Background(background)
+	if entry.Colour.IsSet() && entry.Colour != style.Get(chroma.Text).Colour {
+		out = out.Foreground(lipgloss.Color(entry.Colour.String()))
+	}
+	if entry.Bold == chroma.Yes {
+		out = out.Bold(true)
+	}
+	if entry.Italic == chroma.Yes {
+		out = out.Italic(true)
+	}
+	if entry.Underline == chroma.Yes {
+		out = out.Underline(true)
+	}
+	return out
+}
+
+// highlightCode colors code in language with chroma, detecting the language
+// when the fence has none. Every piece is drawn on background, so the colors
+// don't cut holes in the block's background. Code in a language that can't be
+// told is returned as is.
+func highlightCode(content, language string, background lipgloss.Color) string {
+	// Return plain text if content is empty
+	if content == "" {
+		return content
+	}
+	lexer := codeLexer(content, language)
+	if lexer == nil {
+		return content
+	}
+	iterator, err := lexer.Tokenise(nil, content)
+	if err != nil {
+		return content
+	}
+
+	style := styles.Get(codeStyle)
+	cache := make(map[chroma.TokenType]lipgloss.Style)
+	var out strings.Builder
+	for _, token := range iterator.Tokens() {
+		tokenStyle, ok := cache[token.Type]
+		if !ok {
+			tokenStyle = tokenStyleFor(style, token.Type, background)
+			cache[token.Type] = tokenStyle
+		}
+		// Styling several lines at once would pad them to the same width
+		for i, line := range strings.Split(token.Value, "\n") {
+			if i > 0 {
+				out.WriteByte('\n')
+			}
+			if line != "" {
+				out.WriteString(tokenStyle.Render(line))
+			}
+		}
+	}
+	// Lexers end the last line with a newline the code didn't have
+	return strings.TrimSuffix(out.String(), "\n")
+}
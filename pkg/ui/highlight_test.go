@@ -0,0 +1,52 @@
+package ui
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/muesli/termenv"
+)
+
+func TestHighlightCode(t *testing.T) {
+	lipgloss.SetColorProfile(termenv.TrueColor)
+	t.Cleanup(func() { lipgloss.SetColorProfile(termenv.Ascii) })
+	background := lipgloss.Color("#1a1a1a")
+
+	tests := []struct {
+		name     string
+		content  string
+		language string
+		colored  bool
+	}{
+		{"tagged", "package main\n\nfunc main() {\n    return\n}", "go", true},
+		{"untagged shebang", "#!/bin/bash\necho hi", "", true},
+		{"unknown tag analysed", "#!/usr/bin/env bash\necho hi", "notalanguage", true},
+		{"untold", "just some words", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := highlightCode(tt.content, tt.language, background)
+			// Coloring never changes the text or its lines
+			if plain := ansi.Strip(got); plain != tt.content {
+				t.Errorf("text = %q, want %q", plain, tt.content)
+			}
+			if colored := got != tt.content; colored != tt.colored {
+				t.Errorf("colored = %v, want %v: %q", colored, tt.colored, got)
+			}
+		})
+	}
+}
+
+func TestHighlightCodeKeepsBackground(t *testing.T) {
+	lipgloss.SetColorProfile(termenv.TrueColor)
+	t.Cleanup(func() { lipgloss.SetColorProfile(termenv.Ascii) })
+
+	got := highlightCode("x := 1 // one\ny := \"two\"", "go", lipgloss.Color("#1a1a1a"))
+	for _, line := range strings.Split(got, "\n") {
+		if !strings.Contains(line, "48;2;26;26;26") {
+			t.Errorf("line drawn without the block background: %q", line)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package ui
+
+import (
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// defaultHistorySize is how many commands are remembered when history_size
+// isn't set
+const defaultHistorySize = 100
+
+// historyLimit returns how many commands the history keeps
+func (m Model) historyLimit() int {
+	if m.historySize > 0 {
+		return m.historySize
+	}
+	return defaultHistorySize
+}
+
+// recordCommand adds a command line to the history and saves it. Repeating
+// the previous command doesn't add it again, and the oldest commands are
+// dropped once the history is full.
+func (m *Model) recordCommand(line string) tea.Cmd {
+	m.historyPos = 0
+	line = strings.TrimSpace(line)
+	if line == "" || len(m.commandHistory) > 0 && m.commandHistory[len(m.commandHistory)-1] == line {
+		return nil
+	}
+
+	m.commandHistory = append(m.commandHistory, line)
+	if over := len(m.commandHistory) - m.historyLimit(); over > 0 {
+		m.commandHistory = append([]string(nil), m.commandHistory[over:]...)
+	}
+	return m.configManager.SaveHistory(m.commandHistory)
+}
+
+// browseHistory steps back (-1) or forward (1) through the command history
+// into the input. Stepping forward past the newest command restores what was
+// being typed.
+func (m *Model) browseHistory(dir int) {
+	pos := m.historyPos - dir
+	if pos < 0 || pos > len(m.commandHistory) || pos == m.historyPos {
+		return
+	}
+	if m.historyPos == 0 {
+		m.historyDraft = m.input.Value()
+	}
+
+	m.historyPos = pos
+	if pos == 0 {
+		m.input.SetValue(m.historyDraft)
+	} else {
+		m.input.SetValue(m.commandHistory[len(m.commandHistory)-pos])
+	}
+	m.input.CursorEnd()
+}
@@ -0,0 +1,37 @@
+package ui
+
+import (
+	"fmt"
+	"os"
+)
+
+// imagePrefix starts a prompt that generates an image through ComfyUI in a
+// chat conversation
+const imagePrefix = "/img "
+
+// imageStream reports whether the running generation is an image, either in
+// image mode or from a /img prompt
+func (m Model) imageStream() bool {
+	if m.isImageMode {
+		return true
+	}
+	i := m.messageIndex(m.currentStreamID)
+	return i >= 0 && m.messages[i].Image
+}
+
+// loadWorkflow reads the configured workflow the first time a chat
+// conversation generates an image
+func (m *Model) loadWorkflow() error {
+	if len(m.comfyUIWorkflow) > 0 {
+		return nil
+	}
+	if m.workflowPath == "" {
+		return fmt.Errorf("no workflow configured")
+	}
+	workflow, err := os.ReadFile(m.workflowPath)
+	if err != nil {
+		return fmt.Errorf("can't read workflow: %w", err)
+	}
+	m.comfyUIWorkflow = workflow
+	return nil
+}
@@ -0,0 +1,74 @@
+package ui
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/thebug/lab/eko/v3/pkg/comfyui"
+	"github.com/thebug/lab/eko/v3/pkg/types"
+)
+
+// drain feeds everything waiting on the stream channel through Update, which
+// takes one message from it per call
+func drain(t *testing.T, m Model) Model {
+	t.Helper()
+	for len(m.msgChan) > 0 {
+		next, _ := m.Update(types.RedrawMsg{})
+		m = next.(Model)
+	}
+	return m
+}
+
+// imageReplyModel returns a model generating the image reply "ba"
+func imageReplyModel(t *testing.T) Model {
+	m := newTestModel(t)
+	m.messages = []types.Message{
+		{ID: "aa", Role: "user", Content: "a cat", Image: true},
+		{ID: "ba", Role: "assistant", Content: "Generating image...", Image: true},
+	}
+	m.isThinking = true
+	m.currentStreamID = "ba"
+	m.imageSidecars = true
+	return m
+}
+
+func TestImageSidecarWritten(t *testing.T) {
+	m := imageReplyModel(t)
+	image := filepath.Join(t.TempDir(), "eko-img.png")
+	info := comfyui.ImageInfo{Seed: 7, HasSeed: true, Prompt: "a cat", Files: []string{image}}
+
+	m.emitImageResult("ba", "Image generated: "+image, info)
+	m = drain(t, m)
+
+	if _, err := os.Stat(strings.TrimSuffix(image, ".png") + ".json"); err != nil {
+		t.Errorf("sidecar not written: %v", err)
+	}
+	if m.isThinking {
+		t.Error("generation still running after the result")
+	}
+	if got := m.imageSeeds["ba"]; got != 7 {
+		t.Errorf("seed = %d, want 7", got)
+	}
+}
+
+func TestImageSidecarErrorReachesStatusLine(t *testing.T) {
+	m := imageReplyModel(t)
+	image := filepath.Join(t.TempDir(), "missing", "eko-img.png")
+	info := comfyui.ImageInfo{Files: []string{image}}
+
+	m.emitImageResult("ba", "Image generated: "+image, info)
+	m = drain(t, m)
+
+	if !strings.HasPrefix(m.status, "✖ couldn't write image metadata") {
+		t.Errorf("status = %q, want the sidecar error", m.status)
+	}
+	// The image is still reported
+	if got := m.messages[1].Content; !strings.Contains(got, "Image generated: "+image) {
+		t.Errorf("reply = %q", got)
+	}
+	if m.isThinking {
+		t.Error("generation still running after the result")
+	}
+}
@@ -0,0 +1,123 @@
+package ui
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/charmbracelet/x/ansi"
+)
+
+// listItemRegex matches a markdown list item: indentation, a "-", "*" or "+"
+// bullet or a "1." / "1)" number, and the item text
+var listItemRegex = regexp.MustCompile(`^([ \t]*)([-*+]|\d+[.)])[ \t]+(.*)$`)
+
+// listBullets are drawn for unordered items, by nesting level
+var listBullets = []string{"•", "◦", "▪"}
+
+// listIndent is how far each nesting level is indented
+const listIndent = 2
+
+// RenderLists draws markdown lists in content with bullets and nested
+// indentation, wrapping each item to width so wrapped lines line up under the
+// item text. Code blocks are left untouched.
+func RenderLists(content string, width int) string {
+	return mapProse(content, func(text string) string {
+		return renderListLines(text, width)
+	})
+}
+
+// listItem is a list entry being collected, including continuation lines
+type listItem struct {
+	level  int
+	marker string
+	text   string
+}
+
+// renderListLines renders the lists in text that contains no code fences
+func renderListLines(text string, width int) string {
+	lines := strings.Split(text, "\n")
+	out := make([]string, 0, len(lines))
+
+	// Indentation widths of the enclosing items; its length is the nesting level
+	var indents []int
+	var item *listItem
+	flush := func() {
+		if item != nil {
+			out = append(out, formatListItem(*item, width)...)
+			item = nil
+		}
+	}
+
+	for _, line := range lines {
+		if match := listItemRegex.FindStringSubmatch(line); match != nil {
+			flush()
+			indent := indentWidth(match[1])
+			// Close items indented as deep or deeper; what remains encloses this one
+			for len(indents) > 0 && indents[len(indents)-1] >= indent {
+				indents = indents[:len(indents)-1]
+			}
+			level := len(indents)
+			indents = append(indents, indent)
+
+			marker := match[2]
+			if marker == "-" || marker == "*" || marker == "+" {
+				marker = listBullets[level%len(listBullets)]
+			}
+			item = &listItem{level: level, marker: marker, text: match[3]}
+			continue
+		}
+
+		trimmed := strings.TrimSpace(line)
+		if item != nil && trimmed != "" && indentWidth(line) > indents[len(indents)-1] {
+			// An indented line under an item continues its text
+			item.text += " " + trimmed
+			continue
+		}
+
+		flush()
+		if trimmed != "" {
+			// Any other text ends the list
+			indents = nil
+		}
+		out = append(out, line)
+	}
+	flush()
+	return strings.Join(out, "\n")
+}
+
+// formatListItem wraps an item's text after its marker and indents the
+// wrapped lines to start under the text
+func formatListItem(item listItem, width int) []string {
+	prefix := strings.Repeat(" ", item.level*listIndent) + item.marker + " "
+	hang := strings.Repeat(" ", ansi.StringWidth(prefix))
+
+	textWidth := width - len(hang)
+	if textWidth < 10 {
+		textWidth = 10
+	}
+	wrapped := strings.Split(ansi.Wrap(item.text, textWidth, ""), "\n")
+	for i, line := range wrapped {
+		if i == 0 {
+			wrapped[i] = prefix + line
+		} else {
+			wrapped[i] = hang + line
+		}
+	}
+	return wrapped
+}
+
+// indentWidth measures leading whitespace, counting a tab as four spaces
+func indentWidth(s string) int {
+	width := 0
+	for _, r := range s {
+		switch r {
+		case ' ':
+			width++
+		case '\t':
+			width += 4
+		default:
+			return width
+		}
+	}
+	return width
+}
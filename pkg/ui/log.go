@@ -0,0 +1,109 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/thebug/lab/eko/v3/pkg/debuglog"
+	"github.com/thebug/lab/eko/v3/pkg/types"
+)
+
+// logRefreshInterval is how often the :log overlay rereads the log
+const logRefreshInterval = time.Second
+
+// openLog shows the end of the debug log in an overlay that follows new lines
+func (m *Model) openLog() tea.Cmd {
+	m.state = types.LogState
+	m.loadLog(true)
+	if m.logTicking {
+		return nil
+	}
+	m.logTicking = true
+	return logTick()
+}
+
+// logTick schedules the next refresh of the :log overlay
+func logTick() tea.Cmd {
+	return tea.Tick(logRefreshInterval, func(time.Time) tea.Msg {
+		return types.LogTickMsg{}
+	})
+}
+
+// refreshLog rereads the log while the overlay is open, staying at the end
+// if the reader is there
+func (m *Model) refreshLog() tea.Cmd {
+	if m.state != types.LogState {
+		m.logTicking = false
+		return nil
+	}
+	m.loadLog(m.logOffset >= m.logMaxOffset())
+	return logTick()
+}
+
+// loadLog reads the log, moving to its end when follow is set
+func (m *Model) loadLog(follow bool) {
+	m.logLines, m.logErr = debuglog.Tail()
+	if follow || m.logOffset > m.logMaxOffset() {
+		m.logOffset = m.logMaxOffset()
+	}
+}
+
+// logMaxOffset is the offset that shows the last page of the log
+func (m Model) logMaxOffset() int {
+	maxOffset := len(m.logLines) - m.overlayPageSize()
+	if maxOffset < 0 {
+		maxOffset = 0
+	}
+	return maxOffset
+}
+
+// handleLogState handles scrolling and closing the log overlay
+func (m *Model) handleLogState(msg tea.KeyMsg) {
+	switch msg.String() {
+	case "j", "down":
+		if m.logOffset < m.logMaxOffset() {
+			m.logOffset++
+		}
+	case "k", "up":
+		if m.logOffset > 0 {
+			m.logOffset--
+		}
+	case "G":
+		m.logOffset = m.logMaxOffset()
+	case "g":
+		m.logOffset = 0
+	case "esc", "q":
+		m.logLines = nil
+		m.state = types.NormalState
+	}
+}
+
+// renderLog renders the log overlay, one log line per row
+func (m Model) renderLog() string {
+	var b strings.Builder
+	b.WriteString(lipgloss.NewStyle().Foreground(accentColor).Render(debuglog.Path()))
+	b.WriteString(" (j/k to scroll, G to follow, esc to close)\n\n")
+
+	if m.logErr != nil {
+		b.WriteString(lipgloss.NewStyle().Foreground(lipgloss.Color("#FF0000")).Render(fmt.Sprintf("Can't read the log: %v", m.logErr)))
+		return b.String()
+	}
+	if len(m.logLines) == 0 {
+		b.WriteString(lipgloss.NewStyle().Foreground(subtleColor).Render("Nothing logged yet"))
+		return b.String()
+	}
+
+	end := m.logOffset + m.overlayPageSize()
+	if end > len(m.logLines) {
+		end = len(m.logLines)
+	}
+	for _, line := range m.logLines[m.logOffset:end] {
+		b.WriteString(ansi.Truncate(line, m.width, "…"))
+		b.WriteString("\n")
+	}
+	return b.String()
+}
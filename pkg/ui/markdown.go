@@ -0,0 +1,48 @@
+package ui
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/x/ansi"
+)
+
+// renderMarkdown renders the markdown of a reply around its fenced code
+// blocks: math when enabled, lists and blockquotes, word wrapping, then search
+// matches. The code blocks come out byte-for-byte as they went in, so
+// ReplaceCodeBlocksInContent, which must run afterwards, still finds them and
+// gives them yankable IDs in order.
+func (m Model) renderMarkdown(content string, width int) string {
+	return mapProse(content, func(text string) string {
+		if m.renderMath {
+			text = renderMathSpans(text)
+		}
+		text = renderListLines(text, width)
+		text = wrapProse(renderQuoteLines(text, width), width)
+		return m.highlightMatches(text)
+	})
+}
+
+// wrapProse word-wraps each line of text to width display columns, counting
+// wide characters as two. Words are only broken when longer than a whole
+// line, so URLs stay intact where they fit; CJK text, which has no spaces,
+// breaks between characters. Lines that already fit are left alone.
+func wrapProse(text string, width int) string {
+	if width < 1 {
+		return text
+	}
+	return ansi.Wrap(text, width, "")
+}
+
+// mapProse applies render to the text between the fenced code blocks in
+// content and leaves the blocks themselves untouched
+func mapProse(content string, render func(string) string) string {
+	var b strings.Builder
+	last := 0
+	for _, loc := range codeBlockRegex.FindAllStringIndex(content, -1) {
+		b.WriteString(render(content[last:loc[0]]))
+		b.WriteString(content[loc[0]:loc[1]])
+		last = loc[1]
+	}
+	b.WriteString(render(content[last:]))
+	return b.String()
+}
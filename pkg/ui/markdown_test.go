@@ -0,0 +1,61 @@
+package ui
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/x/ansi"
+)
+
+func TestRenderMarkdownMixedProseAndCode(t *testing.T) {
+	m := newTestModel(t)
+	goBlock := "```go\n// * not a list\n// > not a quote\nfunc f() { return strings.Repeat(\"a very long line that must not be wrapped\", 4) }\n```"
+	shBlock := "```\n  - indented: kept\n```"
+	content := strings.Join([]string{
+		"Intro paragraph that is long enough to need wrapping at the narrow width used by this test.",
+		"",
+		"- first item",
+		"  - nested item",
+		"",
+		goBlock,
+		"",
+		"> a quoted remark",
+		"",
+		shBlock,
+		"Closing words.",
+	}, "\n")
+	const width = 40
+
+	got := m.renderMarkdown(content, width)
+
+	// Code blocks come out byte-for-byte, in order
+	if i, j := strings.Index(got, goBlock), strings.Index(got, shBlock); i < 0 || j < 0 || i > j {
+		t.Fatalf("code blocks changed or reordered:\n%s", got)
+	}
+
+	prose := ansi.Strip(strings.Replace(strings.Replace(got, goBlock, "", 1), shBlock, "", 1))
+	for _, line := range strings.Split(prose, "\n") {
+		if w := ansi.StringWidth(line); w > width {
+			t.Errorf("prose line %q is %d columns wide, want at most %d", line, w, width)
+		}
+	}
+	for _, want := range []string{"• first item", "◦ nested item", "a quoted remark", "Closing words."} {
+		if !strings.Contains(prose, want) {
+			t.Errorf("rendered prose lacks %q:\n%s", want, prose)
+		}
+	}
+	if strings.Contains(prose, "> a quoted") || strings.Contains(prose, "- first") {
+		t.Errorf("markdown markers left in prose:\n%s", prose)
+	}
+
+	// The blocks are still found and numbered afterwards
+	ReplaceCodeBlocksInContent(got, "ba", 80, "")
+	first, ok1 := GetCodeBlock("baa")
+	second, ok2 := GetCodeBlock("bab")
+	if !ok1 || first.Language != "go" || !strings.HasPrefix(first.Content, "// * not a list") {
+		t.Errorf("baa = %+v", first)
+	}
+	if !ok2 || second.Content != "  - indented: kept" {
+		t.Errorf("bab = %+v", second)
+	}
+}
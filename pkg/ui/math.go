@@ -0,0 +1,153 @@
+package ui
+
+import (
+	"regexp"
+	"strings"
+)
+
+// mathSpanRegex matches $$...$$, $...$, \(...\) and \[...\] spans. Inline $...$
+// must not start or end with whitespace so prices like "$5 and $10" are left alone.
+var mathSpanRegex = regexp.MustCompile(`\$\$([\s\S]+?)\$\$|\$([^\s$](?:[^$\n]*[^\s$])?)\$|\\\(([\s\S]+?)\\\)|\\\[([\s\S]+?)\\\]`)
+
+// latexCommandRegex matches a backslash command such as \alpha
+var latexCommandRegex = regexp.MustCompile(`\\([a-zA-Z]+)`)
+
+// latexFracRegex matches an innermost \frac{a}{b}
+var latexFracRegex = regexp.MustCompile(`\\frac\{([^{}]*)\}\{([^{}]*)\}`)
+
+// latexSqrtRegex matches an innermost \sqrt{x}
+var latexSqrtRegex = regexp.MustCompile(`\\sqrt\{([^{}]*)\}`)
+
+// latexWrapperRegex matches formatting commands whose argument is shown as-is
+var latexWrapperRegex = regexp.MustCompile(`\\(?:text|mathrm|mathbf|mathit|operatorname|textbf)\{([^{}]*)\}`)
+
+// latexScriptRegex matches ^x, ^{xy}, _x and _{xy}
+var latexScriptRegex = regexp.MustCompile(`([\^_])(?:\{([^{}]*)\}|([A-Za-z0-9+\-=]))`)
+
+// latexSymbols maps LaTeX commands to their closest Unicode character
+var latexSymbols = map[string]string{
+	// Greek lowercase
+	"alpha": "α", "beta": "β", "gamma": "γ", "delta": "δ", "epsilon": "ε",
+	"varepsilon": "ε", "zeta": "ζ", "eta": "η", "theta": "θ", "vartheta": "ϑ",
+	"iota": "ι", "kappa": "κ", "lambda": "λ", "mu": "μ", "nu": "ν", "xi": "ξ",
+	"pi": "π", "rho": "ρ", "sigma": "σ", "tau": "τ", "upsilon": "υ", "phi": "φ",
+	"varphi": "φ", "chi": "χ", "psi": "ψ", "omega": "ω",
+	// Greek uppercase
+	"Gamma": "Γ", "Delta": "Δ", "Theta": "Θ", "Lambda": "Λ", "Xi": "Ξ", "Pi": "Π",
+	"Sigma": "Σ", "Upsilon": "Υ", "Phi": "Φ", "Psi": "Ψ", "Omega": "Ω",
+	// Operators and relations
+	"times": "×", "cdot": "·", "div": "÷", "pm": "±", "mp": "∓",
+	"leq": "≤", "le": "≤", "geq": "≥", "ge": "≥", "neq": "≠", "ne": "≠",
+	"approx": "≈", "equiv": "≡", "sim": "∼", "propto": "∝",
+	"infty": "∞", "partial": "∂", "nabla": "∇",
+	"sum": "∑", "prod": "∏", "int": "∫", "oint": "∮",
+	"in": "∈", "notin": "∉", "subset": "⊂", "subseteq": "⊆", "supset": "⊃",
+	"cup": "∪", "cap": "∩", "emptyset": "∅", "forall": "∀", "exists": "∃",
+	"neg": "¬", "land": "∧", "lor": "∨",
+	"to": "→", "rightarrow": "→", "leftarrow": "←", "Rightarrow": "⇒",
+	"Leftarrow": "⇐", "leftrightarrow": "↔", "iff": "⇔", "mapsto": "↦",
+	"ldots": "…", "cdots": "⋯", "dots": "…", "degree": "°", "circ": "∘",
+	// Spacing and sizing commands that have no visible output
+	"left": "", "right": "", "quad": " ", "qquad": "  ", "displaystyle": "",
+}
+
+// superscripts maps characters to their Unicode superscript form
+var superscripts = map[rune]rune{
+	'0': '⁰', '1': '¹', '2': '²', '3': '³', '4': '⁴', '5': '⁵', '6': '⁶', '7': '⁷',
+	'8': '⁸', '9': '⁹', '+': '⁺', '-': '⁻', '=': '⁼', '(': '⁽', ')': '⁾',
+	'n': 'ⁿ', 'i': 'ⁱ', 'x': 'ˣ', 'y': 'ʸ', 'T': 'ᵀ',
+}
+
+// subscripts maps characters to their Unicode subscript form
+var subscripts = map[rune]rune{
+	'0': '₀', '1': '₁', '2': '₂', '3': '₃', '4': '₄', '5': '₅', '6': '₆', '7': '₇',
+	'8': '₈', '9': '₉', '+': '₊', '-': '₋', '=': '₌', '(': '₍', ')': '₎',
+	'a': 'ₐ', 'e': 'ₑ', 'o': 'ₒ', 'x': 'ₓ', 'i': 'ᵢ', 'j': 'ⱼ', 'k': 'ₖ',
+	'n': 'ₙ', 'm': 'ₘ', 'p': 'ₚ', 't': 'ₜ',
+}
+
+// RenderMath replaces LaTeX math spans in content with a Unicode approximation.
+// Fenced code blocks are left untouched since $ is common in shell snippets.
+func RenderMath(content string) string {
+	return mapProse(content, renderMathSpans)
+}
+
+// renderMathSpans converts every math span in text that contains no code fences
+func renderMathSpans(text string) string {
+	return mathSpanRegex.ReplaceAllStringFunc(text, func(span string) string {
+		groups := mathSpanRegex.FindStringSubmatch(span)
+		for _, expr := range groups[1:] {
+			if expr != "" {
+				return latexToUnicode(strings.TrimSpace(expr))
+			}
+		}
+		return span
+	})
+}
+
+// latexToUnicode pretty-prints a single LaTeX expression
+func latexToUnicode(expr string) string {
+	// Unwrap formatting commands and resolve nested scripts/fractions/roots from the inside out
+	for {
+		next := latexWrapperRegex.ReplaceAllString(expr, "$1")
+		next = latexFracRegex.ReplaceAllStringFunc(next, func(frac string) string {
+			parts := latexFracRegex.FindStringSubmatch(frac)
+			return groupIfNeeded(parts[1]) + "/" + groupIfNeeded(parts[2])
+		})
+		next = latexSqrtRegex.ReplaceAllStringFunc(next, func(root string) string {
+			return "√" + groupIfNeeded(latexSqrtRegex.FindStringSubmatch(root)[1])
+		})
+		next = latexScriptRegex.ReplaceAllStringFunc(next, convertScript)
+		if next == expr {
+			break
+		}
+		expr = next
+	}
+
+	expr = latexCommandRegex.ReplaceAllStringFunc(expr, func(cmd string) string {
+		if symbol, ok := latexSymbols[cmd[1:]]; ok {
+			return symbol
+		}
+		return cmd
+	})
+
+	// Drop grouping braces that are left over
+	expr = strings.NewReplacer("\\{", "{", "\\}", "}", "{", "", "}", "").Replace(expr)
+	return expr
+}
+
+// convertScript turns ^x / _{xy} into Unicode super/subscripts when every
+// character has a script form, otherwise into an explicit ^(xy) marker
+func convertScript(script string) string {
+	parts := latexScriptRegex.FindStringSubmatch(script)
+	body := parts[2] + parts[3]
+	table := superscripts
+	if parts[1] == "_" {
+		table = subscripts
+	}
+	if converted, ok := mapRunes(body, table); ok {
+		return converted
+	}
+	return parts[1] + groupIfNeeded(body)
+}
+
+// mapRunes converts every rune of s through table, failing if any is missing
+func mapRunes(s string, table map[rune]rune) (string, bool) {
+	var b strings.Builder
+	for _, r := range s {
+		mapped, ok := table[r]
+		if !ok {
+			return "", false
+		}
+		b.WriteRune(mapped)
+	}
+	return b.String(), true
+}
+
+// groupIfNeeded wraps multi-character expressions in parentheses
+func groupIfNeeded(s string) string {
+	if len([]rune(s)) <= 1 {
+		return s
+	}
+	return "(" + s + ")"
+}
@@ -0,0 +1,60 @@
+package ui
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/thebug/lab/eko/v3/pkg/types"
+)
+
+// recordMeta attaches Ollama's timing statistics to the reply they belong to.
+// Replies from the cache or ComfyUI carry none and are left alone.
+func (m *Model) recordMeta(id string, meta types.ResponseMeta) {
+	if meta == (types.ResponseMeta{}) {
+		return
+	}
+	for i := len(m.messages) - 1; i >= 0; i-- {
+		if m.messages[i].ID == id {
+			m.messages[i].Meta = &meta
+			return
+		}
+	}
+}
+
+// formatMeta renders the timing breakdown shown by :meta, separating model
+// loading and prompt processing from generation
+func formatMeta(meta types.ResponseMeta) string {
+	return fmt.Sprintf("total %s | load %s | prompt %d tok in %s (%s) | eval %d tok in %s (%s)",
+		roundDuration(meta.TotalDuration),
+		roundDuration(meta.LoadDuration),
+		meta.PromptEvalCount, roundDuration(meta.PromptEvalDuration), tokenRate(meta.PromptEvalCount, meta.PromptEvalDuration),
+		meta.EvalCount, roundDuration(meta.EvalDuration), tokenRate(meta.EvalCount, meta.EvalDuration),
+	)
+}
+
+// formatLatency summarizes how a reply felt: time to its first token, which
+// is mostly prompt processing, and to its end
+func formatLatency(meta types.ResponseMeta) string {
+	return fmt.Sprintf("first token %s of %s", meta.FirstToken.Round(10*time.Millisecond), meta.Elapsed.Round(10*time.Millisecond))
+}
+
+// formatTokenStats summarizes a reply for the status footer: its generation
+// speed and the tokens of prompt and reply together
+func formatTokenStats(meta types.ResponseMeta) string {
+	return fmt.Sprintf("%s · %d tok", tokenRate(meta.EvalCount, meta.EvalDuration), meta.PromptEvalCount+meta.EvalCount)
+}
+
+// roundDuration keeps durations readable, e.g. 1.234s or 56.3ms
+func roundDuration(d time.Duration) time.Duration {
+	if d >= time.Second {
+		return d.Round(time.Millisecond)
+	}
+	return d.Round(time.Microsecond * 100)
+}
+
+func tokenRate(tokens int, d time.Duration) string {
+	if d <= 0 {
+		return "- tok/s"
+	}
+	return fmt.Sprintf("%.1f tok/s", float64(tokens)/d.Seconds())
+}
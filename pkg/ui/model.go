@@ -1,9 +1,11 @@
 package ui
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
 	"time"
 
@@ -13,9 +15,12 @@ import (
 	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/thebug/lab/eko/v3/pkg/cache"
 	"github.com/thebug/lab/eko/v3/pkg/comfyui"
 	"github.com/thebug/lab/eko/v3/pkg/config"
+	"github.com/thebug/lab/eko/v3/pkg/debuglog"
 	"github.com/thebug/lab/eko/v3/pkg/ollama"
+	"github.com/thebug/lab/eko/v3/pkg/session"
 	"github.com/thebug/lab/eko/v3/pkg/types"
 )
 
@@ -39,12 +44,57 @@ type Model struct {
 	nodeProgress    string // "5/9" format for current node progress
 	elapsedTime     time.Duration
 	startTime       time.Time
+	etaEstimate     time.Duration  // Expected generation time from past runs of this workflow
+	timings         config.Timings // Generation time history per workflow
 	modelName       string
+	systemPrompt    string
+	tags            []string
+	scratchpad      string // Notes kept alongside the chat, never sent to the model
+	showScratchpad  bool
+	sessionDir      string
+	sessionIndex    *session.Index
+	sessionList     []string
 	configManager   *config.Manager
 	ollamaClient    *ollama.Client
 	comfyUIClient   *comfyui.Client
 	comfyUIWorkflow []byte
+	workflowPath    string
+	workflowDir     string                            // From config: where :workflow lists workflows
+	workflowList    []string                          // Workflows offered by :workflow
+	workflows       map[string]types.WorkflowSettings // From config: settings by workflow file name or path
+	lastImagePrompt string
+	imageSeeds      map[string]int64 // Seed of each generated image, by message ID
+	imageSidecars   bool             // From config: write a metadata JSON next to each image
+	promptQueue     []string // Prompts waiting for the current generation to finish
+	councilModels   []string // :council members; the current model judges their replies
 	isImageMode     bool
+	inline          bool // Skip the alt-screen so the transcript stays in scrollback
+	renderMath      bool // Pretty-print LaTeX math in responses
+	hideHeader      bool // Hide the header and image-mode footer to maximize the viewport
+	starredOnly     bool // :starred - show only starred messages
+	focusLast       bool // F / :focus - show only the last exchange
+	collapseLength  int  // From config: characters a collapsed message keeps
+	showMeta        bool // :meta - show Ollama's timing breakdown under each reply
+	showIndices     bool // :numbers - show each message's number for :goto
+	teePath         string // :tee - file every streamed reply is also appended to
+	configErr       error  // Why the config file couldn't be read, shown in the header
+	modelTemplates  map[string]string // From config: template name of each model prompted through /api/generate
+	promptTemplates map[string]types.PromptTemplate
+	commandAliases  map[string]string // From config: :alias expands to the mapped command line
+	fallbackModels  []string          // From config: models offered when the list cannot be fetched
+	startOllama     bool              // From config: offer to start a local Ollama that isn't running
+	ollamaOffered   bool              // Starting Ollama was already offered this run
+	offline         bool              // Neither Ollama nor ComfyUI answered; sending is disabled
+	pinging         bool              // The periodic backend check is running
+	ollamaConn      connState         // Whether Ollama answered last time, shown in the footer
+	promptPrefix    string            // From config: wrapped around each user message sent
+	promptSuffix    string
+	noAffixes       bool // :affix - send this conversation's messages without the prefix and suffix
+	stopPattern     *regexp.Regexp // Client-side stop: end the stream once this matches
+	repetitionLimit int            // From config: end the stream once a phrase repeats this often
+	responseCache   *cache.Cache   // Nil unless response caching is enabled
+	cacheAll        bool           // Cache non-deterministic requests too
+	cacheKey        string         // Cache key of the running stream, empty if it won't be stored
 	width           int
 	height          int
 	modelList       []string
@@ -55,6 +105,12 @@ type Model struct {
 	currentStreamID string
 	queueCount      int
 
+	// Tab completion in command mode: the matches, the one shown and the
+	// text before the completed word
+	completions    []string
+	completionIdx  int
+	completionBase string
+
 	// For gg / G navigation
 	lastKey  string
 	keyTimer time.Time
@@ -62,14 +118,109 @@ type Model struct {
 	// Real-time streaming
 	msgChan chan tea.Msg
 
+	// Parent of every request context; shutdown cancels it on quit
+	ctx      context.Context
+	shutdown context.CancelFunc
+
+	// Aborts the running stream's request; nil while idle
+	streamCancel context.CancelFunc
+
+	// With word_streaming, the end of the streamed text that doesn't finish a word yet
+	wordStreaming bool
+	pendingTokens string
+
+	// Temperature of the last T regeneration and the reply it was for, so
+	// pressing T again keeps heating up the same reply
+	hotTemperature float64
+	hotID          string
+
+	// Reply whose request failed last, for . to retry
+	failedID string
+
+	// Snapshots of the conversation for u / ctrl+r
+	undoStack [][]types.Message
+	redoStack [][]types.Message
+
+	// Read-only conversations can be scrolled and copied from but not sent to
+	readOnly bool
+
+	// Conversation as last saved or loaded, to tell whether a load would lose work
+	savedMessages []types.Message
+	pendingLoad   *types.SessionLoadedMsg // Load waiting for confirmation
+
+	// Conversation tabs; the active tab's state lives in the fields above
+	tabs      []conversation
+	activeTab int
+
+	// Throttled redraw: tokens arriving within redrawInterval share one render
+	redrawInterval  time.Duration
+	redrawScheduled bool
+
+	// Streaming output is followed only when the view is within this many lines of the bottom
+	autoScrollThreshold int
+
+	// Model list fetch progress
+	modelsLoaded     bool // Set once the first fetch has completed
+	refreshingModels bool // Set while an on-demand refresh is in flight
+
 	// For yank mode
-	yankInput       string
-	yankStatus      string    // For showing success/failure messages
-	yankStatusTimer time.Time // For auto-clearing status messages
+	yankInput string
+
+	// For d: the message ID typed so far, and whether a prompt takes its reply with it
+	deleteInput     string
+	deleteWithReply bool
+
+	// For up/down in : mode: commands entered, oldest first, how far back
+	// the input is (0 is the line being typed) and that line while browsing
+	commandHistory []string
+	historyPos     int
+	historyDraft   string
+	historySize    int
+
+	// For ]c / [c navigation: where each code block starts in the viewport
+	codeBlockPositions []codeBlockPos
+	focusedBlock       string
+
+	// For :goto: the first viewport line of each message, -1 when not shown
+	messageLines []int
+
+	// For / search: the query as typed, its pattern, whether matches are
+	// highlighted and the line of the match last scrolled to
+	searchQuery     string
+	searchPattern   *regexp.Regexp
+	searchHighlight bool
+	searchLine      int
+
+	// From config: render only the last renderWindow messages, 0 for all.
+	// olderShown counts the earlier ones brought in with k at the top, and
+	// scrollAnchor is the message to keep in view once they are rendered.
+	renderWindow int
+	olderShown   int
+	scrollAnchor string
+
+	// For :bench: cancels the runs in flight, nil when idle
+	benchCancel context.CancelFunc
+	benchTotal  int
+	benchReport string
+
+	// For the :log overlay
+	logLines   []string
+	logOffset  int
+	logErr     error
+	logTicking bool // A refresh is scheduled, so reopening doesn't start another
+
+	// For the :diff overlay
+	diff       []diffLine
+	diffTitle  string
+	diffOffset int
+
+	// Transient status line (yank results, command feedback)
+	status      string    // For showing success/failure messages
+	statusTimer time.Time // For auto-clearing status messages
 }
 
 // NewModel creates a new application model
-func NewModel(imageMode bool, args []string) Model {
+func NewModel(imageMode bool, inline bool, configFile string, args []string) Model {
 	ti := textinput.New()
 	ti.Prompt = ""
 	ti.PromptStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("0"))
@@ -110,6 +261,10 @@ func NewModel(imageMode bool, args []string) Model {
 		}
 	}
 
+	configManager := config.NewManager(configFile)
+	msgChan := make(chan tea.Msg, 100) // Buffered channel for streaming messages
+	ctx, shutdown := context.WithCancel(context.Background())
+
 	return Model{
 		state:           types.NormalState,
 		viewMode:        types.VerboseMode,
@@ -122,31 +277,42 @@ func NewModel(imageMode bool, args []string) Model {
 		elapsedTime:     0,
 		startTime:       time.Time{},
 		modelName:       config.DefaultModel,
-		configManager:   config.NewManager(),
+		configManager:   configManager,
 		ollamaClient:    ollama.NewClient(),
 		comfyUIClient:   comfyui.NewClient(config.DefaultComfyUIURL),
 		comfyUIWorkflow: workflow,
+		workflowPath:    initialWorkflowPath,
+		timings:         configManager.LoadTimings(),
+		commandHistory:  configManager.LoadHistory(),
 		isImageMode:     isImageMode,
+		inline:          inline,
 		streaming:       false,
 		isThinking:      false,
 		currentStreamID: "",
 		queueCount:      0,
 		lastKey:         "",
-		msgChan:         make(chan tea.Msg, 100), // Buffered channel for streaming messages
+		msgChan:         msgChan,
+		ctx:             ctx,
+		shutdown:        shutdown,
+		tabs:            []conversation{{name: "1", msgChan: msgChan}},
 		yankInput:       "",
-		yankStatus:      "",
-		yankStatusTimer: time.Time{},
+		status:          "",
+		statusTimer:     time.Time{},
 	}
 }
 
 // Init initializes the model
 func (m Model) Init() tea.Cmd {
 	cmds := []tea.Cmd{
-		tea.EnterAltScreen,
 		m.configManager.LoadConfig(),
 		m.initializeViewport(),
 		m.updateViewportContent(),
 	}
+
+	// Inline mode renders in the normal buffer so the conversation survives exit
+	if !m.inline {
+		cmds = append(cmds, tea.EnterAltScreen)
+	}
 	
 	if m.isImageMode {
 		cmds = append(cmds, checkQueueStatus(m.comfyUIClient.BaseURL))
@@ -156,317 +322,96 @@ func (m Model) Init() tea.Cmd {
 }
 
 // Update handles model updates
-func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+func (m Model) Update(msg tea.Msg) (model tea.Model, cmd tea.Cmd) {
+	// A bug handling one message must not take the whole conversation down:
+	// keep the model as it was before the message and report the panic
+	defer func() {
+		if r := recover(); r != nil {
+			m.setStatus(false, m.rescue("Update", r))
+			model, cmd = m, m.updateViewportContent()
+		}
+	}()
+	return m.update(msg)
+}
+
+// update does the work of Update
+func (m Model) update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	var cmds []tea.Cmd
 	var cmd tea.Cmd
 
-	// Handle messages from the streaming channel
+	// Handle messages from the streaming channels
 	select {
 	case streamMsg := <-m.msgChan:
-		// Process streaming message
-		switch streamMsg := streamMsg.(type) {
-		case types.TokenMsg:
-			if len(m.messages) > 0 && m.messages[len(m.messages)-1].Role == "assistant" && m.messages[len(m.messages)-1].ID == streamMsg.ID {
-				m.messages[len(m.messages)-1].Content += streamMsg.Token
-				// Direct update instead of throttled redraw to prevent crashes
-				cmds = append(cmds, m.updateViewportContent())
-			}
-		case types.GenerationStartMsg:
-			m.isThinking = true
-			m.currentStreamID = streamMsg.ID
-			cmds = append(cmds, m.spinner.Tick)
-		case types.GenerationDoneMsg:
-			m.isThinking = false
-			m.streaming = false
-			m.currentStreamID = ""
-			cmds = append(cmds, m.updateViewportContent())
-		case types.StreamErrorMsg:
-			if len(m.messages) > 0 && m.messages[len(m.messages)-1].Role == "assistant" {
-				m.messages[len(m.messages)-1].Content = fmt.Sprintf("Error: %s", streamMsg.Error)
-				m.viewport.GotoBottom()
-			}
-			m.streaming = false
-			m.isThinking = false
-		case types.CancelStreamMsg:
-			// Handle stream cancellation
-			if m.currentStreamID == streamMsg.ID {
-				m.isThinking = false
-				m.streaming = false
-				m.currentStreamID = ""
-				if len(m.messages) > 0 && m.messages[len(m.messages)-1].Role == "assistant" {
-					m.messages[len(m.messages)-1].Content += " [Stream cancelled]"
-				}
-				cmds = append(cmds, m.updateViewportContent())
-			}
-		case types.ProgressMsg:
-			// Handle progress updates from ComfyUI
-			if m.isImageMode && m.isThinking && m.currentStreamID == streamMsg.ID {
-				m.queueCount = streamMsg.Update.QueueRemaining
-				if streamMsg.Update.Percent > 0 {
-					m.progressPct = streamMsg.Update.Percent
-				}
-				if streamMsg.Update.Value > 0 && streamMsg.Update.Max > 0 {
-					m.nodeProgress = fmt.Sprintf("%d/%d", streamMsg.Update.Value, streamMsg.Update.Max)
-				}
-				// Don't set progressStage - we don't want to show "Executing node X" text
-				m.elapsedTime = streamMsg.Update.ElapsedTime
-				cmds = append(cmds, m.updateViewportContent())
-			}
-		}
+		cmds = append(cmds, m.handleStreamMsg(streamMsg)...)
 	default:
 		// No message from channel, continue with normal processing
 	}
+	cmds = append(cmds, m.drainBackgroundTabs()...)
 
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
-		// Handle state transitions first
+		// Each state handles its own keys
 		justTransitioned := false
-		if m.state == types.NormalState {
-			switch msg.String() {
-			case "i":
-				// Enter insert mode with empty input (current behavior)
-				m.state = types.InsertState
-				m.input.Focus()
-				m.input.Prompt = ""
-				m.input.SetValue("")
-				justTransitioned = true
-				// Don't process the 'i' key by input
-				break
-			case ":":
-				m.state = types.CommandState
-				m.input.Focus()
-				m.input.Prompt = ":"
-				m.input.SetValue("")
-				justTransitioned = true
-				// Don't process the ':' key by input
-				break
-			case "y":
-				m.state = types.YankCodeState
-				justTransitioned = true
-				// Don't process the 'y' key further
-				break
-			case "o":
-				// Enter insert mode with last user message prefilled
-				m.state = types.InsertState
-				m.input.Focus()
-				m.input.Prompt = ""
-
-				// Find the last user message and prefilled it
-				lastUserMessage := m.getLastUserMessage()
-				m.input.SetValue(lastUserMessage)
-				justTransitioned = true
-				// Don't process the 'o' key by input
-				break
-			case "O":
-				// Enter insert mode with last assistant message prefilled
-				m.state = types.InsertState
-				m.input.Focus()
-				m.input.Prompt = ""
-
-				// Find the last assistant message and prefilled it
-				lastAssistantMessage := m.getLastAssistantMessage()
-				m.input.SetValue(lastAssistantMessage)
-				justTransitioned = true
-				// Don't process the 'O' key by input
-				break
-			case "tab":
-				// Toggle focus
-				break
-			case "ctrl+c":
-				// Cancel current stream if active, otherwise quit
-				if m.isThinking && m.currentStreamID != "" {
-					cmds = append(cmds, m.cancelStream(m.currentStreamID))
-				} else {
-					cmds = append(cmds, tea.Quit)
-				}
-				break
-			case "q":
-				cmds = append(cmds, tea.Quit)
-				break
-			// Navigation: G and gg
-			case "G":
-				if len(m.messages) > 0 {
-					m.viewport.GotoBottom()
-				}
-				// reset lastKey
-				m.lastKey = ""
-				break
-			case "g":
-				// double-tap 'g' quickly => top
-				now := time.Now()
-				if m.lastKey == "g" && now.Sub(m.keyTimer) <= 300*time.Millisecond {
-					m.viewport.GotoTop()
-					m.lastKey = ""
-				} else {
-					m.lastKey = "g"
-					m.keyTimer = now
-				}
-				break
-			}
-		} else {
-			// Handle other states (insert, command, yank, config)
-			switch m.state {
-			case types.InsertState:
-				// Handle insert state specific keys
-				// Check for Shift+Enter first - try multiple possible representations
-				keyStr := msg.String()
-
-				// Temporary debug: Show what key is being pressed
-				if keyStr != "enter" && msg.Type == tea.KeyEnter {
-					currentValue := m.input.Value()
-					m.input.SetValue(currentValue + "[DEBUG:" + keyStr + "]")
-					justTransitioned = true
-				} else if keyStr == "shift+enter" || keyStr == "shift+return" ||
-					(msg.Type == tea.KeyEnter && len(keyStr) > 5) {
-					// Shift+Enter: Add new line to input
-					currentValue := m.input.Value()
-					m.input.SetValue(currentValue + "\n")
-					// Don't process this key further
-					justTransitioned = true
-				} else if keyStr == "enter" {
-					// Regular Enter: Send message
-					if m.input.Value() == "" {
-						// Do nothing if input is empty
-					} else {
-						// Cancel any existing stream before starting new one
-						if m.isThinking && m.currentStreamID != "" {
-							cmds = append(cmds, m.cancelStream(m.currentStreamID))
-						}
-
-						// Add user message
-						id := generateID(len(m.messages))
-						userMsg := types.Message{ID: id, Role: "user", Content: m.input.Value(), IsCollapsed: false, Timestamp: time.Now()}
-						m.messages = append(m.messages, userMsg)
-
-						// Add placeholder AI message
-						aiId := generateID(len(m.messages))
-						aiMsg := types.Message{ID: aiId, Role: "assistant", Content: "", IsCollapsed: false, Timestamp: time.Now()}
-						m.messages = append(m.messages, aiMsg)
-
-						if m.isImageMode {
-							m.isThinking = true
-							m.currentStreamID = aiId
-							m.progressPct = 0.0
-							m.progressStage = "Starting..."
-							m.nodeProgress = ""
-							m.elapsedTime = 0
-							m.startTime = time.Now()
-							cmds = append(cmds, m.generateImage(aiId, m.input.Value()), m.updateViewportContent(), m.scrollToBottom(), m.spinner.Tick)
-							m.state = types.NormalState
-							m.input.Reset()
-						} else {
-							// Start real-time streaming response
-							m.streaming = true
-							m.isThinking = true
-							m.currentStreamID = aiId
-							cmds = append(cmds, m.startRealtimeStream(aiId), m.updateViewportContent(), m.scrollToBottom())
-							m.state = types.NormalState
-							m.input.Reset()
-						}
-					}
-				} else if msg.String() == "esc" {
-					m.state = types.NormalState
-					m.input.Reset()
-				}
-			case types.CommandState:
-				// Handle command state specific keys
-				if msg.String() == "enter" {
-					command := m.input.Value()
-					m.input.Reset()
-					cmd := m.handleCommand(command)
-					if cmd != nil {
-						cmds = append(cmds, cmd)
-					}
-					// Don't reset to normal state here - let handleCommand decide the state
-				} else if msg.String() == "esc" {
-					m.state = types.NormalState
-					m.input.Reset()
-				}
-			case types.YankState:
-				// Handle yank state
-				if len(msg.String()) == 2 {
-					// Try to yank message with this ID
-					for _, message := range m.messages {
-						if message.ID == msg.String() {
-							clipboard.WriteAll(message.Content)
-							break
-						}
-					}
-					m.state = types.NormalState
-				} else if msg.String() == "esc" {
-					m.state = types.NormalState
-				}
-			case types.YankCodeState:
-				// Handle yank code state - collect full code block ID
-				// Capture ALL keys exclusively in yank mode
-				keyStr := msg.String()
-				if keyStr == "enter" {
-					// Process the yank input
-					if m.yankInput != "" {
-						// Try to find and copy the code block
-						if block, exists := GetCodeBlock(m.yankInput); exists {
-							err := clipboard.WriteAll(block.Content)
-							if err != nil {
-								m.yankStatus = "✖ Failed to copy"
-							} else {
-								m.yankStatus = "✔ Copied " + m.yankInput
-							}
-							m.yankStatusTimer = time.Now()
-						} else {
-							m.yankStatus = "✖ Invalid code ID"
-							m.yankStatusTimer = time.Now()
-						}
-					}
-					m.yankInput = ""
-					m.state = types.NormalState
-				} else if keyStr == "esc" {
-					m.yankInput = ""
-					m.state = types.NormalState
-				} else if len(keyStr) == 1 {
-					// Append ANY single character to yank input (capture all keys)
-					m.yankInput += keyStr
-				} else if keyStr == "backspace" || keyStr == "delete" {
-					// Remove last character from yank input
-					if len(m.yankInput) > 0 {
-						m.yankInput = m.yankInput[:len(m.yankInput)-1]
-					}
-				}
-				// CRITICAL: Don't process any other keys in yank mode
-				justTransitioned = true
-				break
-			case types.ConfigState:
-				// Handle config state
-				switch msg.String() {
-				case "j":
-					if m.selectedIdx < len(m.modelList)-1 {
-						m.selectedIdx++
-					}
-				case "k":
-					if m.selectedIdx > 0 {
-						m.selectedIdx--
-					}
-				case "enter":
-					if m.selectedIdx < len(m.modelList) {
-						m.modelName = m.modelList[m.selectedIdx]
-						m.state = types.NormalState
-						cmds = append(cmds, m.configManager.SaveConfig(m.modelName))
-					}
-				case "esc":
-					m.state = types.NormalState
-				}
+		if msg.String() == composeKey && m.state != types.InsertState {
+			// Drop out of any mode straight into a new message
+			m.compose()
+			return m, tea.Batch(cmds...)
+		}
+		switch m.state {
+		case types.NormalState:
+			var normalCmds []tea.Cmd
+			justTransitioned, normalCmds = m.handleNormalState(msg)
+			cmds = append(cmds, normalCmds...)
+		case types.InsertState:
+			var cmd tea.Cmd
+			justTransitioned, cmd = m.handleInsertState(msg)
+			cmds = append(cmds, cmd)
+		case types.CommandState:
+			var cmd tea.Cmd
+			justTransitioned, cmd = m.handleCommandState(msg)
+			cmds = append(cmds, cmd)
+		case types.YankState:
+			cmds = append(cmds, m.handleYankState(msg))
+		case types.YankCodeState:
+			m.handleYankCodeState(msg)
+		case types.ConfigState:
+			cmds = append(cmds, m.handleConfigState(msg))
+		case types.SessionListState:
+			if cmd := m.handleSessionListState(msg); cmd != nil {
+				cmds = append(cmds, cmd)
 			}
+		case types.ScratchpadState:
+			justTransitioned = m.handleScratchpadState(msg)
+		case types.DiffState:
+			m.handleDiffState(msg)
+		case types.BenchState:
+			m.handleBenchState(msg)
+		case types.LogState:
+			m.handleLogState(msg)
+		case types.LoadConfirmState:
+			cmds = append(cmds, m.handleLoadConfirmState(msg)...)
+		case types.StartOllamaState:
+			cmds = append(cmds, m.handleStartOllamaState(msg))
+		case types.ComfyURLState:
+			var cmd tea.Cmd
+			justTransitioned, cmd = m.handleComfyURLState(msg)
+			cmds = append(cmds, cmd)
+		case types.SearchState:
+			var cmd tea.Cmd
+			justTransitioned, cmd = m.handleSearchState(msg)
+			cmds = append(cmds, cmd)
+		case types.WorkflowListState:
+			cmds = append(cmds, m.handleWorkflowListState(msg))
+		case types.ClearConfirmState:
+			cmds = append(cmds, m.handleClearConfirmState(msg))
+		case types.DeleteState:
+			cmds = append(cmds, m.handleDeleteState(msg))
 		}
 
 		// Process input if we're in insert or command state, but skip if we just transitioned
 		if !justTransitioned {
-			if m.state == types.InsertState {
-				// Skip processing Shift+Enter for new lines
-				if msg.String() != "shift+enter" && msg.String() != "shift+return" &&
-					!(msg.Type == tea.KeyEnter && len(msg.String()) > 5) {
-					m.input, cmd = m.input.Update(msg)
-					cmds = append(cmds, cmd)
-				}
-			} else if m.state == types.CommandState {
+			switch m.state {
+			case types.InsertState, types.CommandState, types.ScratchpadState, types.ComfyURLState, types.SearchState:
 				m.input, cmd = m.input.Update(msg)
 				cmds = append(cmds, cmd)
 			}
@@ -475,9 +420,7 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case tea.WindowSizeMsg:
 		m.width = msg.Width
 		m.height = msg.Height
-		m.viewport.Width = msg.Width
-		// Account for header (1 line) and input (2 lines height)
-		m.viewport.Height = msg.Height - 3
+		m.resizeViewport()
 		cmds = append(cmds, m.updateViewportContent())
 		return m, tea.Batch(cmds...)
 
@@ -486,25 +429,11 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		cmds = append(cmds, cmd)
 
 		// Update elapsed time only
-		if m.isImageMode && m.isThinking {
+		if m.isThinking && m.imageStream() {
 			m.elapsedTime = time.Since(m.startTime)
 			// Don't add fake progress, real progress should come from websocket
 		}
 
-	case types.ProgressMsg:
-		// This shouldn't be reached since we handle it in msgChan, but keep for safety
-		if m.isImageMode && m.isThinking && m.currentStreamID == msg.ID {
-			if msg.Update.Percent > 0 {
-				m.progressPct = msg.Update.Percent
-			}
-			if msg.Update.Value > 0 && msg.Update.Max > 0 {
-				m.nodeProgress = fmt.Sprintf("%d/%d", msg.Update.Value, msg.Update.Max)
-			}
-			// Don't set progressStage - we don't want to show "Executing node X" text
-			m.elapsedTime = msg.Update.ElapsedTime
-			cmds = append(cmds, m.updateViewportContent())
-		}
-
 	case types.QueueStatusMsg:
 		if msg.Err == nil {
 			m.queueCount = msg.Count
@@ -512,6 +441,11 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 
 	case types.ConfigLoadedMsg:
+		// Settings that can't be read are flagged rather than silently replaced by defaults
+		m.configErr = msg.Err
+		if msg.Err != nil {
+			m.setStatus(false, msg.Err.Error())
+		}
 		if msg.Err == nil {
 			if msg.ModelName != "" {
 				m.modelName = msg.ModelName
@@ -519,12 +453,85 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			if msg.URL != "" {
 				m.ollamaClient.BaseURL = msg.URL
 			}
+			m.ollamaClient.SetTimeout(time.Duration(msg.TimeoutSeconds) * time.Second)
 			if msg.ComfyUIURL != "" {
 				m.comfyUIClient.BaseURL = msg.ComfyUIURL
 			}
+			if msg.ComfyUIClientID != "" {
+				m.comfyUIClient.ClientID = msg.ComfyUIClientID
+			}
+			m.comfyUIClient.SetMaxJobs(msg.MaxImageJobs)
+			m.imageSidecars = msg.ImageSidecars
+			m.comfyUIClient.InjectAllPositive = msg.InjectAllPrompts
+			m.comfyUIClient.OutputDir = msg.ImageOutputDir
+			m.comfyUIClient.FilePrefix = msg.ImagePrefix
+			m.comfyUIClient.Nodes = msg.ComfyUINodes
+			if msg.Inline && !m.inline {
+				m.inline = true
+				cmds = append(cmds, tea.ExitAltScreen)
+			}
+			m.renderMath = msg.RenderMath
+			m.redrawInterval = time.Duration(msg.RedrawIntervalMs) * time.Millisecond
+			m.autoScrollThreshold = msg.AutoScrollThreshold
+			m.responseCache = nil
+			if msg.ResponseCache {
+				m.responseCache = cache.New(m.configManager.ResponseCacheDir())
+			}
+			m.cacheAll = msg.CacheAll
+			m.repetitionLimit = msg.RepetitionLimit
+			m.stopPattern = nil
+			if msg.StopPattern != "" {
+				if re, err := regexp.Compile(msg.StopPattern); err == nil {
+					m.stopPattern = re
+				} else {
+					m.setStatus(false, fmt.Sprintf("Invalid stop_pattern: %v", err))
+				}
+			}
+			if msg.HideHeader != m.hideHeader {
+				m.hideHeader = msg.HideHeader
+				m.resizeViewport()
+			}
+			m.modelTemplates = msg.ModelTemplates
+			m.promptTemplates = msg.PromptTemplates
+			m.commandAliases = msg.CommandAliases
+			m.fallbackModels = msg.FallbackModels
+			m.startOllama = msg.StartOllama
+			m.promptPrefix = msg.PromptPrefix
+			m.promptSuffix = msg.PromptSuffix
+			m.workflows = msg.Workflows
+			debuglog.SetPath(msg.DebugLog)
+			m.wordStreaming = msg.WordStreaming
+			m.renderWindow = msg.RenderWindow
+			if msg.Seed != nil {
+				m.applySeed(msg.Seed)
+			}
+			if msg.Temperature != nil {
+				m.ollamaClient.SetTemperature(*msg.Temperature)
+			}
+			if msg.TopP != nil {
+				m.ollamaClient.SetTopP(*msg.TopP)
+			}
+			if msg.NumCtx > 0 {
+				m.ollamaClient.SetNumCtx(msg.NumCtx)
+			}
+			if msg.SystemPrompt != "" {
+				m.systemPrompt = msg.SystemPrompt
+			}
+			m.workflowDir = msg.WorkflowDir
+			m.collapseLength = msg.CollapseLength
+			m.deleteWithReply = msg.DeleteWithReply
+			m.historySize = msg.HistorySize
+			m.ollamaClient.Think = msg.Think
+			// The version may have been detected before the config was read
+			m.warnUnsupportedFeatures()
+			if msg.SessionDir != m.sessionDir {
+				m.sessionDir = msg.SessionDir
+				m.sessionIndex = nil
+			}
 			
-			// Load default workflow if in image mode and no workflow loaded yet
-			if m.isImageMode && len(m.comfyUIWorkflow) == 0 {
+			// Load default workflow if in image mode and no workflow loaded yet.
+			// Chat mode reads it on the first /img prompt.
+			if len(m.comfyUIWorkflow) == 0 {
 				path := msg.WorkflowPath
 				// Expand ~ if present
 				if strings.HasPrefix(path, "~/") {
@@ -532,116 +539,383 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					path = filepath.Join(home, path[2:])
 				}
 				
-				var err error
-				m.comfyUIWorkflow, err = os.ReadFile(path)
-				if err != nil {
-					// Just log error to console if we can't load default workflow
-					// In a real app we might want to show this in UI
+				m.workflowPath = path
+				if m.isImageMode {
+					var err error
+					m.comfyUIWorkflow, err = os.ReadFile(path)
+					if err != nil {
+						// Just log error to console if we can't load default workflow
+						// In a real app we might want to show this in UI
+					}
 				}
 			}
+
+			// Ask for the ComfyUI URL up front instead of failing on the first prompt
+			if m.isImageMode {
+				cmds = append(cmds, checkComfyUI(m.comfyUIClient.BaseURL, false))
+			}
+		}
+		// Fetch models and server version after config is loaded and URL is set
+		cmds = append(cmds, m.ollamaClient.FetchModels(), m.ollamaClient.FetchVersion())
+		// Start checking the backends, which goes on for the rest of the run
+		if !m.pinging {
+			m.pinging = true
+			cmds = append(cmds, pingBackends(m.ollamaClient.BaseURL, m.comfyUIClient.BaseURL))
+		}
+
+	case types.BenchDoneMsg:
+		m.handleBenchDone(msg)
+
+	case types.LogTickMsg:
+		cmds = append(cmds, m.refreshLog())
+
+	case types.ComfyUICheckedMsg:
+		cmds = append(cmds, m.handleComfyUIChecked(msg))
+
+	case types.OllamaStartedMsg:
+		cmds = append(cmds, m.handleOllamaStarted(msg))
+
+	case types.PingMsg:
+		cmds = append(cmds, m.handlePing(msg))
+
+	case types.ControlRequest:
+		cmds = append(cmds, m.handleControl(msg)...)
+
+	case types.VersionLoadedMsg:
+		if msg.Err == nil {
+			m.ollamaClient.Version = msg.Version
+			m.warnUnsupportedFeatures()
 		}
-		// Fetch models after config is loaded and URL is set
-		cmds = append(cmds, m.ollamaClient.FetchModels())
 
 	case types.ModelsLoadedMsg:
+		m.noteOllamaReply(msg.Err)
 		if msg.Err == nil && len(msg.Models) > 0 {
 			m.modelList = msg.Models
 		} else {
-			// Fallback to default models if Ollama is not available
-			m.modelList = []string{"dolphin-phi", "llama2-uncensored", "mistral", "qwen3:1.7b", "gemma3"}
+			// Offer the configured fallback models if Ollama is not available,
+			// or at least the current one
+			m.modelList = m.fallbackModels
+			if len(m.modelList) == 0 {
+				m.modelList = []string{m.modelName}
+			}
+			if msg.Err != nil {
+				m.offerOllamaStart(msg.Err)
+			}
 		}
 
-	case types.StreamMsg:
-		// Append token to the last assistant message
-		if len(m.messages) > 0 && m.messages[len(m.messages)-1].Role == "assistant" {
-			m.messages[len(m.messages)-1].Content += msg.Token
+		m.modelsLoaded = true
+
+		// Keep the selection inside the (possibly shorter) list
+		if m.selectedIdx >= len(m.modelList) {
+			m.selectedIdx = len(m.modelList) - 1
+		}
+		if m.selectedIdx < 0 {
+			m.selectedIdx = 0
 		}
 
-		// Continue streaming if not done
-		if !msg.Done {
-			cmds = append(cmds, m.continueStream(msg.ID))
-		} else {
-			m.streaming = false
+		// Report the outcome of an on-demand refresh
+		if m.refreshingModels {
+			m.refreshingModels = false
+			if msg.Err != nil {
+				m.setStatus(false, fmt.Sprintf("Refresh failed: %v (using fallback list)", msg.Err))
+			} else {
+				m.setStatus(true, fmt.Sprintf("Refreshed %d models", len(m.modelList)))
+			}
 		}
 
-		// Update viewport content (no auto-scroll for assistant responses)
+	case types.RedrawMsg:
+		// Handle a (possibly throttled) redraw
+		m.redrawScheduled = false
 		cmds = append(cmds, m.updateViewportContent())
 
-	case types.StreamTokenMsg:
-		// Handle real-time streaming tokens
-		if len(m.messages) > 0 && m.messages[len(m.messages)-1].Role == "assistant" {
-			m.messages[len(m.messages)-1].Content += msg.Token
+	case types.TokenMsg, types.GenerationStartMsg, types.GenerationDoneMsg, types.StreamErrorMsg, types.CancelStreamMsg, types.ProgressMsg, types.StatusMsg:
+		// Handled the same way as when they arrive through the stream channel
+		cmds = append(cmds, m.handleStreamMsg(msg)...)
+
+	case types.ViewportContentMsg:
+		// Content rendered for a tab that is no longer shown is stale
+		if msg.Tab != m.activeTab {
+			break
+		}
+		// Follow a growing reply, unless the reader has scrolled up to older content
+		follow := m.viewport.AtBottom() || m.isThinking && m.nearBottom()
+		offset := m.viewport.YOffset
+		m.viewport.SetContent(msg.Content)
+		m.codeBlockPositions = locateCodeBlocks(msg.Content, m.messages)
+		m.messageLines = msg.MessageLines
+		if i := m.messageIndex(m.scrollAnchor); m.scrollAnchor != "" && i >= 0 && i < len(msg.MessageLines) && msg.MessageLines[i] >= 0 {
+			// Older messages were rendered above: stay on the one that was at the top
+			m.viewport.SetYOffset(msg.MessageLines[i])
+			m.scrollAnchor = ""
+		} else if follow {
+			m.viewport.GotoBottom()
+		} else {
+			// SetContent only clamps the offset when it falls past the last
+			// line (and then snaps to the bottom), so restore it explicitly
+			m.viewport.SetYOffset(offset)
+		}
+		// Only scroll to bottom for user prompts, not assistant responses
+		// (This will be handled by the specific message type that triggers this)
+
+	case types.ScrollToBottomMsg:
+		// Force scroll to bottom, unless a background tab asked
+		if msg.Tab == m.activeTab {
+			m.viewport.GotoBottom()
+		}
+
+	case types.SessionLoadedMsg:
+		if msg.Err != nil {
+			m.setStatus(false, fmt.Sprintf("Failed to load %s: %v", msg.Path, msg.Err))
+			break
+		}
+		if m.isThinking {
+			m.setStatus(false, "Wait for the current response to finish before loading")
+			break
+		}
+		// Ask before replacing work that would otherwise be lost
+		if m.unsaved() {
+			m.pendingLoad = &msg
+			m.state = types.LoadConfirmState
+			break
 		}
+		cmds = append(cmds, m.applyLoadedSession(msg)...)
 
-		// Continue streaming if not done
-		if !msg.Done {
-			cmds = append(cmds, m.continueStreamRealtime(msg.ID))
+	case types.SessionSavedMsg:
+		if msg.Err != nil {
+			m.setStatus(false, msg.Err.Error())
+			break
+		}
+		m.setStatus(true, "Saved "+msg.Path)
+		m.markSaved(msg)
+
+	case types.SessionsFoundMsg:
+		if msg.Err != nil {
+			m.setStatus(false, fmt.Sprintf("Search failed: %v", msg.Err))
+		} else if len(msg.Paths) == 0 {
+			m.setStatus(false, fmt.Sprintf("No sessions tagged %q", msg.Tag))
 		} else {
-			m.streaming = false
+			m.sessionList = msg.Paths
+			m.selectedIdx = 0
+			m.state = types.SessionListState
 		}
 
-		// Update viewport content (no auto-scroll for assistant responses)
-		cmds = append(cmds, m.updateViewportContent())
+	}
+
+	// Reset status if it's been shown for more than 3 seconds
+	if m.status != "" && time.Since(m.statusTimer) >= 3*time.Second {
+		m.status = ""
+	}
+
+	// Update viewport for scrolling only when not in insert mode
+	if m.state != types.InsertState {
+		m.viewport, cmd = m.viewport.Update(msg)
+		cmds = append(cmds, cmd)
+	}
 
-	// New real-time streaming message handlers
+	return m, tea.Batch(cmds...)
+}
+
+
+// handleStreamMsg applies one message from the active conversation's stream channel
+func (m *Model) handleStreamMsg(streamMsg tea.Msg) []tea.Cmd {
+	var cmds []tea.Cmd
+	switch streamMsg := streamMsg.(type) {
 	case types.TokenMsg:
-		// Handle individual token updates
-		if len(m.messages) > 0 && m.messages[len(m.messages)-1].Role == "assistant" && m.messages[len(m.messages)-1].ID == msg.ID {
-			m.messages[len(m.messages)-1].Content += msg.Token
-			// Direct update instead of throttled redraw to prevent crashes
-			cmds = append(cmds, m.updateViewportContent())
+		// Tokens from a stream that was stopped or cancelled are dropped, as are empty ones
+		if streamMsg.Token != "" && m.currentStreamID == streamMsg.ID && len(m.messages) > 0 && m.messages[len(m.messages)-1].Role == "assistant" && m.messages[len(m.messages)-1].ID == streamMsg.ID {
+			m.appendToken(streamMsg.Token)
+			cmds = append(cmds, m.requestRedraw())
+			cmds = append(cmds, m.checkStopPattern()...)
+			cmds = append(cmds, m.checkRepetition()...)
 		}
-
 	case types.GenerationStartMsg:
-		// Mark that generation has started
 		m.isThinking = true
-		m.currentStreamID = msg.ID
+		m.currentStreamID = streamMsg.ID
 		cmds = append(cmds, m.spinner.Tick)
-
 	case types.GenerationDoneMsg:
-		// Mark that generation is complete
-		m.isThinking = false
-		m.streaming = false
-		m.currentStreamID = ""
-		// Final redraw and scroll to bottom
-		cmds = append(cmds, m.updateViewportContent(), m.scrollToBottom())
-
-	case types.RedrawMsg:
-		// Handle redraw message
+		// A stream that was already stopped must not end the current one
+		if m.currentStreamID != streamMsg.ID {
+			break
+		}
+		if m.isThinking && !m.startTime.IsZero() && m.imageStream() {
+			cmds = append(cmds, m.recordGenerationTime())
+		}
+		m.flushTokens()
+		m.endStream()
+		m.trimReply(streamMsg.ID)
+		m.recordMeta(streamMsg.ID, streamMsg.Meta)
+		cmds = append(cmds, m.finishCaching(streamMsg.Reason))
+		m.suggestContinue(streamMsg.Reason)
 		cmds = append(cmds, m.updateViewportContent())
-
+		cmds = append(cmds, m.dispatchQueuedPrompt()...)
 	case types.StreamErrorMsg:
-		// Handle streaming error
-		if len(m.messages) > 0 && m.messages[len(m.messages)-1].Role == "assistant" {
-			m.messages[len(m.messages)-1].Content = fmt.Sprintf("Error: %s", msg.Error)
-			m.viewport.GotoBottom()
+		// Errors from a stream that was already stopped, e.g. its aborted request, are dropped
+		if m.currentStreamID != streamMsg.ID {
+			break
+		}
+		if i := m.messageIndex(streamMsg.ID); i >= 0 {
+			m.messages[i].Content = fmt.Sprintf("Error: %s", streamMsg.Error)
+		}
+		m.failedID = streamMsg.ID
+		m.endStream()
+		cmds = append(cmds, m.updateViewportContent())
+		cmds = append(cmds, m.dispatchQueuedPrompt()...)
+	case types.CancelStreamMsg:
+		if m.currentStreamID == streamMsg.ID {
+			cmds = append(cmds, m.cancelStream()...)
+		}
+	case types.CouncilDoneMsg:
+		cmds = append(cmds, m.handleCouncilDone(streamMsg)...)
+	case types.StatusMsg:
+		// The outcome of a background command, or a problem a stream ran into
+		// along the way, such as :tee failing to write
+		if streamMsg.Err != nil {
+			m.setStatus(false, streamMsg.Err.Error())
+		} else {
+			m.setStatus(true, streamMsg.Text)
+		}
+	case types.ImageInfoMsg:
+		// Remember the seed so the image can be reproduced: y + message ID copies it
+		if streamMsg.Info.HasSeed {
+			if m.imageSeeds == nil {
+				m.imageSeeds = make(map[string]int64)
+			}
+			m.imageSeeds[streamMsg.ID] = streamMsg.Info.Seed
+		}
+	case types.ProgressMsg:
+		// Handle progress updates from ComfyUI
+		if streamMsg.Update.Warning != "" {
+			m.setStatus(false, streamMsg.Update.Warning)
+		}
+		if m.isThinking && m.currentStreamID == streamMsg.ID && m.imageStream() {
+			m.queueCount = streamMsg.Update.QueueRemaining
+			if streamMsg.Update.Seed != 0 {
+				// Known before the image is done, so it can be copied while it renders
+				if m.imageSeeds == nil {
+					m.imageSeeds = make(map[string]int64)
+				}
+				m.imageSeeds[streamMsg.ID] = streamMsg.Update.Seed
+			}
+			if streamMsg.Update.Percent > 0 {
+				m.progressPct = streamMsg.Update.Percent
+			}
+			if streamMsg.Update.Value > 0 && streamMsg.Update.Max > 0 {
+				m.nodeProgress = fmt.Sprintf("%d/%d", streamMsg.Update.Value, streamMsg.Update.Max)
+			}
+			// Don't set progressStage - we don't want to show "Executing node X" text
+			m.elapsedTime = streamMsg.Update.ElapsedTime
+			cmds = append(cmds, m.updateViewportContent())
 		}
-		m.streaming = false
+	}
+	return cmds
+}
 
-	case types.ViewportContentMsg:
-		// Update viewport content
-		m.viewport.SetContent(msg.Content)
-		// Only scroll to bottom for user prompts, not assistant responses
-		// (This will be handled by the specific message type that triggers this)
+// nearBottom reports whether the viewport is within autoScrollThreshold lines of the end
+func (m Model) nearBottom() bool {
+	remaining := m.viewport.TotalLineCount() - m.viewport.YOffset - m.viewport.Height
+	return remaining <= m.autoScrollThreshold
+}
 
-	case types.ScrollToBottomMsg:
-		// Force scroll to bottom
-		m.viewport.GotoBottom()
+// requestRedraw re-renders the conversation, coalescing updates into at most one
+// redraw per redrawInterval when throttling is configured. Update runs on a single
+// goroutine, so the scheduled flag needs no extra locking.
+func (m *Model) requestRedraw() tea.Cmd {
+	if m.redrawInterval <= 0 {
+		return m.updateViewportContent()
+	}
+	if m.redrawScheduled {
+		return nil
+	}
+	m.redrawScheduled = true
+	return tea.Tick(m.redrawInterval, func(time.Time) tea.Msg {
+		return types.RedrawMsg{}
+	})
+}
 
+// resizeViewport fits the viewport between the header, optional panels and the input
+func (m *Model) resizeViewport() {
+	m.viewport.Width = m.width
+	// Account for header (1 line), input (2 lines height) and footer (1 line)
+	height := m.height - 4
+	if m.hideHeader {
+		height += 2
 	}
+	if panel := m.renderScratchpad(); panel != "" {
+		height -= lipgloss.Height(panel)
+	}
+	if height < 1 {
+		height = 1
+	}
+	m.viewport.Height = height
+}
+
+// handleScratchpadState handles input while editing the scratchpad. It returns
+// true when the key was consumed and must not reach the text input.
+func (m *Model) handleScratchpadState(msg tea.KeyMsg) bool {
+	switch msg.String() {
+	case "enter":
+		m.scratchpad = strings.TrimSpace(m.input.Value())
+		m.showScratchpad = m.scratchpad != ""
+		m.state = types.NormalState
+		m.input.Reset()
+		m.resizeViewport()
+		return true
 
-	// Reset yank status if it's been shown for more than 3 seconds
-	if m.yankStatus != "" && time.Since(m.yankStatusTimer) >= 3*time.Second {
-		m.yankStatus = ""
+	case "esc":
+		m.state = types.NormalState
+		m.input.Reset()
+		return true
 	}
+	return false
+}
 
-	// Update viewport for scrolling only when not in insert mode
-	if m.state != types.InsertState {
-		m.viewport, cmd = m.viewport.Update(msg)
-		cmds = append(cmds, cmd)
+// timingKey identifies the active workflow in the generation time history
+func (m Model) timingKey() string {
+	if m.workflowPath == "" {
+		return "default"
 	}
+	if abs, err := filepath.Abs(m.workflowPath); err == nil {
+		return abs
+	}
+	return m.workflowPath
+}
 
-	return m, tea.Batch(cmds...)
+// workflowSettings returns the configured settings for the active workflow,
+// found by its path or file name
+func (m Model) workflowSettings() types.WorkflowSettings {
+	if m.workflowPath == "" {
+		return types.WorkflowSettings{}
+	}
+	for key, settings := range m.workflows {
+		if key == filepath.Base(m.workflowPath) || config.ExpandPath(key) == m.workflowPath {
+			return settings
+		}
+	}
+	return types.WorkflowSettings{}
+}
+
+// recordGenerationTime folds the finished image generation into the workflow's timing history
+func (m *Model) recordGenerationTime() tea.Cmd {
+	m.timings.Record(m.timingKey(), time.Since(m.startTime))
+	return m.configManager.SaveTimings(m.timings)
+}
+
+// setStatus shows a transient success or failure message on the status line
+func (m *Model) setStatus(ok bool, text string) {
+	if ok {
+		m.status = "✔ " + text
+	} else {
+		m.status = "✖ " + text
+	}
+	m.statusTimer = time.Now()
+}
+
+// warnUnsupportedFeatures flags configured options the connected Ollama
+// version is too old to honour, since the server ignores them silently
+func (m *Model) warnUnsupportedFeatures() {
+	if unsupported := m.ollamaClient.UnsupportedFeatures(); len(unsupported) > 0 {
+		m.setStatus(false, fmt.Sprintf("Ollama %s does not support: %s", m.ollamaClient.Version, strings.Join(unsupported, ", ")))
+	}
 }
 
 // getLastUserMessage returns the content of the last user message, or empty string if none exists
@@ -669,75 +943,330 @@ func (m Model) getLastAssistantMessage() string {
 }
 
 // View renders the model
-func (m Model) View() string {
+func (m Model) View() (view string) {
+	defer func() {
+		if r := recover(); r != nil {
+			view = m.rescue("Rendering", r) + "\n\nPress q to quit; the view recovers once the cause is gone."
+		}
+	}()
+
 	switch m.state {
 	case types.ConfigState:
 		return m.renderModelList()
+	case types.SessionListState:
+		return m.renderSessionList()
+	case types.WorkflowListState:
+		return m.renderWorkflowList()
+	case types.DiffState:
+		return m.renderDiff()
+	case types.BenchState:
+		return m.renderBench()
+	case types.LogState:
+		return m.renderLog()
 	default:
 		return m.renderMainView()
 	}
 }
 
-// handleInsertState handles input in insert state
-func (m *Model) handleInsertState(msg tea.KeyMsg) tea.Cmd {
+// handleNormalState handles keys in normal mode. It reports whether the key
+// switched into a mode, so the input must not see it.
+func (m *Model) handleNormalState(msg tea.KeyMsg) (justTransitioned bool, cmds []tea.Cmd) {
 	switch msg.String() {
-	case "enter":
-		if m.input.Value() == "" {
-			return nil
+	case "i":
+		if m.readOnly {
+			m.setStatus(false, readOnlyHint)
+			break
 		}
-
-		// Add user message
-		id := generateID(len(m.messages))
-		userMsg := types.Message{ID: id, Role: "user", Content: m.input.Value(), IsCollapsed: false, Timestamp: time.Now()}
-		m.messages = append(m.messages, userMsg)
-
-		// Add placeholder AI message
-		aiId := generateID(len(m.messages))
-		aiMsg := types.Message{ID: aiId, Role: "assistant", Content: "", IsCollapsed: false, Timestamp: time.Now()}
-		m.messages = append(m.messages, aiMsg)
-
-		if m.isImageMode {
-			m.isThinking = true
-			m.currentStreamID = aiId
-			cmds := []tea.Cmd{m.generateImage(aiId, m.input.Value()), m.updateViewportContent(), m.scrollToBottom(), m.spinner.Tick}
-			m.state = types.NormalState
-			m.input.Reset()
-			return tea.Batch(cmds...)
+		// Enter insert mode with empty input (current behavior)
+		m.state = types.InsertState
+		m.input.Focus()
+		m.input.Prompt = ""
+		m.input.SetValue("")
+		justTransitioned = true
+		// Don't process the 'i' key by input
+		break
+	case ":":
+		m.state = types.CommandState
+		m.input.Focus()
+		m.input.Prompt = ":"
+		m.input.SetValue("")
+		justTransitioned = true
+		// Don't process the ':' key by input
+		break
+	case "y":
+		m.state = types.YankCodeState
+		justTransitioned = true
+		// Don't process the 'y' key further
+		break
+	case "d":
+		// Delete a message by ID to prune it from the context
+		m.state = types.DeleteState
+		m.deleteInput = ""
+		justTransitioned = true
+		break
+	case "p":
+		// Copy the most recent assistant response straight to the clipboard
+		lastAssistantMessage := m.getLastAssistantMessage()
+		if lastAssistantMessage == "" {
+			m.setStatus(false, "No response to copy")
+		} else if err := clipboard.WriteAll(lastAssistantMessage); err != nil {
+			m.setStatus(false, "Failed to copy")
+		} else {
+			m.setStatus(true, "Copied last response")
+		}
+		break
+	case "o":
+		if m.readOnly {
+			m.setStatus(false, readOnlyHint)
+			break
+		}
+		// Enter insert mode with last user message prefilled
+		m.state = types.InsertState
+		m.input.Focus()
+		m.input.Prompt = ""
+
+		// Find the last user message and prefilled it
+		lastUserMessage := m.getLastUserMessage()
+		m.input.SetValue(lastUserMessage)
+		justTransitioned = true
+		// Don't process the 'o' key by input
+		break
+	case "O":
+		if m.readOnly {
+			m.setStatus(false, readOnlyHint)
+			break
+		}
+		// Enter insert mode with last assistant message prefilled
+		m.state = types.InsertState
+		m.input.Focus()
+		m.input.Prompt = ""
+
+		// Find the last assistant message and prefilled it
+		lastAssistantMessage := m.getLastAssistantMessage()
+		m.input.SetValue(lastAssistantMessage)
+		justTransitioned = true
+		// Don't process the 'O' key by input
+		break
+	case "tab":
+		// Cycle to the next conversation tab
+		m.switchTab((m.activeTab + 1) % len(m.tabs))
+	case "shift+tab":
+		m.switchTab((m.activeTab + len(m.tabs) - 1) % len(m.tabs))
+	case "ctrl+c":
+		// Cancel current stream (and anything queued behind it) if active, otherwise quit
+		if m.benchCancel != nil {
+			m.benchCancel()
+		} else if m.isThinking && m.currentStreamID != "" {
+			m.promptQueue = nil
+			cmds = append(cmds, m.cancelStream()...)
+		} else {
+			cmds = append(cmds, m.quit())
+		}
+		break
+	case "q":
+		cmds = append(cmds, m.quit())
+		break
+	case "s":
+		// Show or hide the scratchpad panel
+		m.showScratchpad = !m.showScratchpad
+		m.resizeViewport()
+		break
+	case "S":
+		// Edit the scratchpad in the input line
+		m.state = types.ScratchpadState
+		m.input.Focus()
+		m.input.Prompt = "✎ "
+		m.input.SetValue(m.scratchpad)
+		justTransitioned = true
+		break
+	case "H":
+		// Toggle the header/footer and remember the choice
+		m.hideHeader = !m.hideHeader
+		m.resizeViewport()
+		hideHeader := m.hideHeader
+		cmds = append(cmds, m.updateViewportContent(), m.configManager.UpdateConfig(func(c *config.Config) {
+			c.HideHeader = hideHeader
+		}))
+		break
+	case "R":
+		// Re-fetch the model list, e.g. after pulling a new model
+		cmds = append(cmds, m.refreshModels())
+		break
+	case "ctrl+n":
+		// Flip to the next/previous model without opening :config
+		cmds = append(cmds, m.cycleModel(1))
+		break
+	case "ctrl+p":
+		cmds = append(cmds, m.cycleModel(-1))
+		break
+	case ">":
+		// Quote a message into a follow-up: type its ID, or just enter for the last reply
+		m.state = types.CommandState
+		m.input.Focus()
+		m.input.Prompt = ":"
+		m.input.SetValue("quote ")
+		m.input.CursorEnd()
+		justTransitioned = true
+		break
+	case "*":
+		// Star or unstar the last reply
+		cmds = append(cmds, m.toggleStar(""))
+		break
+	case "z":
+		// Open or close the last reply without leaving TLDR view
+		cmds = append(cmds, m.toggleCollapse(""))
+		break
+	case "F":
+		// Hide everything but the current turn, or show it all again
+		cmds = append(cmds, m.toggleFocus())
+		break
+	case "r":
+		// Ask for a new reply to the last prompt
+		cmds = append(cmds, m.regenerate()...)
+		break
+	case "/":
+		// Search the conversation, vim style
+		m.startSearch()
+		justTransitioned = true
+		break
+	case "n":
+		cmds = append(cmds, m.nextMatch(1))
+		break
+	case "N":
+		cmds = append(cmds, m.nextMatch(-1))
+		break
+	case "T":
+		// Retry the last reply hotter, without changing the temperature setting
+		cmds = append(cmds, m.regenerateHotter()...)
+		break
+	case ".":
+		// Re-run the request that just failed
+		cmds = append(cmds, m.retryFailed()...)
+		break
+	case "a":
+		// Resume a response that stopped early
+		cmds = append(cmds, m.continueResponse()...)
+		break
+	case "u":
+		// Revert the last destructive conversation change
+		cmds = append(cmds, m.undo())
+		break
+	case "ctrl+r":
+		cmds = append(cmds, m.redo())
+		break
+	case "]", "[":
+		// First half of ]c / [c code block navigation
+		m.lastKey = msg.String()
+		m.keyTimer = time.Now()
+		break
+	case "c":
+		if cmd := m.handleBracketKey("c"); cmd != nil {
+			cmds = append(cmds, cmd)
+		}
+		break
+	case "k", "up":
+		// Scrolling past the top renders older messages left out by render_window
+		if m.viewport.AtTop() {
+			cmds = append(cmds, m.showOlderMessages())
 		}
+		break
+	// Navigation: G and gg
+	case "G":
+		if len(m.messages) > 0 {
+			m.viewport.GotoBottom()
+		}
+		// reset lastKey
+		m.lastKey = ""
+		break
+	case "g":
+		// double-tap 'g' quickly => top
+		now := time.Now()
+		if m.lastKey == "g" && now.Sub(m.keyTimer) <= 300*time.Millisecond {
+			m.viewport.GotoTop()
+			m.lastKey = ""
+		} else {
+			m.lastKey = "g"
+			m.keyTimer = now
+		}
+		break
+	}
+	return justTransitioned, cmds
+}
 
-		// Start streaming real-time response
-		m.streaming = true
-		cmds := []tea.Cmd{m.streamResponseRealtime(aiMsg.ID), m.updateViewportContent(), m.scrollToBottom()}
+// handleInsertState handles keys while typing a message. It reports whether
+// the key was handled here, so the input must not see it.
+func (m *Model) handleInsertState(msg tea.KeyMsg) (bool, tea.Cmd) {
+	keyStr := msg.String()
+	switch {
+	case keyStr == "shift+enter" || keyStr == "shift+return" || msg.Type == tea.KeyEnter && len(keyStr) > 5:
+		// Shift+Enter, which most terminals send as alt+enter: break the line
+		// at the cursor and carry on typing after it. The single-line input
+		// turns the break into a space, as with codeFence.
+		value := []rune(m.input.Value())
+		pos := m.input.Position()
+		m.input.SetValue(string(value[:pos]) + "\n" + string(value[pos:]))
+		m.input.SetCursor(pos + 1)
+		return true, nil
+
+	case keyStr == "enter":
+		// Regular Enter: send the message, unless there is nothing to send
+		prompt := m.input.Value()
+		if prompt == "" {
+			return false, nil
+		}
+		var cmds []tea.Cmd
+		if m.isThinking {
+			// Queue behind the running generation instead of cancelling it
+			m.promptQueue = append(m.promptQueue, prompt)
+			m.setStatus(true, fmt.Sprintf("Queued (%d pending)", len(m.promptQueue)))
+			cmds = append(cmds, m.updateViewportContent(), m.scrollToBottom())
+		} else {
+			cmds = append(cmds, m.sendPrompt(prompt)...)
+		}
 		m.state = types.NormalState
 		m.input.Reset()
-		return tea.Batch(cmds...)
+		return false, tea.Batch(cmds...)
 
-	case "esc":
+	case keyStr == "alt+c":
+		// Empty code fence to paste code into
+		m.insertCodeFence()
+		return true, nil
+
+	case keyStr == "esc":
 		m.state = types.NormalState
 		m.input.Reset()
-		return nil
 	}
-
-	// For all other keys, let the input handle them
-	// This will be handled in the main Update function
-	return nil
+	return false, nil
 }
 
-// handleCommandState handles input in command state
-func (m *Model) handleCommandState(msg tea.KeyMsg) tea.Cmd {
+// handleCommandState handles keys while typing a : command. It reports
+// whether the key was handled here, so the input must not see it.
+func (m *Model) handleCommandState(msg tea.KeyMsg) (bool, tea.Cmd) {
 	switch msg.String() {
 	case "enter":
 		command := m.input.Value()
 		m.input.Reset()
-		cmd := m.handleCommand(command)
-		return cmd
+		// Don't reset to normal state here - let handleCommand decide the state
+		return false, tea.Batch(m.recordCommand(command), m.handleCommand(command))
+
+	case "tab":
+		m.completeCommand()
+		return true, nil
+
+	case "up":
+		m.browseHistory(-1)
+		return true, nil
+
+	case "down":
+		m.browseHistory(1)
+		return true, nil
 
 	case "esc":
+		m.historyPos = 0
 		m.state = types.NormalState
 		m.input.Reset()
-		return nil
 	}
-	return nil
+	return false, nil
 }
 
 // handleYankState handles input in yank state
@@ -759,32 +1288,75 @@ func (m *Model) handleYankState(msg tea.KeyMsg) tea.Cmd {
 	return nil
 }
 
-// handleConfigState handles input in config state
+// handleYankCodeState collects the ID of the code block to copy until enter.
+// Every key is captured while yanking.
+func (m *Model) handleYankCodeState(msg tea.KeyMsg) {
+	switch keyStr := msg.String(); {
+	case keyStr == "enter":
+		if m.yankInput != "" {
+			if block, exists := GetCodeBlock(m.yankInput); exists {
+				if err := clipboard.WriteAll(block.Content); err != nil {
+					m.setStatus(false, "Failed to copy")
+				} else {
+					m.setStatus(true, "Copied "+m.yankInput)
+				}
+			} else if seed, exists := m.imageSeeds[m.yankInput]; exists {
+				// Image messages yank their seed
+				if err := clipboard.WriteAll(fmt.Sprint(seed)); err != nil {
+					m.setStatus(false, "Failed to copy")
+				} else {
+					m.setStatus(true, fmt.Sprintf("Copied seed %d", seed))
+				}
+			} else {
+				m.setStatus(false, "Invalid code ID")
+			}
+		}
+		m.yankInput = ""
+		m.state = types.NormalState
+
+	case keyStr == "esc":
+		m.yankInput = ""
+		m.state = types.NormalState
+
+	case len(keyStr) == 1:
+		m.yankInput += keyStr
+
+	case keyStr == "backspace" || keyStr == "delete":
+		if len(m.yankInput) > 0 {
+			m.yankInput = m.yankInput[:len(m.yankInput)-1]
+		}
+	}
+}
+
+// handleConfigState handles input in the model list
 func (m *Model) handleConfigState(msg tea.KeyMsg) tea.Cmd {
 	switch msg.String() {
 	case "j":
 		if m.selectedIdx < len(m.modelList)-1 {
 			m.selectedIdx++
 		}
-		return nil
 
 	case "k":
 		if m.selectedIdx > 0 {
 			m.selectedIdx--
 		}
-		return nil
 
 	case "enter":
-		if m.selectedIdx < len(m.modelList) {
-			m.modelName = m.modelList[m.selectedIdx]
+		if m.selectedIdx >= 0 && m.selectedIdx < len(m.modelList) {
 			m.state = types.NormalState
-			return m.configManager.SaveConfig(m.modelName)
+			// Re-selecting the current model needs no config write
+			if m.modelList[m.selectedIdx] != m.modelName {
+				m.modelName = m.modelList[m.selectedIdx]
+				return m.configManager.SaveConfig(m.modelName)
+			}
 		}
-		return nil
+
+	case "r":
+		// Retry fetching models without leaving the list
+		return m.refreshModels()
 
 	case "esc":
 		m.state = types.NormalState
-		return nil
 	}
 	return nil
 }
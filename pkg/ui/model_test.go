@@ -0,0 +1,297 @@
+package ui
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/thebug/lab/eko/v3/pkg/types"
+)
+
+// newTestModel returns a model whose config, history and timings live in a
+// temporary home directory
+func newTestModel(t *testing.T) Model {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	m := NewModel(false, false, "", nil)
+	m.width, m.height = 100, 40
+	return m
+}
+
+// key builds the tea.KeyMsg for a key name as msg.String() reports it
+func key(name string) tea.KeyMsg {
+	switch name {
+	case "enter":
+		return tea.KeyMsg{Type: tea.KeyEnter}
+	case "alt+enter":
+		return tea.KeyMsg{Type: tea.KeyEnter, Alt: true}
+	case "esc":
+		return tea.KeyMsg{Type: tea.KeyEsc}
+	case "tab":
+		return tea.KeyMsg{Type: tea.KeyTab}
+	case "up":
+		return tea.KeyMsg{Type: tea.KeyUp}
+	case "down":
+		return tea.KeyMsg{Type: tea.KeyDown}
+	case "backspace":
+		return tea.KeyMsg{Type: tea.KeyBackspace}
+	}
+	return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(name)}
+}
+
+// typed splits text into one key per character
+func typed(text string) []string {
+	keys := make([]string, 0, len(text))
+	for _, r := range text {
+		keys = append(keys, string(r))
+	}
+	return keys
+}
+
+// press feeds keys through Update one at a time and returns the model and
+// the command returned for the last key
+func press(t *testing.T, m Model, keys ...string) (Model, tea.Cmd) {
+	t.Helper()
+	var cmd tea.Cmd
+	for _, k := range keys {
+		var next tea.Model
+		next, cmd = m.Update(key(k))
+		m = next.(Model)
+	}
+	return m, cmd
+}
+
+// collect runs cmd and the commands of any batch it returns, keeping the
+// messages of the given type. Timers such as the spinner tick are skipped
+// by only descending into batches.
+func collect[T tea.Msg](cmd tea.Cmd) []T {
+	if cmd == nil {
+		return nil
+	}
+	var found []T
+	switch msg := cmd().(type) {
+	case tea.BatchMsg:
+		for _, c := range msg {
+			found = append(found, collect[T](c)...)
+		}
+	case T:
+		found = append(found, msg)
+	}
+	return found
+}
+
+func TestInsertModeTyping(t *testing.T) {
+	m := newTestModel(t)
+
+	m, _ = press(t, m, append([]string{"i"}, typed("hello")...)...)
+	if m.state != types.InsertState {
+		t.Fatalf("state = %v, want InsertState", m.state)
+	}
+	if got := m.input.Value(); got != "hello" {
+		t.Errorf("input = %q, want %q", got, "hello")
+	}
+
+	m, _ = press(t, m, "esc")
+	if m.state != types.NormalState || m.input.Value() != "" {
+		t.Errorf("after esc: state = %v, input = %q", m.state, m.input.Value())
+	}
+}
+
+func TestInsertModeNewLineAtCursor(t *testing.T) {
+	m := newTestModel(t)
+
+	// The line break goes where the cursor is and typing carries on after it
+	m, _ = press(t, m, "i", "h", "i", "alt+enter", "x")
+	if got := m.input.Value(); got != "hi x" {
+		t.Errorf("input = %q, want %q", got, "hi x")
+	}
+	if m.state != types.InsertState {
+		t.Errorf("state = %v, want InsertState", m.state)
+	}
+}
+
+func TestInsertModeEnterSends(t *testing.T) {
+	m := newTestModel(t)
+
+	m, cmd := press(t, m, append(append([]string{"i"}, typed("hi")...), "enter")...)
+	if m.state != types.NormalState {
+		t.Errorf("state = %v, want NormalState", m.state)
+	}
+	if cmd == nil {
+		t.Fatal("enter returned no command")
+	}
+	if len(m.messages) != 2 || m.messages[0].Role != "user" || m.messages[0].Content != "hi" || m.messages[1].Role != "assistant" {
+		t.Fatalf("messages = %+v, want the prompt and a reply placeholder", m.messages)
+	}
+
+	// Enter on an empty input sends nothing
+	m, _ = press(t, m, "i", "enter")
+	if len(m.messages) != 2 || m.state != types.InsertState {
+		t.Errorf("empty enter: %d messages, state %v", len(m.messages), m.state)
+	}
+}
+
+func TestInsertModeQueuesWhileThinking(t *testing.T) {
+	m := newTestModel(t)
+	m.isThinking = true
+
+	m, _ = press(t, m, append(append([]string{"i"}, typed("next")...), "enter")...)
+	if len(m.promptQueue) != 1 || m.promptQueue[0] != "next" {
+		t.Errorf("queue = %v, want [next]", m.promptQueue)
+	}
+	if len(m.messages) != 0 {
+		t.Errorf("queued prompt was sent: %+v", m.messages)
+	}
+}
+
+func TestCommandModeRunsAndRecordsCommand(t *testing.T) {
+	m := newTestModel(t)
+
+	m, _ = press(t, m, append(append([]string{":"}, typed("tldr")...), "enter")...)
+	if m.viewMode != types.TLDRMode {
+		t.Errorf("view mode = %v, want TLDRMode", m.viewMode)
+	}
+	if m.state != types.NormalState {
+		t.Errorf("state = %v, want NormalState", m.state)
+	}
+
+	m, _ = press(t, m, append(append([]string{":"}, typed("verbose")...), "enter")...)
+	if got := strings.Join(m.commandHistory, ","); got != "tldr,verbose" {
+		t.Errorf("history = %q, want %q", got, "tldr,verbose")
+	}
+
+	// up walks back through the history, down returns to the line being typed
+	m, _ = press(t, m, ":", "x", "up")
+	if got := m.input.Value(); got != "verbose" {
+		t.Errorf("up: input = %q, want %q", got, "verbose")
+	}
+	m, _ = press(t, m, "up")
+	if got := m.input.Value(); got != "tldr" {
+		t.Errorf("up up: input = %q, want %q", got, "tldr")
+	}
+	m, _ = press(t, m, "down", "down")
+	if got := m.input.Value(); got != "x" {
+		t.Errorf("down down: input = %q, want %q", got, "x")
+	}
+
+	m, _ = press(t, m, "esc")
+	if m.state != types.NormalState || m.historyPos != 0 {
+		t.Errorf("after esc: state = %v, historyPos = %d", m.state, m.historyPos)
+	}
+}
+
+func TestCommandModeTabCompletes(t *testing.T) {
+	m := newTestModel(t)
+
+	m, _ = press(t, m, ":", "v", "e", "r", "tab")
+	if got := m.input.Value(); got != "verbose" {
+		t.Errorf("input = %q, want %q", got, "verbose")
+	}
+	if m.state != types.CommandState {
+		t.Errorf("state = %v, want CommandState", m.state)
+	}
+}
+
+func TestCommandReturnsStatusMessage(t *testing.T) {
+	m := newTestModel(t)
+	m.messages = []types.Message{{ID: "aa", Role: "user", Content: "hi"}}
+	path := filepath.Join(t.TempDir(), "chat")
+
+	m, cmd := press(t, m, append(append([]string{":"}, typed("export "+path)...), "enter")...)
+	statuses := collect[types.StatusMsg](cmd)
+	if len(statuses) != 1 || statuses[0].Err != nil || !strings.Contains(statuses[0].Text, "Exported 1 messages") {
+		t.Fatalf("status messages = %+v", statuses)
+	}
+	if _, err := os.Stat(path + ".md"); err != nil {
+		t.Errorf("export not written: %v", err)
+	}
+
+	// The message is shown on the status line once it comes back
+	next, _ := m.Update(statuses[0])
+	if got := next.(Model).status; !strings.HasPrefix(got, "✔ Exported") {
+		t.Errorf("status = %q", got)
+	}
+}
+
+func TestYankCodeModeCapturesKeys(t *testing.T) {
+	m := newTestModel(t)
+
+	m, _ = press(t, m, "y", "i", "q", "backspace", "z")
+	if m.state != types.YankCodeState {
+		t.Fatalf("state = %v, want YankCodeState", m.state)
+	}
+	// Keys that mean something in normal mode are part of the ID here
+	if m.yankInput != "iz" {
+		t.Errorf("yank input = %q, want %q", m.yankInput, "iz")
+	}
+
+	m, _ = press(t, m, "enter")
+	if m.state != types.NormalState || m.yankInput != "" {
+		t.Errorf("after enter: state = %v, yank input = %q", m.state, m.yankInput)
+	}
+	if m.status != "✖ Invalid code ID" {
+		t.Errorf("status = %q", m.status)
+	}
+}
+
+func TestConfigStateSelectsModel(t *testing.T) {
+	m := newTestModel(t)
+	m.modelList = []string{"first", "second"}
+	m.modelName = "first"
+
+	m, _ = press(t, m, append(append([]string{":"}, typed("config")...), "enter")...)
+	if m.state != types.ConfigState || m.selectedIdx != 0 {
+		t.Fatalf("state = %v, selected = %d", m.state, m.selectedIdx)
+	}
+
+	m, _ = press(t, m, "j", "j", "k", "j")
+	if m.selectedIdx != 1 {
+		t.Errorf("selected = %d, want 1", m.selectedIdx)
+	}
+
+	m, cmd := press(t, m, "enter")
+	if m.state != types.NormalState || m.modelName != "second" {
+		t.Errorf("state = %v, model = %q", m.state, m.modelName)
+	}
+	if cmd == nil {
+		t.Error("choosing a new model returned no command to save it")
+	}
+}
+
+func TestDeleteModeRemovesMessage(t *testing.T) {
+	m := newTestModel(t)
+	m.messages = []types.Message{{ID: "aa", Role: "user", Content: "q"}, {ID: "ba", Role: "assistant", Content: "a"}}
+
+	m, _ = press(t, m, "d", "b", "a")
+	if m.state != types.NormalState {
+		t.Errorf("state = %v, want NormalState", m.state)
+	}
+	if len(m.messages) != 1 || m.messages[0].ID != "aa" {
+		t.Errorf("messages = %+v, want only aa", m.messages)
+	}
+}
+
+func TestStreamMessagesThroughUpdate(t *testing.T) {
+	m := newTestModel(t)
+	m.messages = []types.Message{{ID: "aa", Role: "user", Content: "q"}, {ID: "ba", Role: "assistant"}}
+
+	for _, msg := range []tea.Msg{
+		types.GenerationStartMsg{ID: "ba"},
+		types.TokenMsg{ID: "ba", Token: "Hel"},
+		types.TokenMsg{ID: "zz", Token: "stale"},
+		types.TokenMsg{ID: "ba", Token: "lo "},
+		types.GenerationDoneMsg{ID: "ba"},
+	} {
+		next, _ := m.Update(msg)
+		m = next.(Model)
+	}
+
+	if got := m.messages[1].Content; got != "Hello" {
+		t.Errorf("reply = %q, want %q", got, "Hello")
+	}
+	if m.isThinking || m.currentStreamID != "" {
+		t.Errorf("stream still active: thinking = %v, id = %q", m.isThinking, m.currentStreamID)
+	}
+}
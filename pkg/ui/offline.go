@@ -0,0 +1,91 @@
+package ui
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/thebug/lab/eko/v3/pkg/types"
+)
+
+const (
+	// pingInterval is how often the backends are checked
+	pingInterval = 10 * time.Second
+	// pingTimeout bounds each reachability check
+	pingTimeout = 3 * time.Second
+)
+
+// offlineHint explains why sending is refused while no backend is reachable
+const offlineHint = "Offline: neither Ollama nor ComfyUI is reachable, sending resumes once one is"
+
+// pingBackends checks whether Ollama and ComfyUI answer at all. Any HTTP
+// response counts; only a failed connection makes a backend unreachable.
+func pingBackends(ollamaURL, comfyUIURL string) tea.Cmd {
+	return func() tea.Msg {
+		client := &http.Client{Timeout: pingTimeout}
+		reachable := func(url string) bool {
+			resp, err := client.Get(url)
+			if err != nil {
+				return false
+			}
+			resp.Body.Close()
+			return true
+		}
+
+		var msg types.PingMsg
+		var wg sync.WaitGroup
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			msg.Ollama = reachable(ollamaURL + "/api/version")
+		}()
+		go func() {
+			defer wg.Done()
+			msg.ComfyUI = reachable(comfyUIURL + "/prompt")
+		}()
+		wg.Wait()
+		return msg
+	}
+}
+
+// handlePing updates the Ollama connection shown in the footer, enters
+// offline mode when no backend answered and leaves it once one does. The
+// backends are pinged again periodically.
+func (m *Model) handlePing(msg types.PingMsg) tea.Cmd {
+	var cmds []tea.Cmd
+	// Ollama is back: pick up models pulled while it was away
+	if msg.Ollama && m.ollamaConn == connDown {
+		cmds = append(cmds, m.ollamaClient.FetchModels(), m.ollamaClient.FetchVersion())
+	}
+	m.setOllamaConn(msg.Ollama)
+
+	offline := !msg.Ollama && !msg.ComfyUI
+	switch {
+	case offline && !m.offline:
+		m.setStatus(false, offlineHint)
+	case !offline && m.offline:
+		m.setStatus(true, "Back online")
+	}
+	m.offline = offline
+
+	ollamaURL, comfyUIURL := m.ollamaClient.BaseURL, m.comfyUIClient.BaseURL
+	cmds = append(cmds, tea.Tick(pingInterval, func(time.Time) tea.Msg {
+		return pingBackends(ollamaURL, comfyUIURL)()
+	}))
+	return tea.Batch(cmds...)
+}
+
+// sendBlocked reports whether nothing can be sent right now, because the
+// conversation is read-only or eko is offline, and says why in the status line
+func (m *Model) sendBlocked() bool {
+	switch {
+	case m.readOnly:
+		m.setStatus(false, readOnlyHint)
+	case m.offline:
+		m.setStatus(false, offlineHint)
+	default:
+		return false
+	}
+	return true
+}
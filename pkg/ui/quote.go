@@ -0,0 +1,47 @@
+package ui
+
+import (
+	"strings"
+
+	"github.com/thebug/lab/eko/v3/pkg/types"
+)
+
+// quoteMessage starts a follow-up that quotes the message with the given ID,
+// or the last reply when id is empty, in markdown "> " syntax
+func (m *Model) quoteMessage(id string) {
+	i := -1
+	if id == "" {
+		for j := len(m.messages) - 1; j >= 0; j-- {
+			if m.messages[j].Role == "assistant" {
+				i = j
+				break
+			}
+		}
+	} else {
+		i = m.messageIndex(id)
+	}
+	if i < 0 {
+		if id == "" {
+			m.setStatus(false, "No reply to quote")
+		} else {
+			m.setStatus(false, "No message with ID "+id)
+		}
+		return
+	}
+	if m.readOnly {
+		m.setStatus(false, readOnlyHint)
+		return
+	}
+
+	content := strings.TrimSpace(m.messages[i].Content)
+	lines := strings.Split(content, "\n")
+	for j, line := range lines {
+		lines[j] = strings.TrimRight("> "+line, " ")
+	}
+
+	m.state = types.InsertState
+	m.input.Focus()
+	m.input.Prompt = ""
+	m.input.SetValue(strings.Join(lines, "\n") + "\n\n")
+	m.input.CursorEnd()
+}
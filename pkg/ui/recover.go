@@ -0,0 +1,72 @@
+package ui
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/thebug/lab/eko/v3/pkg/session"
+)
+
+var (
+	rescueMu sync.Mutex
+	// rescuedCount is the conversation length last saved by rescue, so a panic
+	// that repeats on every frame doesn't save it again each time
+	rescuedCount = -1
+	// loggedPanics holds the panics already in the crash log, by where they
+	// happened and their value, so one that repeats on every frame is logged
+	// once
+	loggedPanics = make(map[string]bool)
+)
+
+// rescue logs a recovered panic with its stack trace and saves the
+// conversation to a crash file, so a rendering bug never costs a long chat. It
+// returns a note to show the user.
+func (m Model) rescue(where string, r interface{}) (note string) {
+	rescueMu.Lock()
+	defer rescueMu.Unlock()
+
+	crashLog := m.configManager.CrashLogPath()
+	logPanic(crashLog, where, r)
+	note = fmt.Sprintf("%s failed: %v (details in %s)", where, r, crashLog)
+	if len(m.messages) == 0 || len(m.messages) == rescuedCount {
+		return note
+	}
+	rescuedCount = len(m.messages)
+
+	// The model may be what is broken, so saving can panic too
+	defer func() {
+		if r := recover(); r != nil {
+			logPanic(crashLog, "emergency save", r)
+			note += "; saving the conversation failed"
+		}
+	}()
+	path := m.sessionPath("crash-" + time.Now().Format("20060102-150405"))
+	if err := session.Save(path, m.currentSession()); err != nil {
+		return note + "; saving the conversation failed: " + err.Error()
+	}
+	return note + "; conversation saved to " + path
+}
+
+// logPanic appends a panic and the stack that raised it to the crash log at
+// path, unless the same panic was logged already. Callers hold rescueMu.
+func logPanic(path, where string, r interface{}) {
+	key := fmt.Sprintf("%s: %v", where, r)
+	if loggedPanics[key] {
+		return
+	}
+	loggedPanics[key] = true
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	fmt.Fprintf(f, "%s panic in %s: %v\n%s\n", time.Now().Format("2006-01-02 15:04:05"), where, r, debug.Stack())
+}
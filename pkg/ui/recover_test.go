@@ -0,0 +1,40 @@
+package ui
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/thebug/lab/eko/v3/pkg/config"
+)
+
+func TestRescueLogsEachPanicOnce(t *testing.T) {
+	m := newTestModel(t)
+	dir := t.TempDir()
+	t.Chdir(dir)
+
+	// A panic in View repeats on every redraw
+	for i := 0; i < 3; i++ {
+		note := m.rescue("Rendering", "index out of range")
+		if !strings.Contains(note, m.configManager.CrashLogPath()) {
+			t.Errorf("note = %q, want the crash log path", note)
+		}
+	}
+	m.rescue("Update", "nil map")
+
+	home, _ := os.UserHomeDir()
+	data, err := os.ReadFile(filepath.Join(home, config.ConfigDir, config.CrashLog))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(data), "panic in Rendering"); n != 1 {
+		t.Errorf("rendering panic logged %d times, want once", n)
+	}
+	if n := strings.Count(string(data), "panic in Update"); n != 1 {
+		t.Errorf("update panic logged %d times, want once", n)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("wrote to the working directory: %v", entries)
+	}
+}
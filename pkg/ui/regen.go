@@ -0,0 +1,123 @@
+package ui
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/thebug/lab/eko/v3/pkg/types"
+)
+
+const (
+	// temperatureStep is how much hotter each T regeneration runs
+	temperatureStep = 0.2
+	// maxTemperature caps T, beyond which most models produce noise
+	maxTemperature = 2.0
+	// defaultTemperature is Ollama's default, used when none is configured
+	defaultTemperature = 0.8
+)
+
+// regenerate drops the last reply and asks for a new one to the same prompt,
+// with the current model and options. A reply still streaming is cancelled
+// first. Nothing is randomized; set a seed or temperature to steer it.
+func (m *Model) regenerate() []tea.Cmd {
+	if m.sendBlocked() {
+		return nil
+	}
+	var cmds []tea.Cmd
+	if m.isThinking && m.currentStreamID != "" && !m.imageStream() {
+		cmds = append(cmds, m.cancelStream()...)
+	}
+	if m.isThinking {
+		m.setStatus(false, "Wait for the current response to finish")
+		return cmds
+	}
+	if len(m.messages) == 0 || m.messages[len(m.messages)-1].Role != "assistant" {
+		m.setStatus(false, "The last message isn't a reply, nothing to regenerate")
+		return cmds
+	}
+	if m.messages[len(m.messages)-1].Image {
+		m.setStatus(false, "Images can't be regenerated; use . to retry a failed one")
+		return cmds
+	}
+
+	m.pushUndo()
+	m.messages = m.messages[:len(m.messages)-1]
+	id := m.nextID()
+	m.messages = append(m.messages, types.Message{ID: id, Role: "assistant", Model: m.modelName, Seed: m.seed(), Timestamp: time.Now()})
+	m.setStatus(true, "Regenerating")
+
+	m.streaming = true
+	m.isThinking = true
+	m.currentStreamID = id
+	m.cacheKey = ""
+	return append(cmds, m.startRealtimeStream(id), m.updateViewportContent(), m.scrollToBottom())
+}
+
+// regenerateHotter replaces the last reply with a new one generated one
+// temperature step above the current setting. The setting itself is left
+// alone; only this request is sent with the higher temperature.
+func (m *Model) regenerateHotter() []tea.Cmd {
+	if m.sendBlocked() {
+		return nil
+	}
+	if m.isThinking {
+		m.setStatus(false, "Wait for the current response to finish")
+		return nil
+	}
+	if m.isImageMode {
+		m.setStatus(false, "Regenerating is not available in image mode")
+		return nil
+	}
+	if len(m.messages) == 0 || m.messages[len(m.messages)-1].Role != "assistant" || m.messages[len(m.messages)-1].Image {
+		m.setStatus(false, "No response to regenerate")
+		return nil
+	}
+
+	last := &m.messages[len(m.messages)-1]
+	temperature := m.temperature() + temperatureStep
+	if m.hotID == last.ID {
+		// Pressing T again on the same reply keeps climbing
+		temperature = m.hotTemperature + temperatureStep
+	}
+	if temperature > maxTemperature {
+		temperature = maxTemperature
+	}
+
+	options := make(map[string]interface{}, len(m.ollamaClient.Options)+1)
+	for k, v := range m.ollamaClient.Options {
+		options[k] = v
+	}
+	options["temperature"] = temperature
+
+	m.pushUndo()
+	last.Content = ""
+	last.Cached = false
+	last.Meta = nil
+	last.Model = m.modelName
+	last.Seed = m.seed()
+	m.hotID = last.ID
+	m.hotTemperature = temperature
+	m.setStatus(true, fmt.Sprintf("Regenerating at temperature %.1f", temperature))
+
+	m.streaming = true
+	m.isThinking = true
+	m.currentStreamID = last.ID
+	m.cacheKey = ""
+
+	// Stream through a copy of the model whose client sends the hotter options
+	hot := *m
+	hot.ollamaClient = m.ollamaClient.WithOptions(options)
+	return []tea.Cmd{hot.streamInto(m.beginStream(), last.ID, m.chatHistory(last.ID)), m.updateViewportContent()}
+}
+
+// temperature is the configured sampling temperature, or Ollama's default
+func (m Model) temperature() float64 {
+	if temp, ok := m.ollamaClient.Options["temperature"]; ok {
+		if value, err := strconv.ParseFloat(fmt.Sprint(temp), 64); err == nil {
+			return value
+		}
+	}
+	return defaultTemperature
+}
@@ -2,10 +2,14 @@ package ui
 
 import (
 	"fmt"
+	"path/filepath"
 	"strings"
 	"time"
+	"unicode"
 
 	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/thebug/lab/eko/v3/pkg/ollama"
 	"github.com/thebug/lab/eko/v3/pkg/types"
 )
 
@@ -23,34 +27,76 @@ func (m Model) renderMainView() string {
 		m.height = 10
 	}
 
+	headerText := fmt.Sprintf("EKO - Model: %s | Messages: %d", m.modelName, len(m.messages))
+	if m.readOnly {
+		headerText += " [read-only]"
+	}
+	if m.offline {
+		headerText += " " + lipgloss.NewStyle().Foreground(lipgloss.Color("#FF0000")).Render("[offline]")
+	}
+	if m.starredOnly {
+		headerText += " [starred]"
+	}
+	if m.focusLast {
+		headerText += " [focus]"
+	}
+	if len(m.tabs) > 1 {
+		headerText += " | " + m.renderTabBar()
+	}
+	if m.configErr != nil {
+		headerText += " | " + lipgloss.NewStyle().Foreground(lipgloss.Color("#FF0000")).Render("config not loaded: "+m.configErr.Error())
+		// A long error must not wrap the header onto a second line
+		headerText = ansi.Truncate(headerText, m.width, "…")
+	}
 	header := lipgloss.NewStyle().
 		BorderStyle(lipgloss.NormalBorder()).
 		BorderBottom(true).
 		BorderForeground(amoblackColor).
-		Render(fmt.Sprintf("EKO - Model: %s | Messages: %d", m.modelName, len(m.messages)))
+		Render(headerText)
 
-	// Add status line for yank mode
+	// Add status line for yank mode and command feedback
 	statusLine := ""
-	if m.state == types.YankCodeState {
+	if m.benchCancel != nil {
+		statusLine = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("#FFFF00")).
+			Render(fmt.Sprintf("%s Benchmarking %d runs... ctrl+c to cancel", m.spinner.View(), m.benchTotal))
+	} else if m.state == types.YankCodeState {
 		statusLine = lipgloss.NewStyle().
 			Foreground(lipgloss.Color("#FFFF00")). // Yellow color for yank mode
 			Render("[YANK MODE] Enter code block ID: " + m.yankInput)
-	} else if m.yankStatus != "" && time.Since(m.yankStatusTimer) < 3*time.Second {
-		// Show yank status for 3 seconds
+	} else if m.state == types.DeleteState {
+		statusLine = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("#FFFF00")).
+			Render("[DELETE] Enter message ID: " + m.deleteInput)
+	} else if m.state == types.LoadConfirmState && m.pendingLoad != nil {
+		statusLine = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("#FFFF00")).
+			Render(fmt.Sprintf("Replace %d unsaved messages with %s (%d messages)? y: load  s: save current first  n: cancel",
+				len(m.messages), filepath.Base(m.pendingLoad.Path), len(m.pendingLoad.Session.Messages)))
+	} else if m.state == types.ClearConfirmState {
+		statusLine = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("#FFFF00")).
+			Render(fmt.Sprintf("Clear the conversation (%d messages)? y: clear  n: keep", len(m.messages)))
+	} else if m.state == types.StartOllamaState {
+		statusLine = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("#FFFF00")).
+			Render(fmt.Sprintf("Ollama isn't running at %s. Start it? y: start ollama serve  n: skip", m.ollamaClient.BaseURL))
+	} else if m.status != "" && time.Since(m.statusTimer) < 3*time.Second {
+		// Show status for 3 seconds
 		var style lipgloss.Style
-		if strings.HasPrefix(m.yankStatus, "✔") {
+		if strings.HasPrefix(m.status, "✔") {
 			style = lipgloss.NewStyle().Foreground(lipgloss.Color("#00FF00")) // Green for success
 		} else {
 			style = lipgloss.NewStyle().Foreground(lipgloss.Color("#FF0000")) // Red for error
 		}
-		statusLine = style.Render(m.yankStatus)
+		statusLine = style.Render(m.status)
 	}
 
 	inputView := ""
-	if m.state == types.InsertState || m.state == types.CommandState {
+	if m.state == types.InsertState || m.state == types.CommandState || m.state == types.ScratchpadState || m.state == types.ComfyURLState || m.state == types.SearchState {
 		inputView = m.input.View()
-	} else if m.state == types.YankCodeState {
-		// Don't show anything in input area for yank mode
+	} else if m.state == types.YankCodeState || m.state == types.DeleteState {
+		// Don't show anything in input area for yank or delete mode
 		inputView = ""
 	} else {
 		inputView = "press 'i' for insert mode\n q for quit"
@@ -78,42 +124,36 @@ func (m Model) renderMainView() string {
 		Render(inputView)
 
 	// Center everything on the screen
-	var content string
+	var sections []string
+	if !m.hideHeader {
+		sections = append(sections, header)
+	}
 	if statusLine != "" {
-		content = lipgloss.JoinVertical(
-			lipgloss.Center, // Center align vertically
-			header,
-			statusLine,
-			m.viewport.View(),
-			inputLine,
-		)
-	} else {
-		content = lipgloss.JoinVertical(
-			lipgloss.Center, // Center align vertically
-			header,
-			m.viewport.View(),
-			inputLine,
-		)
+		sections = append(sections, statusLine)
+	}
+	if scratchpad := m.renderScratchpad(); scratchpad != "" {
+		sections = append(sections, scratchpad)
 	}
+	sections = append(sections, m.viewport.View(), inputLine)
+	content := lipgloss.JoinVertical(
+		lipgloss.Center, // Center align vertically
+		sections...,
+	)
 
-	// Add IMAGE tag if in image mode
-	if m.isImageMode {
-		// Create a 1-line tag: [ image ] to save height
-		bracketStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#fe3f01"))
-		textStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#800000"))
-		
-		imageTag := fmt.Sprintf("%s%s%s", 
-			bracketStyle.Render("["),
-			textStyle.Render("image"),
-			bracketStyle.Render("]"),
-		)
-		
-		// Add queue count
-		queueText := fmt.Sprintf("%d ", m.queueCount)
-		queueStyled := lipgloss.NewStyle().Foreground(lipgloss.Color("#888888")).Render(queueText)
-		
-		fullTag := lipgloss.JoinHorizontal(lipgloss.Center, queueStyled, imageTag)
-		
+	// Footer: [image] with the ComfyUI queue count in image mode, or the
+	// connection, model and stream state in chat mode
+	footer := ""
+	if !m.hideHeader {
+		if m.isImageMode {
+			footer = renderFooterTag(m.queueCount, "image")
+			if waiting := m.comfyUIClient.Waiting(); waiting > 0 {
+				footer = renderFooterTag(waiting, "waiting") + " " + footer
+			}
+		} else {
+			footer = m.renderStatusFooter()
+		}
+	}
+	if footer != "" {
 		// Let's go with a footer row.
 		return lipgloss.JoinVertical(
 			lipgloss.Top,
@@ -125,7 +165,7 @@ func (m Model) renderMainView() string {
 			lipgloss.NewStyle().
 				Width(m.width).
 				Align(lipgloss.Right).
-				Render(fullTag),
+				Render(footer),
 		)
 	}
 
@@ -137,9 +177,64 @@ func (m Model) renderMainView() string {
 		Render(content)
 }
 
-// renderMessages renders all messages
-func (m Model) renderMessages() string {
+// hardWrap breaks lines wider than width columns, counting display width so
+// wide characters and ANSI styling are handled. Unlike the word wrapping done
+// by lipgloss it needs no spaces, so a single huge token can't blow out the layout.
+func hardWrap(s string, width int) string {
+	if width < 1 {
+		return s
+	}
+	return ansi.Hardwrap(s, width, true)
+}
+
+// renderFooterTag renders a 1-line tag such as "2 [image]" to save height
+func renderFooterTag(count int, label string) string {
+	bracketStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#fe3f01"))
+	textStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#800000"))
+
+	tag := fmt.Sprintf("%s%s%s",
+		bracketStyle.Render("["),
+		textStyle.Render(label),
+		bracketStyle.Render("]"),
+	)
+
+	countText := fmt.Sprintf("%d ", count)
+	countStyled := lipgloss.NewStyle().Foreground(lipgloss.Color("#888888")).Render(countText)
+
+	return lipgloss.JoinHorizontal(lipgloss.Center, countStyled, tag)
+}
+
+// renderScratchpad renders the pinned scratchpad panel, or nothing when hidden
+func (m Model) renderScratchpad() string {
+	if !m.showScratchpad {
+		return ""
+	}
+
+	notes := m.scratchpad
+	if notes == "" {
+		notes = "(empty - press S to edit)"
+	}
+
+	panelWidth := int(float64(m.width) * 0.8)
+	if panelWidth < 30 {
+		panelWidth = 30
+	}
+
+	title := lipgloss.NewStyle().Foreground(accentColor).Render("scratchpad")
+	return lipgloss.NewStyle().
+		BorderStyle(lipgloss.RoundedBorder()).
+		BorderForeground(subtleColor).
+		Foreground(defaultColor).
+		Padding(0, 1).
+		Width(panelWidth).
+		Render(title + "\n" + notes)
+}
+
+// renderMessages renders all messages, and reports the line each one starts
+// on (-1 for hidden ones) so :goto can scroll to it
+func (m Model) renderMessages() (string, []int) {
 	var b strings.Builder
+	offsets := make([]int, len(m.messages))
 
 	// Ensure minimum width to prevent panics
 	contentWidth := m.width
@@ -153,9 +248,26 @@ func (m Model) renderMessages() string {
 		messageWidth = 20
 	}
 
+	// Starred messages are shown however far back they are
+	first := 0
+	switch {
+	case m.focusLast:
+		first = m.focusStart()
+	case !m.starredOnly:
+		first = m.renderStart()
+		if first > 0 {
+			b.WriteString(renderOlderHint(first))
+			b.WriteString("\n")
+		}
+	}
 	for i, msg := range m.messages {
+		offsets[i] = -1
+		if i < first || m.starredOnly && !msg.Starred {
+			continue
+		}
+
 		// Add small breathing room between different message types
-		if i > 0 {
+		if i > first && !m.starredOnly {
 			prevMsg := m.messages[i-1]
 			if prevMsg.Role != msg.Role {
 				// Add a subtle separator between user and assistant messages
@@ -165,17 +277,29 @@ func (m Model) renderMessages() string {
 
 		// Content (with TLDR handling and code block processing)
 		content := msg.Content
-		if m.viewMode == types.TLDRMode && msg.IsCollapsed && len(content) > 100 {
-			content = content[:100] + "..."
+		if msg.Council != "" && content == "" && m.isThinking {
+			content = m.spinner.View() + " " + msg.Council + " is thinking..."
+		} else if (m.viewMode == types.TLDRMode || msg.Council != "") && msg.IsCollapsed && m.collapsible(content) {
+			// Council replies stay collapsed under the synthesis until :verbose
+			content = truncateGraphemes(content, m.collapseLimit()) + "..."
 		} else if msg.Role == "assistant" {
-			// Process code blocks for assistant messages
-			content = ReplaceCodeBlocksInContent(content, msg.ID, messageWidth)
+			// Markdown first, around the raw code blocks, which are then
+			// numbered and highlighted
+			content = m.renderMarkdown(content, messageWidth-2)
+			content = ReplaceCodeBlocksInContent(content, msg.ID, messageWidth, m.focusedBlock)
+		} else {
+			content = mapProse(content, func(text string) string {
+				return wrapProse(text, messageWidth-2)
+			})
+			content = m.highlightMatches(content)
 		}
+		// Break over-long lines (base64 blobs, minified code) inside the card's padding
+		content = hardWrap(content, messageWidth-2)
 
 		// Show spinner if this is the last message and still processing
 		if msg.Role == "assistant" && len(m.messages) > 0 &&
 			msg.ID == m.messages[len(m.messages)-1].ID && m.isThinking {
-			if m.isImageMode {
+			if msg.Image || m.isImageMode {
 				// Custom thin progress bar
 				barWidth := 30
 				
@@ -216,24 +340,39 @@ func (m Model) renderMessages() string {
 				// Time calculation: Elapsed / Total
 				elapsed := m.elapsedTime.Round(time.Second)
 				totalStr := "?"
+				if m.etaEstimate > 0 {
+					// Past runs of this workflow give a stable estimate from the start
+					totalStr = m.etaEstimate.Round(time.Second).String()
+				}
 				
 				if m.progressPct > 0.01 {
-					totalEstimated := time.Duration(float64(m.elapsedTime) / m.progressPct).Round(time.Second)
-					totalStr = totalEstimated.String()
+					totalEstimated := time.Duration(float64(m.elapsedTime) / m.progressPct)
+					if m.etaEstimate > 0 {
+						// Shift trust from history to live progress as generation advances
+						weight := m.progressPct
+						if weight > 1 {
+							weight = 1
+						}
+						totalEstimated = time.Duration((1-weight)*float64(m.etaEstimate) + weight*float64(totalEstimated))
+					}
+					totalStr = totalEstimated.Round(time.Second).String()
 				}
 				
 				timeStr := fmt.Sprintf(" | %s/%s", elapsed, totalStr)
 				
 				// Combine everything into one line
 				infoText := fmt.Sprintf("%s%s", nodePctStr, timeStr)
+				if seed, ok := m.imageSeeds[msg.ID]; ok {
+					infoText += fmt.Sprintf(" | seed %d", seed)
+				}
 				infoStyled := lipgloss.NewStyle().Foreground(lipgloss.Color("#888888")).Render(infoText)
 				
 				content = fmt.Sprintf("%s%s\n%s", filledStyled, emptyStyled, infoStyled)
-			} else if msg.Content == "" {
+			} else if strings.TrimSpace(msg.Content) == "" {
 				content = m.spinner.View() + " AI is thinking..."
 			} else {
-				// Show spinner while content is being streamed
-				content = content + " " + m.spinner.View()
+				// Show spinner while content is being streamed, right after the last word
+				content = strings.TrimRightFunc(content, unicode.IsSpace) + " " + m.spinner.View()
 			}
 		}
 
@@ -244,12 +383,48 @@ func (m Model) renderMessages() string {
 		if msg.Role == "assistant" {
 			divider := lipgloss.NewStyle().Foreground(lipgloss.Color("#808080")).Render(strings.Repeat("─", messageWidth-4))
 			metadata := fmt.Sprintf("%s | %s", msg.ID, timeStr)
+			if m.showIndices {
+				metadata = indexStyle.Render(fmt.Sprintf("[%d]", i+1)) + " " + metadata
+			}
+			if msg.Council != "" {
+				metadata += " | " + msg.Council
+			} else if msg.Model != "" {
+				metadata += " | " + msg.Model
+			}
+			if msg.Seed != nil {
+				metadata += fmt.Sprintf(" | seed %d", *msg.Seed)
+			}
+			if msg.Cached {
+				metadata += " | cached"
+			}
+			if msg.Meta != nil && msg.Meta.FirstToken > 0 {
+				metadata += " | " + formatLatency(*msg.Meta)
+			}
+			if msg.Starred {
+				metadata += " | " + starStyle.Render("★")
+			}
+			if msg.ID == m.failedID && i == len(m.messages)-1 {
+				metadata += " | " + starStyle.Render(". to retry")
+			}
+			if m.showMeta && msg.Meta != nil {
+				metadata += "\n" + formatMeta(*msg.Meta)
+			}
 			cardContent = fmt.Sprintf("%s\n%s\n%s", content, divider, metadata)
+		} else if !ollama.IsStandardRole(msg.Role) {
+			// Custom roles get a colored name tag so personas are easy to tell apart
+			label := lipgloss.NewStyle().Foreground(roleColor(msg.Role)).Bold(true).Render(msg.Role)
+			cardContent = fmt.Sprintf("%s\n%s", label, content)
 		} else {
 			// User messages: white text only, no divider, no metadata
 			textStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#FFFFFF"))
 			cardContent = textStyle.Render(content)
 		}
+		if msg.Starred && msg.Role != "assistant" {
+			cardContent = starStyle.Render("★") + " " + cardContent
+		}
+		if m.showIndices && msg.Role != "assistant" {
+			cardContent = indexStyle.Render(fmt.Sprintf("[%d]", i+1)) + " " + cardContent
+		}
 
 		// Create message card with no borders
 		var messageStyle lipgloss.Style
@@ -279,21 +454,59 @@ func (m Model) renderMessages() string {
 
 		// Render the message card
 		messageCard := messageStyle.Render(cardContent)
+		offsets[i] = b.Len()
 		b.WriteString(messageCard)
 		b.WriteString("\n")
 	}
 
-	return b.String()
+	// Queued prompts sit below the conversation until their turn comes
+	if len(m.promptQueue) > 0 {
+		leftMargin := contentWidth - messageWidth - 2
+		if leftMargin < 0 {
+			leftMargin = 0
+		}
+		queuedStyle := lipgloss.NewStyle().
+			Foreground(subtleColor).
+			Italic(true).
+			Padding(0, 1).
+			Width(messageWidth).
+			Align(lipgloss.Right).
+			MarginLeft(leftMargin)
+
+		b.WriteString("\n")
+		for _, prompt := range m.promptQueue {
+			b.WriteString(queuedStyle.Render("⧗ " + prompt))
+			b.WriteString("\n")
+		}
+	}
+
+	content := b.String()
+	return content, offsetsToLines(content, offsets)
+}
+
+// rolePalette holds the colors custom role names are assigned from
+var rolePalette = []lipgloss.Color{"#5FAFFF", "#AF87FF", "#5FD7AF", "#FFD75F", "#FF87AF", "#87D7FF"}
+
+// roleColor picks a stable color for a custom role name
+func roleColor(role string) lipgloss.Color {
+	var sum int
+	for _, r := range role {
+		sum += int(r)
+	}
+	return rolePalette[sum%len(rolePalette)]
 }
 
 // renderModelList renders the model selection list
 func (m Model) renderModelList() string {
 	if len(m.modelList) == 0 {
-		return "Loading models..."
+		if !m.modelsLoaded || m.refreshingModels {
+			return "Loading models..."
+		}
+		return "No models available — check Ollama connection\n\nPress r to refresh, esc to go back"
 	}
 
 	var b strings.Builder
-	b.WriteString("Select a model (j/k to navigate, enter to select, esc to cancel):\n\n")
+	b.WriteString("Select a model (j/k to navigate, enter to select, r to refresh, esc to cancel):\n\n")
 
 	for i, model := range m.modelList {
 		if i == m.selectedIdx {
@@ -305,3 +518,19 @@ func (m Model) renderModelList() string {
 
 	return b.String()
 }
+
+// renderSessionList renders the sessions matched by :find
+func (m Model) renderSessionList() string {
+	var b strings.Builder
+	b.WriteString("Matching sessions (j/k to navigate, enter to load, esc to cancel):\n\n")
+
+	for i, path := range m.sessionList {
+		if i == m.selectedIdx {
+			b.WriteString("> " + lipgloss.NewStyle().Foreground(accentColor).Render(path) + "\n")
+		} else {
+			b.WriteString("  " + path + "\n")
+		}
+	}
+
+	return b.String()
+}
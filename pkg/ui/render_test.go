@@ -0,0 +1,45 @@
+package ui
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+)
+
+func TestHardWrapHugeLine(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+	}{
+		{"ascii", strings.Repeat("abcdefghij", 10000)},
+		{"wide", strings.Repeat("漢字かな", 25000)},
+		{"styled", lipgloss.NewStyle().Foreground(codeColor).Render(strings.Repeat("x", 100000))},
+	}
+	const width = 73
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start := time.Now()
+			got := hardWrap(tt.line, width)
+			if elapsed := time.Since(start); elapsed > time.Second {
+				t.Errorf("wrapping took %s", elapsed)
+			}
+
+			lines := strings.Split(got, "\n")
+			for i, line := range lines {
+				if w := ansi.StringWidth(line); w > width {
+					t.Fatalf("line %d is %d columns wide, want at most %d", i, w, width)
+				}
+			}
+			if want := (ansi.StringWidth(tt.line) + width - 1) / width; len(lines) < want {
+				t.Errorf("%d lines, want at least %d", len(lines), want)
+			}
+			// Only line breaks are added
+			if plain := strings.ReplaceAll(ansi.Strip(got), "\n", ""); plain != ansi.Strip(tt.line) {
+				t.Error("wrapped text differs from the line")
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package ui
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// repetitionMarker ends a reply that was cut off for looping
+const repetitionMarker = "(stopped: repetition detected)"
+
+// maxRepeatUnit is the longest phrase, in bytes, checked for repetition
+const maxRepeatUnit = 80
+
+// minRepeatUnit is the shortest phrase, in bytes, checked for repetition
+const minRepeatUnit = 4
+
+// minRepeatRun is the fewest bytes a run of repeats must cover. Short phrases
+// need more copies to count.
+const minRepeatRun = 40
+
+// checkRepetition ends the stream once the reply ends in the same phrase
+// repeated repetitionLimit times in a row, as models stuck in a loop do. One
+// copy of the phrase is kept and the reply is marked as stopped.
+func (m *Model) checkRepetition() []tea.Cmd {
+	if m.repetitionLimit < 2 || !m.isThinking || len(m.messages) == 0 {
+		return nil
+	}
+
+	last := &m.messages[len(m.messages)-1]
+	cut, ok := repeatedTail(last.Content, m.repetitionLimit)
+	if !ok {
+		return nil
+	}
+
+	last.Content = strings.TrimRight(last.Content[:cut], " \n") + " " + repetitionMarker
+	m.endStream()
+	m.setStatus(false, "Stopped a reply that kept repeating itself")
+	return append([]tea.Cmd{m.updateViewportContent()}, m.dispatchQueuedPrompt()...)
+}
+
+// repeatedTail looks for a phrase repeated at least repeats times, and over at
+// least minRepeatRun bytes, at the end of content and returns where the second
+// copy of the run starts. Only phrases of whole words count: they need a letter
+// and a break between words, so rules, indentation, runs of braces and drawn
+// out words like "Hmmmm" or "zzzz" don't.
+func repeatedTail(content string, repeats int) (int, bool) {
+	for unit := minRepeatUnit; unit <= maxRepeatUnit; unit++ {
+		copies := max(repeats, (minRepeatRun+unit-1)/unit)
+		if unit*copies > len(content) {
+			continue
+		}
+		start := len(content) - unit*copies
+		phrase := content[len(content)-unit:]
+		if !utf8.ValidString(phrase) || !strings.ContainsFunc(phrase, unicode.IsLetter) || !strings.ContainsFunc(phrase, wordBreak) {
+			continue
+		}
+		if strings.Repeat(phrase, copies) != content[start:] {
+			continue
+		}
+		// Go back to the first copy of the run
+		for start >= unit && content[start-unit:start] == phrase {
+			start -= unit
+		}
+		return start + unit, true
+	}
+	return 0, false
+}
+
+// wordBreak reports whether r separates words
+func wordBreak(r rune) bool {
+	return unicode.IsSpace(r) || unicode.IsPunct(r)
+}
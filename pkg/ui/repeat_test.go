@@ -0,0 +1,55 @@
+package ui
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/thebug/lab/eko/v3/pkg/types"
+)
+
+func TestRepeatedTail(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    int
+		found   bool
+	}{
+		{"word loop", "Sure: " + strings.Repeat("the ", 12), len("Sure: the "), true},
+		{"phrase loop", "Ok. " + strings.Repeat("I am sorry. ", 10), len("Ok. I am sorry. "), true},
+		{"drawn out word", "H" + strings.Repeat("m", 200), 0, false},
+		{"snoring", strings.Repeat("z", 200), 0, false},
+		{"address", "Visit " + strings.Repeat("w", 60), 0, false},
+		{"rule", "Title\n" + strings.Repeat("=", 80), 0, false},
+		{"short run", "go go go go go go go go go go", 0, false},
+		{"too few copies", strings.Repeat("this keeps going on ", 9), 0, false},
+		{"prose", "The quick brown fox jumps over the lazy dog.", 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, found := repeatedTail(tt.content, 10)
+			if found != tt.found || got != tt.want {
+				t.Errorf("repeatedTail = %d, %v, want %d, %v", got, found, tt.want, tt.found)
+			}
+		})
+	}
+}
+
+func TestRepetitionStopsStreamThroughUpdate(t *testing.T) {
+	m := newTestModel(t)
+	m.repetitionLimit = 10
+	m.messages = []types.Message{{ID: "aa", Role: "user", Content: "q"}, {ID: "ba", Role: "assistant"}}
+
+	next, _ := m.Update(types.GenerationStartMsg{ID: "ba"})
+	m = next.(Model)
+	for i := 0; i < 20 && m.isThinking; i++ {
+		next, _ = m.Update(types.TokenMsg{ID: "ba", Token: "loop "})
+		m = next.(Model)
+	}
+
+	if m.isThinking {
+		t.Fatal("stream still running after the reply looped")
+	}
+	if got, want := m.messages[1].Content, "loop "+repetitionMarker; got != want {
+		t.Errorf("reply = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,42 @@
+package ui
+
+import tea "github.com/charmbracelet/bubbletea"
+
+// retryFailed re-runs the request behind the last reply that failed, in place
+// and with the same prompt, so a transient Ollama or ComfyUI error needs no
+// retyping
+func (m *Model) retryFailed() []tea.Cmd {
+	if m.sendBlocked() {
+		return nil
+	}
+	if m.isThinking {
+		m.setStatus(false, "Wait for the current response to finish")
+		return nil
+	}
+	i := m.messageIndex(m.failedID)
+	if m.failedID == "" || i < 0 {
+		m.setStatus(false, "No failed request to retry")
+		return nil
+	}
+	if i != len(m.messages)-1 {
+		m.setStatus(false, "Only the last reply can be retried")
+		return nil
+	}
+
+	id := m.failedID
+	m.failedID = ""
+	m.messages[i].Content = ""
+	m.messages[i].Cached = false
+	m.messages[i].Meta = nil
+
+	if m.messages[i].Image {
+		return m.startImage(id, m.lastImagePrompt)
+	}
+	m.messages[i].Model = m.modelName
+	m.messages[i].Seed = m.seed()
+	m.streaming = true
+	m.isThinking = true
+	m.currentStreamID = id
+	m.cacheKey = ""
+	return []tea.Cmd{m.startRealtimeStream(id), m.updateViewportContent(), m.scrollToBottom()}
+}
@@ -0,0 +1,160 @@
+package ui
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/thebug/lab/eko/v3/pkg/types"
+)
+
+// caseSensitivePrefix at the start of a search makes it match case, as in vim
+const caseSensitivePrefix = `\C`
+
+var searchMatchStyle = lipgloss.NewStyle().Background(lipgloss.Color("#ffd700")).Foreground(lipgloss.Color("#000000"))
+
+// ansiSequence matches the color codes already in rendered text, which
+// search highlighting must not match inside
+var ansiSequence = regexp.MustCompile(`\x1b\[[0-9;:]*[A-Za-z]`)
+
+// startSearch opens the / prompt
+func (m *Model) startSearch() {
+	m.state = types.SearchState
+	m.input.Focus()
+	m.input.Prompt = "/"
+	m.input.SetValue("")
+}
+
+// handleSearchState handles input at the / prompt. It returns true when the
+// key was consumed.
+func (m *Model) handleSearchState(msg tea.KeyMsg) (bool, tea.Cmd) {
+	switch msg.String() {
+	case "enter":
+		query := m.input.Value()
+		m.state = types.NormalState
+		m.input.Reset()
+		m.input.Prompt = ""
+		// An empty search repeats the last one
+		if query == "" {
+			query = m.searchQuery
+		}
+		if query == "" {
+			return true, nil
+		}
+
+		pattern, sensitive := strings.CutPrefix(query, caseSensitivePrefix)
+		if pattern == "" {
+			return true, nil
+		}
+		pattern = regexp.QuoteMeta(pattern)
+		if !sensitive {
+			pattern = "(?i)" + pattern
+		}
+		m.searchQuery = query
+		m.searchPattern = regexp.MustCompile(pattern)
+		// Start from the top of the screen, so the first match shown is the next one down
+		m.searchLine = m.viewport.YOffset - 1
+		m.searchHighlight = false
+		return true, m.nextMatch(1)
+
+	case "esc":
+		m.state = types.NormalState
+		m.input.Reset()
+		m.input.Prompt = ""
+		return true, nil
+	}
+	return false, nil
+}
+
+// clearSearch removes the search highlighting until the next n or N, like
+// vim's :nohlsearch
+func (m *Model) clearSearch() tea.Cmd {
+	if !m.searchHighlight {
+		return nil
+	}
+	m.searchHighlight = false
+	return m.updateViewportContent()
+}
+
+// nextMatch scrolls to the next (dir > 0) or previous (dir < 0) message
+// matching the search, wrapping around at the ends
+func (m *Model) nextMatch(dir int) tea.Cmd {
+	if m.searchPattern == nil {
+		m.setStatus(false, "No previous search")
+		return nil
+	}
+	var cmd tea.Cmd
+	if !m.searchHighlight {
+		m.searchHighlight = true
+		cmd = m.updateViewportContent()
+	}
+
+	// Only messages on screen can be scrolled to
+	var lines []int
+	for i, msg := range m.messages {
+		if i < len(m.messageLines) && m.messageLines[i] >= 0 && m.searchPattern.MatchString(msg.Content) {
+			lines = append(lines, m.messageLines[i])
+		}
+	}
+	if len(lines) == 0 {
+		m.setStatus(false, "Pattern not found: "+m.searchQuery)
+		return cmd
+	}
+
+	hit := -1
+	wrapped := false
+	if dir > 0 {
+		for i, line := range lines {
+			if line > m.searchLine {
+				hit = i
+				break
+			}
+		}
+		if hit < 0 {
+			hit, wrapped = 0, true
+		}
+	} else {
+		for i := len(lines) - 1; i >= 0; i-- {
+			if lines[i] < m.searchLine {
+				hit = i
+				break
+			}
+		}
+		if hit < 0 {
+			hit, wrapped = len(lines)-1, true
+		}
+	}
+
+	m.searchLine = lines[hit]
+	m.viewport.SetYOffset(m.searchLine)
+	status := fmt.Sprintf("/%s: match %d of %d", m.searchQuery, hit+1, len(lines))
+	if wrapped && len(lines) > 1 {
+		status += ", wrapped"
+	}
+	m.setStatus(true, status)
+	return cmd
+}
+
+// highlightMatches marks the search matches in rendered text, leaving the
+// color codes already in it alone
+func (m Model) highlightMatches(text string) string {
+	if !m.searchHighlight {
+		return text
+	}
+	var b strings.Builder
+	last := 0
+	highlight := func(plain string) string {
+		return m.searchPattern.ReplaceAllStringFunc(plain, func(match string) string {
+			return searchMatchStyle.Render(match)
+		})
+	}
+	for _, loc := range ansiSequence.FindAllStringIndex(text, -1) {
+		b.WriteString(highlight(text[last:loc[0]]))
+		b.WriteString(text[loc[0]:loc[1]])
+		last = loc[1]
+	}
+	b.WriteString(highlight(text[last:]))
+	return b.String()
+}
@@ -0,0 +1,72 @@
+package ui
+
+import (
+	"fmt"
+	"strconv"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/thebug/lab/eko/v3/pkg/config"
+)
+
+// seed returns the sampling seed sent to Ollama, or nil when sampling is
+// random. Sessions loaded from JSON hold it as a float.
+func (m Model) seed() *int64 {
+	var seed int64
+	switch v := m.ollamaClient.Options["seed"].(type) {
+	case int64:
+		seed = v
+	case int:
+		seed = int64(v)
+	case float64:
+		seed = int64(v)
+	default:
+		return nil
+	}
+	return &seed
+}
+
+// setSeed handles :seed. A fixed seed makes sampling reproducible: the same
+// prompt at the same temperature gets the same reply. "save" also writes the
+// seed to the config so later runs start with it.
+func (m *Model) setSeed(args []string) tea.Cmd {
+	if len(args) == 0 {
+		if seed := m.seed(); seed != nil {
+			m.setStatus(true, fmt.Sprintf("Seed %d", *seed))
+		} else {
+			m.setStatus(false, "No seed set. Usage: :seed <n> [save] | :seed off [save]")
+		}
+		return nil
+	}
+
+	var seed *int64
+	if args[0] != "off" {
+		n, err := strconv.ParseInt(args[0], 10, 64)
+		if err != nil {
+			m.setStatus(false, "Usage: :seed <n> [save] | :seed off [save]")
+			return nil
+		}
+		seed = &n
+	}
+	m.applySeed(seed)
+	if seed != nil {
+		m.setStatus(true, fmt.Sprintf("Seed %d", *seed))
+	} else {
+		m.setStatus(true, "Seed off")
+	}
+
+	if len(args) < 2 || args[1] != "save" {
+		return nil
+	}
+	return m.configManager.UpdateConfig(func(c *config.Config) {
+		c.Seed = seed
+	})
+}
+
+// applySeed sets or, when seed is nil, removes the seed option
+func (m *Model) applySeed(seed *int64) {
+	if seed != nil {
+		m.ollamaClient.SetOption("seed", *seed)
+	} else {
+		m.ollamaClient.SetOption("seed", nil)
+	}
+}
@@ -0,0 +1,60 @@
+package ui
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/thebug/lab/eko/v3/pkg/debuglog"
+	"github.com/thebug/lab/eko/v3/pkg/types"
+)
+
+// offerOllamaStart asks whether to start Ollama when it couldn't be reached,
+// once per run and only when start_ollama is enabled and it can be started here
+func (m *Model) offerOllamaStart(err error) {
+	var urlErr *url.Error
+	if !m.startOllama || m.ollamaOffered || !errors.As(err, &urlErr) || !m.ollamaClient.CanServe() {
+		return
+	}
+	// Don't pull the user out of something they're in the middle of
+	if m.state != types.NormalState {
+		return
+	}
+	m.ollamaOffered = true
+	m.state = types.StartOllamaState
+}
+
+// handleStartOllamaState handles the answer to starting Ollama
+func (m *Model) handleStartOllamaState(msg tea.KeyMsg) tea.Cmd {
+	switch msg.String() {
+	case "y":
+		m.state = types.NormalState
+		m.setStatus(true, "Starting Ollama...")
+		return m.ollamaClient.Serve()
+
+	case "n", "esc":
+		m.state = types.NormalState
+		m.setStatus(false, "Ollama not started")
+	}
+	return nil
+}
+
+// handleOllamaStarted reconnects once the server is up, or shows why it
+// couldn't start. The full output goes to the debug log.
+func (m *Model) handleOllamaStarted(msg types.OllamaStartedMsg) tea.Cmd {
+	if msg.Err != nil {
+		output := strings.TrimSpace(msg.Output)
+		if output != "" {
+			debuglog.Printf("%v\n%s", msg.Err, output)
+			lines := strings.Split(output, "\n")
+			m.setStatus(false, fmt.Sprintf("Couldn't start Ollama: %s (:log for output)", lines[len(lines)-1]))
+		} else {
+			m.setStatus(false, fmt.Sprintf("Couldn't start Ollama: %v", msg.Err))
+		}
+		return nil
+	}
+	m.setStatus(true, "Started Ollama")
+	return tea.Batch(m.ollamaClient.FetchModels(), m.ollamaClient.FetchVersion())
+}
@@ -0,0 +1,174 @@
+package ui
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/thebug/lab/eko/v3/pkg/session"
+	"github.com/thebug/lab/eko/v3/pkg/types"
+)
+
+// currentSession snapshots the conversation together with the settings it is
+// being held with, so a later :load reproduces the same model and options
+func (m Model) currentSession() types.Session {
+	var options map[string]interface{}
+	if len(m.ollamaClient.Options) > 0 {
+		options = make(map[string]interface{}, len(m.ollamaClient.Options))
+		for k, v := range m.ollamaClient.Options {
+			options[k] = v
+		}
+	}
+
+	messages := make([]types.Message, len(m.messages))
+	copy(messages, m.messages)
+
+	return types.Session{
+		Options: &types.SessionOptions{
+			Model:        m.modelName,
+			SystemPrompt: m.systemPrompt,
+			Options:      options,
+		},
+		Tags:       m.tags,
+		Scratchpad: m.scratchpad,
+		Messages:   messages,
+	}
+}
+
+// applySession replaces the conversation with a loaded session and applies its
+// options for the rest of this run. Message-only saves leave settings untouched.
+func (m *Model) applySession(s types.Session) {
+	m.pushUndo()
+	m.messages = s.Messages
+	ResetCodeBlocks(m.messages)
+	m.focusedBlock = ""
+	m.tags = s.Tags
+	m.scratchpad = s.Scratchpad
+	m.showScratchpad = s.Scratchpad != ""
+	m.resizeViewport()
+	if s.Options == nil {
+		return
+	}
+
+	if s.Options.Model != "" {
+		m.modelName = s.Options.Model
+	}
+	m.systemPrompt = s.Options.SystemPrompt
+	if s.Options.Options != nil {
+		m.ollamaClient.Options = s.Options.Options
+	}
+}
+
+// applyLoadedSession replaces the conversation with a session read by loadSession
+func (m *Model) applyLoadedSession(msg types.SessionLoadedMsg) []tea.Cmd {
+	m.applySession(msg.Session)
+	m.readOnly = msg.ReadOnly
+	m.savedMessages = cloneMessages(m.messages)
+	m.setStatus(true, fmt.Sprintf("Loaded %d messages from %s", len(m.messages), msg.Path))
+	m.warnUnsupportedFeatures()
+	return []tea.Cmd{m.updateViewportContent(), m.scrollToBottom()}
+}
+
+// unsaved reports whether the conversation has changed since it was last
+// saved or loaded
+func (m Model) unsaved() bool {
+	return len(m.messages) > 0 && !reflect.DeepEqual(m.messages, m.savedMessages)
+}
+
+// markSaved records a finished save as the tab's saved state, unless the
+// conversation has moved on since the snapshot was written
+func (m *Model) markSaved(msg types.SessionSavedMsg) {
+	if msg.Tab == m.activeTab {
+		if reflect.DeepEqual(m.messages, msg.Messages) {
+			m.savedMessages = msg.Messages
+		}
+		return
+	}
+	if msg.Tab < len(m.tabs) && reflect.DeepEqual(m.tabs[msg.Tab].messages, msg.Messages) {
+		m.tabs[msg.Tab].savedMessages = msg.Messages
+	}
+}
+
+// handleLoadConfirmState handles the answer to loading over unsaved messages
+func (m *Model) handleLoadConfirmState(msg tea.KeyMsg) []tea.Cmd {
+	pending := m.pendingLoad
+	switch msg.String() {
+	case "y":
+		m.pendingLoad = nil
+		m.state = types.NormalState
+		return m.applyLoadedSession(*pending)
+
+	case "s":
+		// Keep the current conversation under an autosave name, then load
+		m.pendingLoad = nil
+		m.state = types.NormalState
+		path := m.sessionPath("autosave-" + time.Now().Format("20060102-150405"))
+		sess := m.currentSession()
+		save := func() tea.Msg {
+			if err := session.Save(path, sess); err != nil {
+				return types.StatusMsg{Err: fmt.Errorf("autosave failed: %w", err)}
+			}
+			return types.StatusMsg{Text: "Saved previous conversation to " + path}
+		}
+		return append(m.applyLoadedSession(*pending), save)
+
+	case "n", "esc":
+		m.pendingLoad = nil
+		m.state = types.NormalState
+		m.setStatus(false, "Load cancelled")
+	}
+	return nil
+}
+
+// sessionPath resolves a session name to a file path, adding the .json
+// extension and placing relative names in the configured session directory
+func (m Model) sessionPath(name string) string {
+	if !strings.HasSuffix(name, ".json") {
+		name += ".json"
+	}
+	if m.sessionDir != "" && !filepath.IsAbs(name) {
+		return filepath.Join(m.sessionDir, name)
+	}
+	return name
+}
+
+// loadSession reads a session file in the background. readOnly opens it for
+// reference only, so nothing can be sent until :readonly is toggled off.
+func (m Model) loadSession(path string, readOnly bool) tea.Cmd {
+	return func() tea.Msg {
+		sess, err := session.Load(path)
+		return types.SessionLoadedMsg{Path: path, Session: sess, ReadOnly: readOnly, Err: err}
+	}
+}
+
+// handleSessionListState handles input while picking a session from :find results
+func (m *Model) handleSessionListState(msg tea.KeyMsg) tea.Cmd {
+	switch msg.String() {
+	case "j":
+		if m.selectedIdx < len(m.sessionList)-1 {
+			m.selectedIdx++
+		}
+		return nil
+
+	case "k":
+		if m.selectedIdx > 0 {
+			m.selectedIdx--
+		}
+		return nil
+
+	case "enter":
+		m.state = types.NormalState
+		if m.selectedIdx < len(m.sessionList) {
+			return m.loadSession(m.sessionList[m.selectedIdx], false)
+		}
+		return nil
+
+	case "esc":
+		m.state = types.NormalState
+		return nil
+	}
+	return nil
+}
@@ -0,0 +1,29 @@
+package ui
+
+import (
+	"testing"
+
+	"github.com/thebug/lab/eko/v3/pkg/types"
+)
+
+func TestApplySessionReplacesCodeBlocks(t *testing.T) {
+	m := newTestModel(t)
+	ReplaceCodeBlocksInContent("```go\nold()\n```\n```sh\nrm -rf old\n```", "ba", 80, "")
+	ReplaceCodeBlocksInContent("```go\nother()\n```", "da", 80, "")
+
+	// The loaded conversation reuses ID ba, with a single block
+	m.applySession(types.Session{Messages: []types.Message{
+		{ID: "aa", Role: "user", Content: "```go\nnot a reply\n```"},
+		{ID: "ba", Role: "assistant", Content: "Here:\n```python\nif x:\n    new()\n```"},
+	}})
+
+	block, ok := GetCodeBlock("baa")
+	if !ok || block.Content != "if x:\n    new()" || block.Language != "python" {
+		t.Errorf("baa = %+v, %v, want the loaded block", block, ok)
+	}
+	for _, id := range []string{"bab", "daa", "aaa"} {
+		if _, ok := GetCodeBlock(id); ok {
+			t.Errorf("%s still registered after loading", id)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package ui
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/thebug/lab/eko/v3/pkg/config"
+)
+
+// settableOptions are the generation options :set changes, in display order
+var settableOptions = []string{"temperature", "top_p", "num_ctx"}
+
+const setUsage = "Usage: :set temperature|top_p|num_ctx <value>|off"
+
+// setOption handles :set. With no arguments it shows the options in use;
+// otherwise it sets or, with "off", removes one, and writes it to the config
+// so later runs start with it. A trailing "save" is accepted and ignored.
+func (m *Model) setOption(args []string) tea.Cmd {
+	if len(args) == 0 {
+		var parts []string
+		for _, name := range settableOptions {
+			if value, ok := m.ollamaClient.Options[name]; ok {
+				parts = append(parts, fmt.Sprintf("%s %v", name, value))
+			}
+		}
+		if len(parts) == 0 {
+			m.setStatus(true, "Using the model's default options")
+		} else {
+			m.setStatus(true, strings.Join(parts, " | "))
+		}
+		return nil
+	}
+	if len(args) < 2 {
+		m.setStatus(false, setUsage)
+		return nil
+	}
+
+	name, raw := args[0], args[1]
+	off := raw == "off"
+	var update func(*config.Config)
+	switch name {
+	case "temperature", "top_p":
+		var value float64
+		if !off {
+			var err error
+			value, err = strconv.ParseFloat(raw, 64)
+			if err != nil || value < 0 || (name == "top_p" && value > 1) {
+				m.setStatus(false, fmt.Sprintf("Invalid %s %q", name, raw))
+				return nil
+			}
+		}
+		var saved *float64
+		switch {
+		case off:
+			m.ollamaClient.SetOption(name, nil)
+		case name == "temperature":
+			m.ollamaClient.SetTemperature(value)
+			saved = &value
+		default:
+			m.ollamaClient.SetTopP(value)
+			saved = &value
+		}
+		update = func(c *config.Config) {
+			if name == "temperature" {
+				c.Temperature = saved
+			} else {
+				c.TopP = saved
+			}
+		}
+	case "num_ctx":
+		value := 0
+		if off {
+			m.ollamaClient.SetOption(name, nil)
+		} else {
+			var err error
+			value, err = strconv.Atoi(raw)
+			if err != nil || value <= 0 {
+				m.setStatus(false, fmt.Sprintf("Invalid num_ctx %q", raw))
+				return nil
+			}
+			m.ollamaClient.SetNumCtx(value)
+		}
+		update = func(c *config.Config) {
+			c.NumCtx = value
+		}
+	default:
+		m.setStatus(false, setUsage)
+		return nil
+	}
+
+	if off {
+		m.setStatus(true, name+" off")
+	} else {
+		m.setStatus(true, fmt.Sprintf("%s %s", name, raw))
+	}
+	return m.configManager.UpdateConfig(update)
+}
@@ -0,0 +1,53 @@
+package ui
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/thebug/lab/eko/v3/pkg/config"
+	"github.com/thebug/lab/eko/v3/pkg/types"
+)
+
+func TestSetPersistsEveryChange(t *testing.T) {
+	m := newTestModel(t)
+	home, _ := os.UserHomeDir()
+	path := filepath.Join(home, config.ConfigDir, config.ConfigFile)
+
+	for _, command := range []string{"set temperature 0.3", "set num_ctx 8192", "set temperature off"} {
+		var cmd tea.Cmd
+		m, cmd = press(t, m, append(append([]string{":"}, typed(command)...), "enter")...)
+		if statuses := collect[types.StatusMsg](cmd); len(statuses) != 0 {
+			t.Fatalf("%s: %+v", command, statuses)
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"num_ctx": 8192`) || !strings.Contains(string(data), `"temperature": null`) {
+		t.Errorf("config = %s, want num_ctx saved and temperature cleared", data)
+	}
+}
+
+func TestSetReportsConfigWriteError(t *testing.T) {
+	m := newTestModel(t)
+	home, _ := os.UserHomeDir()
+	// A directory where the config file should be can't be written
+	if err := os.MkdirAll(filepath.Join(home, config.ConfigDir, config.ConfigFile), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	m, cmd := press(t, m, append(append([]string{":"}, typed("set top_p 0.9")...), "enter")...)
+	statuses := collect[types.StatusMsg](cmd)
+	if len(statuses) != 1 || statuses[0].Err == nil {
+		t.Fatalf("status messages = %+v, want the write error", statuses)
+	}
+	next, _ := m.Update(statuses[0])
+	if got := next.(Model).status; !strings.HasPrefix(got, "✖ couldn't save config") {
+		t.Errorf("status = %q", got)
+	}
+}
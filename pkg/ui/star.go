@@ -0,0 +1,37 @@
+package ui
+
+import (
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+var starStyle = lipgloss.NewStyle().Foreground(accentColor)
+
+// toggleStar stars or unstars the message with the given ID, or the last
+// reply when id is empty. Stars are saved with the session.
+func (m *Model) toggleStar(id string) tea.Cmd {
+	idx := -1
+	for i := len(m.messages) - 1; i >= 0; i-- {
+		if id == "" && m.messages[i].Role == "assistant" || id != "" && m.messages[i].ID == id {
+			idx = i
+			break
+		}
+	}
+	if idx < 0 {
+		if id == "" {
+			m.setStatus(false, "No reply to star")
+		} else {
+			m.setStatus(false, "No message with ID "+id)
+		}
+		return nil
+	}
+
+	msg := &m.messages[idx]
+	msg.Starred = !msg.Starred
+	if msg.Starred {
+		m.setStatus(true, "Starred "+msg.ID)
+	} else {
+		m.setStatus(true, "Unstarred "+msg.ID)
+	}
+	return m.updateViewportContent()
+}
@@ -0,0 +1,61 @@
+package ui
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/thebug/lab/eko/v3/pkg/types"
+)
+
+// chunkingServer streams chunks chat chunks, then holds the response open
+// until the client goes away, like a model that keeps generating
+func chunkingServer(t *testing.T, chunks int) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		flusher := w.(http.Flusher)
+		for i := 0; i < chunks; i++ {
+			fmt.Fprintf(w, `{"message":{"role":"assistant","content":"w%d "},"done":false}`+"\n", i)
+			flusher.Flush()
+		}
+		<-r.Context().Done()
+	}))
+	// A leaked stream would keep its response open, and Close waits for it
+	t.Cleanup(func() {
+		srv.CloseClientConnections()
+		srv.Close()
+	})
+	return srv
+}
+
+func TestQuitStopsStreamGoroutine(t *testing.T) {
+	m := newTestModel(t)
+	srv := chunkingServer(t, 2*cap(m.msgChan))
+	m.ollamaClient.BaseURL = srv.URL
+	m.messages = []types.Message{{ID: "aa", Role: "user", Content: "q"}, {ID: "ba", Role: "assistant"}}
+	before := runtime.NumGoroutine()
+
+	m.streamInto(m.beginStream(), "ba", m.chatHistory("ba"))()
+
+	// Nothing reads the channel, so the stream fills it and blocks mid-reply
+	deadline := time.Now().Add(5 * time.Second)
+	for len(m.msgChan) < cap(m.msgChan) {
+		if time.Now().After(deadline) {
+			t.Fatalf("stream sent %d messages, want the channel filled", len(m.msgChan))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	m.quit()
+
+	deadline = time.Now().Add(5 * time.Second)
+	for runtime.NumGoroutine() > before {
+		if time.Now().After(deadline) {
+			buf := make([]byte, 1<<16)
+			t.Fatalf("%d goroutines left running after quit, want %d:\n%s", runtime.NumGoroutine(), before, buf[:runtime.Stack(buf, true)])
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,237 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/thebug/lab/eko/v3/pkg/ollama"
+	"github.com/thebug/lab/eko/v3/pkg/types"
+)
+
+// conversation holds the per-tab state that is swapped in and out of the Model.
+// Each tab has its own stream channel so a background tab keeps generating.
+type conversation struct {
+	name            string
+	messages        []types.Message
+	modelName       string
+	systemPrompt    string
+	options         map[string]interface{}
+	tags            []string
+	scratchpad      string
+	promptQueue     []string
+	councilModels   []string
+	lastImagePrompt string
+	imageSeeds      map[string]int64
+	msgChan         chan tea.Msg
+	streaming       bool
+	isThinking      bool
+	currentStreamID string
+	streamCancel    context.CancelFunc
+	pendingTokens   string
+	failedID        string
+	cacheKey        string
+	startTime       time.Time
+	progressPct     float64
+	nodeProgress    string
+	elapsedTime     time.Duration
+	undoStack       [][]types.Message
+	redoStack       [][]types.Message
+	readOnly        bool
+	noAffixes       bool
+	savedMessages   []types.Message
+	yOffset         int
+}
+
+// saveTab stores the active conversation back into its tab slot. The scroll
+// position is only recorded by switchTab since the viewport is shared.
+func (m *Model) saveTab() {
+	m.tabs[m.activeTab] = conversation{
+		name:            m.tabs[m.activeTab].name,
+		messages:        m.messages,
+		modelName:       m.modelName,
+		systemPrompt:    m.systemPrompt,
+		options:         m.ollamaClient.Options,
+		tags:            m.tags,
+		scratchpad:      m.scratchpad,
+		promptQueue:     m.promptQueue,
+		councilModels:   m.councilModels,
+		lastImagePrompt: m.lastImagePrompt,
+		imageSeeds:      m.imageSeeds,
+		msgChan:         m.msgChan,
+		streaming:       m.streaming,
+		isThinking:      m.isThinking,
+		currentStreamID: m.currentStreamID,
+		streamCancel:    m.streamCancel,
+		pendingTokens:   m.pendingTokens,
+		failedID:        m.failedID,
+		cacheKey:        m.cacheKey,
+		startTime:       m.startTime,
+		progressPct:     m.progressPct,
+		nodeProgress:    m.nodeProgress,
+		elapsedTime:     m.elapsedTime,
+		undoStack:       m.undoStack,
+		redoStack:       m.redoStack,
+		readOnly:        m.readOnly,
+		noAffixes:       m.noAffixes,
+		savedMessages:   m.savedMessages,
+		yOffset:         m.tabs[m.activeTab].yOffset,
+	}
+}
+
+// loadTab makes tab i the active conversation. The caller must saveTab first.
+func (m *Model) loadTab(i int) {
+	t := m.tabs[i]
+	m.activeTab = i
+	m.messages = t.messages
+	m.modelName = t.modelName
+	m.systemPrompt = t.systemPrompt
+	m.ollamaClient.Options = t.options
+	m.tags = t.tags
+	m.scratchpad = t.scratchpad
+	m.promptQueue = t.promptQueue
+	m.councilModels = t.councilModels
+	m.lastImagePrompt = t.lastImagePrompt
+	m.imageSeeds = t.imageSeeds
+	m.msgChan = t.msgChan
+	m.streaming = t.streaming
+	m.isThinking = t.isThinking
+	m.currentStreamID = t.currentStreamID
+	m.streamCancel = t.streamCancel
+	m.pendingTokens = t.pendingTokens
+	m.failedID = t.failedID
+	m.cacheKey = t.cacheKey
+	m.startTime = t.startTime
+	m.progressPct = t.progressPct
+	m.nodeProgress = t.nodeProgress
+	m.elapsedTime = t.elapsedTime
+	m.undoStack = t.undoStack
+	m.redoStack = t.redoStack
+	m.readOnly = t.readOnly
+	m.noAffixes = t.noAffixes
+	m.savedMessages = t.savedMessages
+}
+
+// switchTab activates tab i and restores its scroll position
+func (m *Model) switchTab(i int) {
+	if i == m.activeTab {
+		return
+	}
+	m.tabs[m.activeTab].yOffset = m.viewport.YOffset
+	m.saveTab()
+	m.loadTab(i)
+	m.olderShown = 0
+	m.showActiveTab(m.tabs[i].yOffset)
+}
+
+// showActiveTab renders the active tab into the viewport at the given scroll position
+func (m *Model) showActiveTab(yOffset int) {
+	content, lines := m.renderMessages()
+	m.viewport.SetContent(content)
+	m.codeBlockPositions = locateCodeBlocks(content, m.messages)
+	m.messageLines = lines
+	m.viewport.SetYOffset(yOffset)
+}
+
+// tabClient returns a client for a request made in the background on behalf
+// of the active tab. It keeps sending that tab's options after another tab is
+// switched in.
+func (m Model) tabClient() *ollama.Client {
+	return m.ollamaClient.WithOptions(m.ollamaClient.Options)
+}
+
+// newTab opens an empty conversation that inherits the current model, system
+// prompt and generation options
+func (m *Model) newTab(name string) {
+	if name == "" {
+		name = fmt.Sprintf("%d", len(m.tabs)+1)
+	}
+	m.tabs[m.activeTab].yOffset = m.viewport.YOffset
+	m.saveTab()
+	m.tabs = append(m.tabs, conversation{
+		name:         name,
+		modelName:    m.modelName,
+		systemPrompt: m.systemPrompt,
+		// SetOption replaces the map, so the tabs can share it until one changes
+		options: m.ollamaClient.Options,
+		msgChan: make(chan tea.Msg, 100),
+	})
+	m.loadTab(len(m.tabs) - 1)
+	m.showActiveTab(0)
+}
+
+// closeTab removes the active tab and switches to its left neighbour
+func (m *Model) closeTab() {
+	if len(m.tabs) == 1 {
+		m.setStatus(false, "Cannot close the last tab")
+		return
+	}
+	if m.isThinking {
+		m.setStatus(false, "Wait for this tab's response to finish before closing it")
+		return
+	}
+
+	closed := m.activeTab
+	m.tabs = append(m.tabs[:closed], m.tabs[closed+1:]...)
+	next := closed - 1
+	if next < 0 {
+		next = 0
+	}
+	// The closed tab's state is discarded, so load without saving
+	m.loadTab(next)
+	m.showActiveTab(m.tabs[next].yOffset)
+}
+
+// drainBackgroundTabs applies at most one pending stream message per inactive
+// tab by swapping it in, running the usual handler and swapping back. Only the
+// active tab's view changes: viewport refreshes and scrolls the handler
+// requests are tagged with its tab index and ignored, and the status line it
+// sets is put back.
+func (m *Model) drainBackgroundTabs() []tea.Cmd {
+	var cmds []tea.Cmd
+	active := m.activeTab
+	for i := range m.tabs {
+		if i == active {
+			continue
+		}
+		select {
+		case streamMsg := <-m.tabs[i].msgChan:
+			status, statusTimer := m.status, m.statusTimer
+			m.saveTab()
+			m.loadTab(i)
+			cmds = append(cmds, m.handleStreamMsg(streamMsg)...)
+			m.saveTab()
+			m.loadTab(active)
+			m.status, m.statusTimer = status, statusTimer
+		default:
+		}
+	}
+	return cmds
+}
+
+// renderTabBar renders the tab names with the active one highlighted
+func (m Model) renderTabBar() string {
+	activeStyle := lipgloss.NewStyle().Foreground(accentColor).Bold(true)
+	inactiveStyle := lipgloss.NewStyle().Foreground(subtleColor)
+
+	var parts []string
+	for i, t := range m.tabs {
+		label := fmt.Sprintf("%d:%s", i+1, t.name)
+		busy := t.isThinking
+		if i == m.activeTab {
+			busy = m.isThinking
+		}
+		if busy {
+			label += "…"
+		}
+		if i == m.activeTab {
+			parts = append(parts, activeStyle.Render("["+label+"]"))
+		} else {
+			parts = append(parts, inactiveStyle.Render(" "+label+" "))
+		}
+	}
+	return strings.Join(parts, " ")
+}
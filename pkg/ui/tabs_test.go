@@ -0,0 +1,106 @@
+package ui
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/thebug/lab/eko/v3/pkg/types"
+)
+
+func TestTabsKeepTheirOwnOptions(t *testing.T) {
+	m := newTestModel(t)
+	m.ollamaClient.SetTemperature(0.2)
+
+	m.newTab("")
+	if got := m.ollamaClient.Options["temperature"]; got != 0.2 {
+		t.Errorf("new tab temperature = %v, want the inherited 0.2", got)
+	}
+	m.ollamaClient.SetTemperature(1.5)
+	m.ollamaClient.SetNumCtx(8192)
+
+	m.switchTab(0)
+	if got := m.ollamaClient.Options["temperature"]; got != 0.2 {
+		t.Errorf("first tab temperature = %v, want 0.2", got)
+	}
+	if _, ok := m.ollamaClient.Options["num_ctx"]; ok {
+		t.Error("num_ctx set in the second tab leaked into the first")
+	}
+	m.switchTab(1)
+	if got := m.ollamaClient.Options["temperature"]; got != 1.5 {
+		t.Errorf("second tab temperature = %v, want 1.5", got)
+	}
+}
+
+func TestStreamSendsItsTabsOptions(t *testing.T) {
+	requests := make(chan map[string]interface{}, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Options map[string]interface{} `json:"options"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		requests <- body.Options
+		fmt.Fprintln(w, `{"message":{"role":"assistant","content":"hi"},"done":true}`)
+	}))
+	t.Cleanup(srv.Close)
+
+	m := newTestModel(t)
+	m.ollamaClient.BaseURL = srv.URL
+	m.ollamaClient.SetTemperature(0.2)
+	m.messages = []types.Message{{ID: "aa", Role: "user", Content: "q"}, {ID: "ba", Role: "assistant"}}
+	cmd := m.streamInto(m.beginStream(), "ba", m.chatHistory("ba"))
+
+	// Another tab with other options is switched in before the request is made
+	m.newTab("")
+	m.ollamaClient.SetTemperature(1.5)
+	cmd()
+
+	select {
+	case options := <-requests:
+		if options["temperature"] != 0.2 {
+			t.Errorf("temperature sent = %v, want the first tab's 0.2", options["temperature"])
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no request made")
+	}
+}
+
+func TestBackgroundTabLeavesViewAlone(t *testing.T) {
+	m := newTestModel(t)
+	m.messages = []types.Message{{ID: "aa", Role: "user", Content: "q"}, {ID: "ba", Role: "assistant"}}
+	m.isThinking = true
+	m.currentStreamID = "ba"
+	m.newTab("")
+	m.setStatus(true, "second tab")
+
+	background := m.tabs[0].msgChan
+	background <- types.StatusMsg{Err: fmt.Errorf("tee: disk full")}
+	background <- types.TokenMsg{ID: "ba", Token: "Hello"}
+	background <- types.GenerationDoneMsg{ID: "ba"}
+
+	var cmds []tea.Cmd
+	for len(background) > 0 {
+		next, cmd := m.Update(types.RedrawMsg{})
+		m = next.(Model)
+		cmds = append(cmds, cmd)
+	}
+
+	if m.status != "✔ second tab" {
+		t.Errorf("status = %q, want the active tab's", m.status)
+	}
+	if got := m.tabs[0].messages[1].Content; got != "Hello" {
+		t.Errorf("background reply = %q, want %q", got, "Hello")
+	}
+	if m.tabs[0].isThinking {
+		t.Error("background stream still running")
+	}
+	// Scrolls the background tab asks for are for it alone
+	next, _ := m.Update(types.ScrollToBottomMsg{Tab: 0})
+	if next.(Model).viewport.YOffset != m.viewport.YOffset {
+		t.Error("background tab scrolled the active one")
+	}
+}
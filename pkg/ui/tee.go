@@ -0,0 +1,56 @@
+package ui
+
+import (
+	"fmt"
+	"os"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/thebug/lab/eko/v3/pkg/ollama"
+	"github.com/thebug/lab/eko/v3/pkg/types"
+)
+
+// teeObserver appends a streaming reply to a file as it arrives, with a blank
+// line after each reply
+type teeObserver struct {
+	path   string
+	emit   func(tea.Msg) bool
+	failed bool
+}
+
+func (t *teeObserver) Token(token string) bool {
+	t.write(token)
+	return true
+}
+
+func (t *teeObserver) Done(string, types.ResponseMeta) {
+	t.write("\n\n")
+}
+
+// write appends s to the tee file. A failure is reported once and the rest
+// of the reply skipped, without interrupting the stream itself.
+func (t *teeObserver) write(s string) {
+	if t.failed {
+		return
+	}
+	f, err := os.OpenFile(t.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err == nil {
+		_, err = f.WriteString(s)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		t.failed = true
+		t.emit(types.StatusMsg{Err: fmt.Errorf("tee: %w", err)})
+	}
+}
+
+// streamObservers are the extra observers of a reply streamed in this model,
+// besides the UI itself
+func (m Model) streamObservers() []ollama.StreamObserver {
+	var observers []ollama.StreamObserver
+	if m.teePath != "" {
+		observers = append(observers, &teeObserver{path: m.teePath, emit: m.emit})
+	}
+	return observers
+}
@@ -0,0 +1,46 @@
+package ui
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/thebug/lab/eko/v3/pkg/types"
+)
+
+func TestTeeAppendsReplies(t *testing.T) {
+	m := newTestModel(t)
+	path := filepath.Join(t.TempDir(), "replies.md")
+
+	tee := &teeObserver{path: path, emit: m.emit}
+	tee.Token("Hello")
+	tee.Token(" there")
+	tee.Done("stop", types.ResponseMeta{})
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(data); got != "Hello there\n\n" {
+		t.Errorf("tee file = %q", got)
+	}
+}
+
+func TestTeeErrorReachesStatusLine(t *testing.T) {
+	m := newTestModel(t)
+	path := filepath.Join(t.TempDir(), "missing", "replies.md")
+
+	tee := &teeObserver{path: path, emit: m.emit}
+	tee.Token("Hello")
+	tee.Token(" there")
+	if n := len(m.msgChan); n != 1 {
+		t.Fatalf("%d messages sent, want the error reported once", n)
+	}
+
+	// The error arrives through the stream channel, read on the next update
+	next, _ := m.Update(types.RedrawMsg{})
+	if got := next.(Model).status; !strings.HasPrefix(got, "✖ tee:") {
+		t.Errorf("status = %q, want the tee error", got)
+	}
+}
@@ -0,0 +1,105 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+	"unicode"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/thebug/lab/eko/v3/pkg/session"
+	"github.com/thebug/lab/eko/v3/pkg/types"
+)
+
+const (
+	// titlePrompt asks for a session title from the conversation's first message
+	titlePrompt = "Write a title of at most six words for a conversation that starts with the message below. " +
+		"Reply with the title only, without quotes or punctuation.\n\n"
+	// titleTimeout bounds how long :save waits for the model to name the session
+	titleTimeout = 20 * time.Second
+	// maxTitleLen caps the length of a file name made from a title
+	maxTitleLen = 50
+	// maxTitleInput caps how much of the first message is sent for titling
+	maxTitleInput = 2000
+)
+
+// saveTitled saves sess under a title the model writes for it, for :save
+// without a name. If the conversation has no user message or the model
+// can't be asked, the model name and time are used instead.
+func (m Model) saveTitled(sess types.Session, tab int) tea.Cmd {
+	m.ollamaClient = m.tabClient()
+	return func() tea.Msg {
+		name := session.SafeName(m.modelName) + "-" + time.Now().Format("20060102-150405")
+		if title := m.sessionTitle(sess.Messages); title != "" {
+			name = title
+		}
+
+		// Never overwrite an earlier session that got the same title
+		path := m.sessionPath(name)
+		for n := 2; ; n++ {
+			if _, err := os.Stat(path); os.IsNotExist(err) {
+				break
+			}
+			path = m.sessionPath(fmt.Sprintf("%s-%d", name, n))
+		}
+
+		err := session.Save(path, sess)
+		return types.SessionSavedMsg{Path: path, Tab: tab, Messages: sess.Messages, Err: err}
+	}
+}
+
+// sessionTitle asks the model to title a conversation from its first user
+// message and turns the answer into a file name, or returns "" on failure
+func (m Model) sessionTitle(messages []types.Message) string {
+	first := ""
+	for _, msg := range messages {
+		if msg.Role == "user" && !msg.Image {
+			first = msg.Content
+			break
+		}
+	}
+	if strings.TrimSpace(first) == "" {
+		return ""
+	}
+	if runes := []rune(first); len(runes) > maxTitleInput {
+		first = string(runes[:maxTitleInput])
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), titleTimeout)
+	defer cancel()
+	title, err := m.ollamaClient.Chat(ctx, m.modelName, []types.Message{{Role: "user", Content: titlePrompt + first}})
+	if err != nil {
+		return ""
+	}
+	return titleSlug(title)
+}
+
+// titleSlug turns a title into a lowercase, dash-separated file name, e.g.
+// "Fixing the Borrow Checker!" becomes "fixing-the-borrow-checker"
+func titleSlug(title string) string {
+	// Only the first line, in case the model explained itself
+	title, _, _ = strings.Cut(strings.TrimSpace(title), "\n")
+
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(title) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			dash = false
+		} else {
+			dash = true
+		}
+		if b.Len() >= maxTitleLen {
+			break
+		}
+	}
+	if b.Len() == 0 {
+		return ""
+	}
+	return session.SafeName(b.String())
+}
@@ -0,0 +1,66 @@
+package ui
+
+import (
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/thebug/lab/eko/v3/pkg/types"
+)
+
+// maxUndo bounds how many conversation snapshots are kept per tab
+const maxUndo = 20
+
+// cloneMessages copies a conversation so later in-place edits (e.g. streamed
+// tokens) don't leak into a snapshot
+func cloneMessages(messages []types.Message) []types.Message {
+	if messages == nil {
+		return nil
+	}
+	clone := make([]types.Message, len(messages))
+	copy(clone, messages)
+	return clone
+}
+
+// pushUndo snapshots the conversation before a destructive change. Any redo
+// history is dropped since it no longer follows from the current state.
+func (m *Model) pushUndo() {
+	m.undoStack = append(m.undoStack, cloneMessages(m.messages))
+	if len(m.undoStack) > maxUndo {
+		m.undoStack = m.undoStack[len(m.undoStack)-maxUndo:]
+	}
+	m.redoStack = nil
+}
+
+// undo restores the conversation as it was before the last destructive change
+func (m *Model) undo() tea.Cmd {
+	if m.isThinking {
+		m.setStatus(false, "Wait for the current response to finish before undoing")
+		return nil
+	}
+	if len(m.undoStack) == 0 {
+		m.setStatus(false, "Nothing to undo")
+		return nil
+	}
+
+	m.redoStack = append(m.redoStack, cloneMessages(m.messages))
+	m.messages = m.undoStack[len(m.undoStack)-1]
+	m.undoStack = m.undoStack[:len(m.undoStack)-1]
+	m.setStatus(true, "Undone")
+	return m.updateViewportContent()
+}
+
+// redo re-applies a change reverted by undo
+func (m *Model) redo() tea.Cmd {
+	if m.isThinking {
+		m.setStatus(false, "Wait for the current response to finish before redoing")
+		return nil
+	}
+	if len(m.redoStack) == 0 {
+		m.setStatus(false, "Nothing to redo")
+		return nil
+	}
+
+	m.undoStack = append(m.undoStack, cloneMessages(m.messages))
+	m.messages = m.redoStack[len(m.redoStack)-1]
+	m.redoStack = m.redoStack[:len(m.redoStack)-1]
+	m.setStatus(true, "Redone")
+	return m.updateViewportContent()
+}
@@ -0,0 +1,42 @@
+package ui
+
+import (
+	"fmt"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// renderStart is the index of the first message rendered into the viewport.
+// With render_window set, only the last renderWindow messages are rendered,
+// plus those brought in with k at the top; every message is still sent.
+func (m Model) renderStart() int {
+	if m.renderWindow <= 0 {
+		return 0
+	}
+	start := len(m.messages) - m.renderWindow - m.olderShown
+	if start < 0 {
+		return 0
+	}
+	return start
+}
+
+// showOlderMessages renders another window of earlier messages above the
+// current ones, keeping the view on the message that was at the top
+func (m *Model) showOlderMessages() tea.Cmd {
+	start := m.renderStart()
+	if start == 0 {
+		return nil
+	}
+	m.olderShown += m.renderWindow
+	m.scrollAnchor = m.messages[start].ID
+	return m.updateViewportContent()
+}
+
+// renderOlderHint tells how many messages above the window aren't rendered
+func renderOlderHint(hidden int) string {
+	return lipgloss.NewStyle().
+		Foreground(subtleColor).
+		Italic(true).
+		Render(fmt.Sprintf("▲ %d earlier messages, press k at the top to show more", hidden))
+}
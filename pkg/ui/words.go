@@ -0,0 +1,46 @@
+package ui
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+// appendToken adds a streamed token to the reply being generated, the last
+// message. With word_streaming on, text is held back until a word boundary so
+// the reply appears a word at a time rather than in fragments of words.
+func (m *Model) appendToken(token string) {
+	last := &m.messages[len(m.messages)-1]
+	if !m.wordStreaming || last.Image {
+		last.Content += token
+		return
+	}
+	m.pendingTokens += token
+	if cut := lastWordBoundary(m.pendingTokens); cut > 0 {
+		last.Content += m.pendingTokens[:cut]
+		m.pendingTokens = m.pendingTokens[cut:]
+	}
+}
+
+// flushTokens adds the text held back by appendToken to the streaming reply
+func (m *Model) flushTokens() {
+	if m.pendingTokens == "" {
+		return
+	}
+	if i := m.messageIndex(m.currentStreamID); i >= 0 {
+		m.messages[i].Content += m.pendingTokens
+	}
+	m.pendingTokens = ""
+}
+
+// lastWordBoundary returns the length of the longest prefix of s that ends in
+// whitespace or punctuation, or 0 when s has none
+func lastWordBoundary(s string) int {
+	for i := len(s); i > 0; {
+		r, size := utf8.DecodeLastRuneInString(s[:i])
+		if unicode.IsSpace(r) || unicode.IsPunct(r) {
+			return i
+		}
+		i -= size
+	}
+	return 0
+}
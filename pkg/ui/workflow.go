@@ -0,0 +1,126 @@
+package ui
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/thebug/lab/eko/v3/pkg/config"
+	"github.com/thebug/lab/eko/v3/pkg/types"
+)
+
+// workflowDirectory is where :workflow looks for workflows: workflow_dir from
+// the config, or else the directory of the current workflow
+func (m Model) workflowDirectory() string {
+	if m.workflowDir != "" {
+		return m.workflowDir
+	}
+	if m.workflowPath != "" {
+		return filepath.Dir(m.workflowPath)
+	}
+	return "."
+}
+
+// workflowFile resolves a :workflow argument to a file, adding the .json
+// extension and looking names up in the workflow directory
+func (m Model) workflowFile(name string) string {
+	name = config.ExpandPath(name)
+	if !strings.HasSuffix(name, ".json") {
+		name += ".json"
+	}
+	if _, err := os.Stat(name); err != nil && !filepath.IsAbs(name) {
+		name = filepath.Join(m.workflowDirectory(), name)
+	}
+	if abs, err := filepath.Abs(name); err == nil {
+		return abs
+	}
+	return name
+}
+
+// selectWorkflow makes the workflow at path the one images are generated
+// with and saves the choice, provided it is a graph eko can fill in
+func (m *Model) selectWorkflow(path string) tea.Cmd {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		m.setStatus(false, fmt.Sprintf("Can't read workflow: %v", err))
+		return nil
+	}
+	if err := m.comfyUIClient.ValidateWorkflow(data); err != nil {
+		m.setStatus(false, fmt.Sprintf("Can't use %s: %v", filepath.Base(path), err))
+		return nil
+	}
+
+	m.workflowPath = path
+	m.comfyUIWorkflow = data
+	m.setStatus(true, "Workflow "+filepath.Base(path))
+	return m.configManager.UpdateConfig(func(c *config.Config) {
+		c.WorkflowPath = path
+	})
+}
+
+// openWorkflowList lists the workflows in the workflow directory to pick from
+func (m *Model) openWorkflowList() {
+	dir := m.workflowDirectory()
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil || len(files) == 0 {
+		m.setStatus(false, "No workflows in "+dir)
+		return
+	}
+
+	m.workflowList = files
+	m.selectedIdx = 0
+	for i, file := range files {
+		if file == m.workflowPath {
+			m.selectedIdx = i
+		}
+	}
+	m.state = types.WorkflowListState
+}
+
+// handleWorkflowListState handles input while picking a workflow
+func (m *Model) handleWorkflowListState(msg tea.KeyMsg) tea.Cmd {
+	switch msg.String() {
+	case "j", "down":
+		if m.selectedIdx < len(m.workflowList)-1 {
+			m.selectedIdx++
+		}
+
+	case "k", "up":
+		if m.selectedIdx > 0 {
+			m.selectedIdx--
+		}
+
+	case "enter":
+		m.state = types.NormalState
+		if m.selectedIdx < len(m.workflowList) {
+			return m.selectWorkflow(m.workflowList[m.selectedIdx])
+		}
+
+	case "esc":
+		m.state = types.NormalState
+	}
+	return nil
+}
+
+// renderWorkflowList renders the workflows to pick from, marking the current one
+func (m Model) renderWorkflowList() string {
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("Workflows in %s (j/k to navigate, enter to select, esc to cancel):\n\n", m.workflowDirectory()))
+
+	for i, file := range m.workflowList {
+		name := filepath.Base(file)
+		if file == m.workflowPath {
+			name += " (current)"
+		}
+		if i == m.selectedIdx {
+			b.WriteString("> " + lipgloss.NewStyle().Foreground(accentColor).Render(name) + "\n")
+		} else {
+			b.WriteString("  " + name + "\n")
+		}
+	}
+
+	return b.String()
+}